@@ -2,6 +2,7 @@ package auth0
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,17 +10,30 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
+	"user-ms/src/metrics"
+	"user-ms/src/requestid"
+	"user-ms/src/tracing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 )
 
 const (
 	USER_ROLE_ID = "rol_IweSr3VWzIst8EDw"
+
+	// tokenExpiryMargin renews cached tokens before Auth0 rejects them.
+	tokenExpiryMargin = time.Minute
 )
 
 type Auth0Client interface {
-	Register(email string, password string) (string, error)
-	getAPIToken() (string, error)
-	setRole(string, string) error
-	Update(email string, auth0ID string) error
+	Register(ctx context.Context, email string, password string) (string, error)
+	getAPIToken(ctx context.Context) (string, error)
+	setRole(ctx context.Context, userId string, apiToken string) error
+	Update(ctx context.Context, email string, auth0ID string) error
+	Delete(ctx context.Context, auth0ID string) error
+	Ping(ctx context.Context) error
 }
 
 type auth0Client struct {
@@ -27,19 +41,29 @@ type auth0Client struct {
 	clientId     string
 	clientSecret string
 	audience     string
+	httpClient   *http.Client
+	// metrics records the token and role requests Register and the other
+	// calls make on their own; NewInstrumentedAuth0Client sets it.
+	metrics *metrics.DomainMetrics
+
+	tokenMutex     sync.Mutex
+	token          string
+	tokenExpiresAt time.Time
 }
 
 func NewAuth0Client(domain string, clientId string, clientSecret string, audience string) Auth0Client {
 	return &auth0Client{
-		domain,
-		clientId,
-		clientSecret,
-		audience,
+		domain:       domain,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		audience:     audience,
+		httpClient:   tracing.NewHTTPClient(0),
 	}
 }
 
 type ApiTokenResponse struct {
 	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
 }
 
 type RegistrationRequest struct {
@@ -61,8 +85,11 @@ type UpdateRequest struct {
 	Connection string `json:"connection"`
 }
 
-func (c *auth0Client) Register(email string, password string) (string, error) {
-	apiToken, err := c.getAPIToken()
+func (c *auth0Client) Register(ctx context.Context, email string, password string) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Auth0Client.Register")
+	defer span.Finish()
+
+	apiToken, err := c.getAPIToken(ctx)
 
 	if err != nil {
 		return "", err
@@ -73,12 +100,14 @@ func (c *auth0Client) Register(email string, password string) (string, error) {
 
 	b, _ := json.Marshal(&RegistrationRequest{Email: email, Password: password, Connection: "Dislinkt-User"})
 
-	req, _ := http.NewRequest("POST", endpoint, bytes.NewBuffer(b))
+	req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(b))
+	requestid.SetHeader(ctx, req.Header)
 	req.Header.Set("content-type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiToken))
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient.Do(req)
 	if err != nil {
+		ext.Error.Set(span, true)
 		return "", err
 	}
 	defer res.Body.Close()
@@ -91,6 +120,7 @@ func (c *auth0Client) Register(email string, password string) (string, error) {
 		}
 
 		fmt.Println(string(b))
+		ext.Error.Set(span, true)
 		return "", errors.New("Failed to register user on Auth0")
 	}
 
@@ -99,14 +129,30 @@ func (c *auth0Client) Register(email string, password string) (string, error) {
 
 	userId := userRegistrationResponse.UserId
 
-	if err := c.setRole(userId, apiToken); err != nil {
+	if err := c.setRole(ctx, userId, apiToken); err != nil {
 		return "", err
 	}
 
 	return userId, nil
 }
 
-func (c *auth0Client) getAPIToken() (string, error) {
+// getAPIToken returns the cached management API token, fetching a new one
+// when it is about to expire.
+func (c *auth0Client) getAPIToken(ctx context.Context) (string, error) {
+	c.tokenMutex.Lock()
+	defer c.tokenMutex.Unlock()
+
+	if c.token != "" && time.Now().Before(c.tokenExpiresAt) {
+		return c.token, nil
+	}
+
+	start := time.Now()
+	token, err := c.fetchAPIToken(ctx)
+	c.metrics.Auth0Call("get_api_token", time.Since(start), err)
+	return token, err
+}
+
+func (c *auth0Client) fetchAPIToken(ctx context.Context) (string, error) {
 	endpoint := fmt.Sprintf("https://%s/oauth/token", c.domain)
 
 	data := url.Values{}
@@ -115,33 +161,91 @@ func (c *auth0Client) getAPIToken() (string, error) {
 	data.Set("client_secret", c.clientSecret)
 	data.Set("audience", c.audience)
 
-	req, _ := http.NewRequest("POST", endpoint, strings.NewReader(data.Encode()))
+	req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(data.Encode()))
+	requestid.SetHeader(ctx, req.Header)
 
 	req.Header.Add("content-type", "application/x-www-form-urlencoded")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 
 	defer res.Body.Close()
 
+	if res.StatusCode != 200 {
+		return "", fmt.Errorf("Failed to get an API token from Auth0, status %d", res.StatusCode)
+	}
+
 	apiTokenResponse := &ApiTokenResponse{}
-	json.NewDecoder(res.Body).Decode(apiTokenResponse)
+	if err := json.NewDecoder(res.Body).Decode(apiTokenResponse); err != nil {
+		return "", err
+	}
+	if apiTokenResponse.AccessToken == "" {
+		return "", errors.New("Auth0 returned an empty API token")
+	}
 
-	return apiTokenResponse.AccessToken, nil
+	c.token = apiTokenResponse.AccessToken
+	c.tokenExpiresAt = time.Now().Add(time.Duration(apiTokenResponse.ExpiresIn)*time.Second - tokenExpiryMargin)
+	return c.token, nil
 }
 
-func (c *auth0Client) setRole(userId string, apiToken string) error {
+// Delete removes the Auth0 user, e.g. one whose local user couldn't be
+// saved.
+func (c *auth0Client) Delete(ctx context.Context, auth0ID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Auth0Client.Delete")
+	defer span.Finish()
+
+	apiToken, err := c.getAPIToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("https://%s/api/v2/users/%s", c.domain, url.PathEscape(auth0ID))
+
+	req, _ := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
+	requestid.SetHeader(ctx, req.Header)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiToken))
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		ext.Error.Set(span, true)
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 204 {
+		ext.Error.Set(span, true)
+		return fmt.Errorf("Failed to delete user on Auth0, status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// Ping checks that a management API token can be obtained.
+func (c *auth0Client) Ping(ctx context.Context) error {
+	_, err := c.getAPIToken(ctx)
+	return err
+}
+
+func (c *auth0Client) setRole(ctx context.Context, userId string, apiToken string) error {
+	start := time.Now()
+	err := c.assignRole(ctx, userId, apiToken)
+	c.metrics.Auth0Call("set_role", time.Since(start), err)
+	return err
+}
+
+func (c *auth0Client) assignRole(ctx context.Context, userId string, apiToken string) error {
 	url := fmt.Sprintf("https://%s/api/v2/users/%s/roles", c.domain, userId)
 
 	b, _ := json.Marshal(&RoleRequest{[]string{USER_ROLE_ID}})
 
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(b))
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(b))
+	requestid.SetHeader(ctx, req.Header)
 	req.Header.Set("content-type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiToken))
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -156,8 +260,11 @@ func (c *auth0Client) setRole(userId string, apiToken string) error {
 	return nil
 }
 
-func (c *auth0Client) Update(email string, auth0ID string) error {
-	apiToken, err := c.getAPIToken()
+func (c *auth0Client) Update(ctx context.Context, email string, auth0ID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Auth0Client.Update")
+	defer span.Finish()
+
+	apiToken, err := c.getAPIToken(ctx)
 
 	if err != nil {
 		return err
@@ -169,12 +276,14 @@ func (c *auth0Client) Update(email string, auth0ID string) error {
 
 	b, _ := json.Marshal(&UpdateRequest{Email: email, Connection: "Dislinkt-User"})
 
-	req, _ := http.NewRequest("PATCH", endpoint, bytes.NewBuffer(b))
+	req, _ := http.NewRequestWithContext(ctx, "PATCH", endpoint, bytes.NewBuffer(b))
+	requestid.SetHeader(ctx, req.Header)
 	req.Header.Set("content-type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiToken))
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.httpClient.Do(req)
 	if err != nil {
+		ext.Error.Set(span, true)
 		return err
 	}
 	defer res.Body.Close()
@@ -187,6 +296,7 @@ func (c *auth0Client) Update(email string, auth0ID string) error {
 		}
 
 		fmt.Println(string(b))
+		ext.Error.Set(span, true)
 		return errors.New("Failed to update user on Auth0")
 	}
 
@@ -1,6 +1,8 @@
 package auth0
 
 import (
+	"context"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -8,7 +10,7 @@ type Auth0ClientMock struct {
 	mock.Mock
 }
 
-func (a *Auth0ClientMock) Register(email string, password string) (string, error) {
+func (a *Auth0ClientMock) Register(ctx context.Context, email string, password string) (string, error) {
 	args := a.Called(email, password)
 	if args.Get(1) == nil {
 		return args.Get(0).(string), nil
@@ -16,18 +18,34 @@ func (a *Auth0ClientMock) Register(email string, password string) (string, error
 	return "", args.Get(1).(error)
 }
 
-func (a *Auth0ClientMock) getAPIToken() (string, error) {
+func (a *Auth0ClientMock) getAPIToken(ctx context.Context) (string, error) {
 	panic("implement me")
 }
 
-func (a *Auth0ClientMock) setRole(s string, s2 string) error {
+func (a *Auth0ClientMock) setRole(ctx context.Context, s string, s2 string) error {
 	panic("implement me")
 }
 
-func (a *Auth0ClientMock) Update(email string, auth0ID string) error {
+func (a *Auth0ClientMock) Update(ctx context.Context, email string, auth0ID string) error {
 	args := a.Called(email, auth0ID)
 	if args.Get(0) != nil {
 		return args.Get(0).(error)
 	}
 	return nil
 }
+
+func (a *Auth0ClientMock) Delete(ctx context.Context, auth0ID string) error {
+	args := a.Called(auth0ID)
+	if args.Get(0) != nil {
+		return args.Get(0).(error)
+	}
+	return nil
+}
+
+func (a *Auth0ClientMock) Ping(ctx context.Context) error {
+	args := a.Called()
+	if args.Get(0) != nil {
+		return args.Get(0).(error)
+	}
+	return nil
+}
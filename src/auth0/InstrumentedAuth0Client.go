@@ -0,0 +1,58 @@
+package auth0
+
+import (
+	"context"
+	"time"
+	"user-ms/src/metrics"
+)
+
+// instrumentedAuth0Client records the latency and outcome of every call to
+// the wrapped client. The token and role requests are made by the client
+// itself, so they are recorded there.
+type instrumentedAuth0Client struct {
+	client  Auth0Client
+	metrics *metrics.DomainMetrics
+}
+
+func NewInstrumentedAuth0Client(client Auth0Client, domainMetrics *metrics.DomainMetrics) Auth0Client {
+	if inner, ok := client.(*auth0Client); ok {
+		inner.metrics = domainMetrics
+	}
+	return &instrumentedAuth0Client{client, domainMetrics}
+}
+
+func (c *instrumentedAuth0Client) Register(ctx context.Context, email string, password string) (string, error) {
+	start := time.Now()
+	auth0ID, err := c.client.Register(ctx, email, password)
+	c.metrics.Auth0Call("register", time.Since(start), err)
+	return auth0ID, err
+}
+
+func (c *instrumentedAuth0Client) getAPIToken(ctx context.Context) (string, error) {
+	return c.client.getAPIToken(ctx)
+}
+
+func (c *instrumentedAuth0Client) setRole(ctx context.Context, userId string, apiToken string) error {
+	return c.client.setRole(ctx, userId, apiToken)
+}
+
+func (c *instrumentedAuth0Client) Update(ctx context.Context, email string, auth0ID string) error {
+	start := time.Now()
+	err := c.client.Update(ctx, email, auth0ID)
+	c.metrics.Auth0Call("update", time.Since(start), err)
+	return err
+}
+
+func (c *instrumentedAuth0Client) Delete(ctx context.Context, auth0ID string) error {
+	start := time.Now()
+	err := c.client.Delete(ctx, auth0ID)
+	c.metrics.Auth0Call("delete", time.Since(start), err)
+	return err
+}
+
+func (c *instrumentedAuth0Client) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := c.client.Ping(ctx)
+	c.metrics.Auth0Call("ping", time.Since(start), err)
+	return err
+}
@@ -0,0 +1,87 @@
+package auth0
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"user-ms/src/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type InstrumentedAuth0ClientTestsSuite struct {
+	suite.Suite
+}
+
+func TestInstrumentedAuth0ClientTestsSuite(t *testing.T) {
+	suite.Run(t, new(InstrumentedAuth0ClientTestsSuite))
+}
+
+func (suite *InstrumentedAuth0ClientTestsSuite) TestRecordsCalls() {
+	registry := prometheus.NewRegistry()
+	domainMetrics, _ := metrics.NewDomainMetrics(registry)
+	clientMock := new(Auth0ClientMock)
+	client := NewInstrumentedAuth0Client(clientMock, domainMetrics)
+	clientMock.On("Register", "test@test.com", "password123").Return("auth0|1", nil).Once()
+	clientMock.On("Update", "test@test.com", "auth0|1").Return(errors.New("Failed to update user on Auth0")).Once()
+
+	auth0ID, err := client.Register(context.Background(), "test@test.com", "password123")
+	assert.Equal(suite.T(), "auth0|1", auth0ID)
+	assert.Nil(suite.T(), err)
+	assert.NotNil(suite.T(), client.Update(context.Background(), "test@test.com", "auth0|1"))
+
+	expected := `
+# HELP auth0_requests_total Calls to the Auth0 management API, by operation and outcome.
+# TYPE auth0_requests_total counter
+auth0_requests_total{operation="register",outcome="success"} 1
+auth0_requests_total{operation="update",outcome="error"} 1
+`
+	assert.Nil(suite.T(), testutil.GatherAndCompare(registry, strings.NewReader(expected), "auth0_requests_total"))
+	count, err := testutil.GatherAndCount(registry, "auth0_request_duration_seconds")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, count)
+}
+
+func (suite *InstrumentedAuth0ClientTestsSuite) TestRecordsTokenAndRoleRequests() {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			w.Write([]byte(`{"access_token":"token","expires_in":86400}`))
+		case "/api/v2/users":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"user_id":"auth0|1"}`))
+		case "/api/v2/users/auth0|1/roles":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	domainMetrics, _ := metrics.NewDomainMetrics(registry)
+	inner := &auth0Client{domain: strings.TrimPrefix(server.URL, "https://"), httpClient: server.Client()}
+	client := NewInstrumentedAuth0Client(inner, domainMetrics)
+
+	auth0ID, err := client.Register(context.Background(), "test@test.com", "password123")
+	assert.Equal(suite.T(), "auth0|1", auth0ID)
+	assert.Nil(suite.T(), err)
+	// The token is cached, the second call doesn't ask Auth0 for it.
+	assert.Nil(suite.T(), client.Ping(context.Background()))
+
+	expected := `
+# HELP auth0_requests_total Calls to the Auth0 management API, by operation and outcome.
+# TYPE auth0_requests_total counter
+auth0_requests_total{operation="get_api_token",outcome="success"} 1
+auth0_requests_total{operation="ping",outcome="success"} 1
+auth0_requests_total{operation="register",outcome="success"} 1
+auth0_requests_total{operation="set_role",outcome="success"} 1
+`
+	assert.Nil(suite.T(), testutil.GatherAndCompare(registry, strings.NewReader(expected), "auth0_requests_total"))
+}
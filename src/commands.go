@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"user-ms/src/dto"
+	"user-ms/src/service"
+)
+
+// runCommand executes an administrative subcommand such as
+// "users-ms export-graph -format graphml" and returns its exit code. The
+// second result is false when args don't name a command, in which case main
+// starts the HTTP server instead.
+func runCommand(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	switch args[0] {
+	case "export-graph":
+		return exportGraphCommand(args[1:]), true
+	case "import-users":
+		return importCommand("import-users", args[1:]), true
+	case "import-follows":
+		return importCommand("import-follows", args[1:]), true
+	}
+	return 0, false
+}
+
+func exportGraphCommand(args []string) int {
+	var exportRequest dto.GraphExportRequestDTO
+	var output string
+
+	flags := flag.NewFlagSet("export-graph", flag.ContinueOnError)
+	flags.StringVar(&exportRequest.Format, "format", service.GraphFormatCSV, "csv or graphml")
+	flags.StringVar(&exportRequest.From, "from", "", "only edges created at or after this date (2006-01-02 or RFC 3339)")
+	flags.StringVar(&exportRequest.To, "to", "", "only edges created before this date (2006-01-02 or RFC 3339)")
+	flags.BoolVar(&exportRequest.ActiveOnly, "active-only", false, "only include active users")
+	flags.BoolVar(&exportRequest.WithUsers, "with-users", false, "include user attributes")
+	flags.StringVar(&output, "out", "", "output file (defaults to stdout)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	database, _ := initDB()
+	defer database.Close()
+
+	exportService := initGraphExportService(initFollowerRepository(database), initUserRepo(database))
+	if err := exportService.Export(out, &exportRequest); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// importCommand runs import-users or import-follows and prints the import
+// report as JSON. It exits with 1 when any row failed.
+func importCommand(name string, args []string) int {
+	var input string
+	var format string
+	var createIdentities bool
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&input, "file", "", "input file (defaults to stdin)")
+	flags.StringVar(&format, "format", service.ImportFormatNDJSON, "ndjson or csv")
+	if name == "import-users" {
+		flags.BoolVar(&createIdentities, "create-identities", false, "create the Auth0 identities of imported users")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var in io.Reader = os.Stdin
+	if input != "" {
+		file, err := os.Open(input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		in = file
+	}
+
+	database, _ := initDB()
+	defer database.Close()
+
+	importService := initImportService(initUserRepo(database), initFollowerRepository(database), initTransactionManager(database), initAuth0Client(nil))
+
+	// Interrupting stops the import after the current row, the rows done so
+	// far are still reported.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var report *dto.ImportReportDTO
+	var err error
+	if name == "import-users" {
+		report, err = importService.ImportUsers(ctx, in, format, createIdentities, 0)
+	} else {
+		report, err = importService.ImportFollows(ctx, in, format, 0)
+	}
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,8 @@
+package dto
+
+// Auth0UserEventDTO is an Auth0 log stream event about a user account,
+// relayed through RabbitMQ.
+type Auth0UserEventDTO struct {
+	UserAuth0ID string
+	Reason      string
+}
@@ -0,0 +1,7 @@
+package dto
+
+type ConnectionCheckDTO struct {
+	UserId      int
+	OtherUserId int
+	Connected   bool
+}
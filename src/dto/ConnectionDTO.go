@@ -0,0 +1,8 @@
+package dto
+
+type ConnectionDTO struct {
+	ID            int
+	InviterId     int
+	InviteeId     int
+	RequestStatus int
+}
@@ -0,0 +1,5 @@
+package dto
+
+type ConnectionInvitationDTO struct {
+	InviteeId int
+}
@@ -0,0 +1,11 @@
+package dto
+
+// ContentReportedEventDTO is published by posts-ms and jobs-ms when a user
+// reports a post, comment or job offer.
+type ContentReportedEventDTO struct {
+	ContentId       string
+	ContentType     string
+	AuthorAuth0ID   string
+	ReporterAuth0ID string
+	Reason          string
+}
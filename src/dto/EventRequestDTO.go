@@ -3,4 +3,5 @@ package dto
 type EventRequestDTO struct {
 	Timestamp string
 	Message   string
+	RequestId string `json:",omitempty"`
 }
@@ -0,0 +1,11 @@
+package dto
+
+import "time"
+
+type FollowEdgeDTO struct {
+	FollowerId        int
+	FollowingId       int
+	CreatedAt         *time.Time
+	FollowerUsername  string
+	FollowingUsername string
+}
@@ -0,0 +1,15 @@
+package dto
+
+type FollowEventDTO struct {
+	FollowerId  int
+	FollowingId int
+	ActorId     int
+}
+
+type FollowRequestEventDTO struct {
+	RequestId     int
+	FollowerId    int
+	FollowingId   int
+	ActorId       int
+	RequestStatus string
+}
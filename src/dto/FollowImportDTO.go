@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type FollowImportDTO struct {
+	FollowerUsername  string     `json:"follower_username"`
+	FollowingUsername string     `json:"following_username"`
+	CreatedAt         *time.Time `json:"created_at"`
+}
@@ -1,8 +1,14 @@
 package dto
 
+import "time"
+
 type FollowingRequestDTO struct {
 	ID            int
 	FollowerId    int
 	FollowingId   int
 	RequestStatus int
+	ActorId       int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DecidedAt     *time.Time
 }
@@ -0,0 +1,9 @@
+package dto
+
+type GraphExportRequestDTO struct {
+	Format     string `form:"format"`
+	From       string `form:"from"`
+	To         string `form:"to"`
+	ActiveOnly bool   `form:"activeOnly"`
+	WithUsers  bool   `form:"withUsers"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+type HealthCheckDTO struct {
+	Name     string
+	Status   string
+	Critical bool
+	Duration string
+	Error    string
+}
+
+type HealthReportDTO struct {
+	Status string
+	Checks []HealthCheckDTO
+}
@@ -0,0 +1,16 @@
+package dto
+
+type ImportRowResultDTO struct {
+	Row    int
+	Key    string
+	Status string
+	Error  string
+}
+
+type ImportReportDTO struct {
+	Total   int
+	Created int
+	Skipped int
+	Failed  int
+	Rows    []ImportRowResultDTO
+}
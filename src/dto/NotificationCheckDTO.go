@@ -0,0 +1,9 @@
+package dto
+
+type NotificationCheckDTO struct {
+	UserId           int
+	NotificationType string
+	Allowed          bool
+	Channels         []string
+	Digest           string
+}
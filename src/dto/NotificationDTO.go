@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"errors"
+	"strings"
+)
+
 type NotificationType int
 
 const (
@@ -7,10 +12,52 @@ const (
 	Follow
 	Like
 	Comment
+	Connection
+	FollowRequestOutcome
 )
 
+// NotificationDTO is sent to the notification service. Message is rendered
+// in the recipient's language; Template, Params and the actor let the
+// notification service render it again. Channels and Digest come from the
+// recipient's preferences, see NotificationDeliveryDTO.
 type NotificationDTO struct {
 	Message          string
 	UserAuth0ID      string
 	NotificationType *NotificationType
+	ActorId          int
+	ActorUsername    string
+	Template         string
+	Locale           string
+	Params           map[string]string
+	Channels         []string
+	Digest           string
+}
+
+// NotificationTypes lists the notification types users set preferences for,
+// in the order they are shown in. Follow request outcomes follow the follow
+// preferences.
+var NotificationTypes = []NotificationType{Message, Follow, Like, Comment, Connection}
+
+var notificationTypeNames = map[NotificationType]string{
+	Message:              "message",
+	Follow:               "follow",
+	Like:                 "like",
+	Comment:              "comment",
+	Connection:           "connection",
+	FollowRequestOutcome: "follow_request",
+}
+
+func (t NotificationType) String() string {
+	return notificationTypeNames[t]
+}
+
+// ParseNotificationType accepts the lower case names used in query
+// parameters, e.g. "like".
+func ParseNotificationType(name string) (NotificationType, error) {
+	for notificationType, typeName := range notificationTypeNames {
+		if strings.EqualFold(typeName, name) {
+			return notificationType, nil
+		}
+	}
+	return 0, errors.New("unknown notification type")
 }
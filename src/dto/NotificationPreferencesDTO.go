@@ -0,0 +1,25 @@
+package dto
+
+type NotificationPreferenceDTO struct {
+	NotificationType string
+	InApp            bool
+	Email            bool
+	Push             bool
+	Digest           string
+}
+
+// NotificationPreferencesDTO is the full preference matrix of a user, one
+// entry per notification type, with their quiet hours.
+type NotificationPreferencesDTO struct {
+	Preferences     []NotificationPreferenceDTO
+	QuietHoursStart string
+	QuietHoursEnd   string
+	Timezone        string
+}
+
+// NotificationDeliveryDTO tells how a notification reaches its recipient. No
+// channels means it isn't sent at all.
+type NotificationDeliveryDTO struct {
+	Channels []string
+	Digest   string
+}
@@ -0,0 +1,9 @@
+package dto
+
+// NotificationTemplateDTO lets the notification service re-render a
+// notification from its Template and Params, e.g. in another language.
+type NotificationTemplateDTO struct {
+	Template         string
+	NotificationType string
+	Messages         map[string]string
+}
@@ -0,0 +1,22 @@
+package dto
+
+// UserEventDTO is the user snapshot carried by user lifecycle events.
+type UserEventDTO struct {
+	ID        int
+	Auth0ID   string
+	Username  string
+	FirstName string
+	LastName  string
+	Public    bool
+	Active    bool
+}
+
+type UserUpdatedEventDTO struct {
+	User          UserEventDTO
+	ChangedFields []string
+}
+
+type UserBlockedEventDTO struct {
+	UserId        int
+	BlockedUserId int
+}
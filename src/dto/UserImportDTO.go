@@ -0,0 +1,18 @@
+package dto
+
+type UserImportDTO struct {
+	Username       string  `json:"username"`
+	FirstName      string  `json:"first_name"`
+	LastName       string  `json:"last_name"`
+	Email          string  `json:"email"`
+	Password       string  `json:"password"`
+	PhoneNumber    string  `json:"phone_number"`
+	Gender         string  `json:"gender"`
+	DateOfBirth    float32 `json:"date_of_birth"`
+	Biography      string  `json:"biography"`
+	Education      string  `json:"education"`
+	WorkExperience string  `json:"work_experience"`
+	Skills         string  `json:"skills"`
+	Interests      string  `json:"interests"`
+	Public         bool    `json:"public"`
+}
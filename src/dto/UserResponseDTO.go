@@ -3,19 +3,20 @@ package dto
 import "user-ms/src/model"
 
 type UserResponseDTO struct {
-	ID             int
-	Auth0ID        string
-	FirstName      string
-	LastName       string
-	Email          string
-	PhoneNumber    string
-	Gender         *model.Gender
-	Username       string
-	DateOfBirth    float32
-	Biography      string
-	Education      string
-	WorkExperience string
-	Skills         string
-	Interests      string
-	Public         bool
+	ID                int
+	Auth0ID           string
+	FirstName         string
+	LastName          string
+	Email             string
+	PhoneNumber       string
+	Gender            *model.Gender
+	Username          string
+	DateOfBirth       float32
+	Biography         string
+	Education         string
+	WorkExperience    string
+	Skills            string
+	Interests         string
+	Public            bool
+	PreferredLanguage string
 }
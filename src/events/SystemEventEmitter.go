@@ -0,0 +1,406 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"user-ms/src/dto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 5 * time.Second
+)
+
+var (
+	ErrNoEmitter   = errors.New("system event emitter is not configured")
+	ErrQueueFull   = errors.New("system event queue is full")
+	ErrClosed      = errors.New("system event emitter is closed")
+	errRetryable   = errors.New("events-ms is unavailable")
+	errNotAccepted = errors.New("events-ms rejected the event")
+)
+
+var (
+	sentEvents = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "system_events_sent_total",
+		Help: "System events delivered to events-ms.",
+	})
+	failedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "system_events_failed_total",
+		Help: "System events that couldn't be delivered, by reason (rejected or spilled).",
+	}, []string{"reason"})
+	droppedEvents = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "system_events_dropped_total",
+		Help: "System events dropped because the queue was full.",
+	})
+)
+
+// SystemEventEmitter delivers system events to events-ms in the background.
+// Events are queued, collected into batches by the workers and posted with
+// retries. Batches that still can't be delivered are appended to SpillPath
+// and replayed every ReplayInterval, and the queue is flushed on Close.
+//
+// When BatchEndpoint is set a batch is posted there as one JSON array,
+// otherwise its events are posted one by one to Endpoint.
+type SystemEventEmitter struct {
+	Endpoint       string
+	BatchEndpoint  string
+	Client         *http.Client
+	Workers        int
+	BatchSize      int
+	FlushInterval  time.Duration
+	MaxRetries     int
+	SpillPath      string
+	ReplayInterval time.Duration
+	Logger         *logrus.Entry
+
+	queue      chan dto.EventRequestDTO
+	mutex      sync.RWMutex
+	closed     bool
+	spillMutex sync.Mutex
+	done       chan struct{}
+	workers    sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+func NewSystemEventEmitter(endpoint string, queueSize int, workers int, batchSize int, timeout time.Duration, logger *logrus.Entry) *SystemEventEmitter {
+	return &SystemEventEmitter{
+		Endpoint:       endpoint,
+		Client:         &http.Client{Timeout: timeout},
+		Workers:        workers,
+		BatchSize:      batchSize,
+		FlushInterval:  time.Second,
+		MaxRetries:     5,
+		ReplayInterval: time.Minute,
+		Logger:         logger,
+		queue:          make(chan dto.EventRequestDTO, queueSize),
+		done:           make(chan struct{}),
+	}
+}
+
+func (emitter *SystemEventEmitter) Start() {
+	for i := 0; i < emitter.Workers; i++ {
+		emitter.workers.Add(1)
+		go emitter.work()
+	}
+	if emitter.SpillPath != "" {
+		go emitter.replayLoop()
+	}
+}
+
+// Emit queues event without blocking. It fails with ErrQueueFull when the
+// queue has no room left; the event is dropped then.
+func (emitter *SystemEventEmitter) Emit(event dto.EventRequestDTO) error {
+	if emitter == nil {
+		return ErrNoEmitter
+	}
+
+	emitter.mutex.RLock()
+	defer emitter.mutex.RUnlock()
+	if emitter.closed {
+		return ErrClosed
+	}
+
+	select {
+	case emitter.queue <- event:
+		return nil
+	default:
+		droppedEvents.Inc()
+		return ErrQueueFull
+	}
+}
+
+// Ping checks that events-ms accepts connections. Nothing is posted; the
+// endpoint may only take events.
+func (emitter *SystemEventEmitter) Ping(ctx context.Context) error {
+	if emitter == nil {
+		return ErrNoEmitter
+	}
+
+	endpoint, err := url.Parse(emitter.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return fmt.Errorf("invalid events-ms endpoint %q", emitter.Endpoint)
+	}
+	address := endpoint.Host
+	if endpoint.Port() == "" {
+		port := "80"
+		if endpoint.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(endpoint.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	connection, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return connection.Close()
+}
+
+// Close stops accepting events and waits until the workers have delivered or
+// spilled everything that was queued.
+func (emitter *SystemEventEmitter) Close() {
+	emitter.closeOnce.Do(func() {
+		emitter.mutex.Lock()
+		emitter.closed = true
+		close(emitter.queue)
+		emitter.mutex.Unlock()
+
+		close(emitter.done)
+		emitter.workers.Wait()
+	})
+}
+
+func (emitter *SystemEventEmitter) work() {
+	defer emitter.workers.Done()
+
+	ticker := time.NewTicker(emitter.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]dto.EventRequestDTO, 0, emitter.BatchSize)
+	for {
+		select {
+		case event, ok := <-emitter.queue:
+			if !ok {
+				emitter.deliver(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) < emitter.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if len(batch) > 0 {
+			emitter.deliver(batch)
+			batch = make([]dto.EventRequestDTO, 0, emitter.BatchSize)
+		}
+	}
+}
+
+// deliver sends batch, retrying with exponential backoff. Retries are
+// skipped once the emitter is closing so shutdown isn't held up; undelivered
+// events are spilled to disk.
+func (emitter *SystemEventEmitter) deliver(batch []dto.EventRequestDTO) {
+	if len(batch) == 0 {
+		return
+	}
+
+	delay := minRetryDelay
+	for attempt := 0; ; attempt++ {
+		remaining, err := emitter.send(batch)
+		sentEvents.Add(float64(len(batch) - len(remaining)))
+		if err == nil {
+			return
+		}
+		batch = remaining
+
+		if errors.Is(err, errNotAccepted) {
+			emitter.Logger.Error(fmt.Sprintf("Dropping %d system events: %s", len(batch), err.Error()))
+			failedEvents.WithLabelValues("rejected").Add(float64(len(batch)))
+			return
+		}
+
+		if attempt >= emitter.MaxRetries || emitter.closing() {
+			emitter.Logger.Error(fmt.Sprintf("Can't deliver %d system events, spilling them to disk: %s", len(batch), err.Error()))
+			failedEvents.WithLabelValues("spilled").Add(float64(len(batch)))
+			emitter.spill(batch)
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-emitter.done:
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// send posts the batch and returns the events that still have to be sent.
+func (emitter *SystemEventEmitter) send(batch []dto.EventRequestDTO) ([]dto.EventRequestDTO, error) {
+	if emitter.BatchEndpoint != "" {
+		if err := emitter.post(emitter.BatchEndpoint, batch); err != nil {
+			return batch, err
+		}
+		return nil, nil
+	}
+
+	for i := range batch {
+		if err := emitter.post(emitter.Endpoint, &batch[i]); err != nil {
+			return batch[i:], err
+		}
+	}
+	return nil, nil
+}
+
+func (emitter *SystemEventEmitter) post(endpoint string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errNotAccepted, err.Error())
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: %s", errNotAccepted, err.Error())
+	}
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := emitter.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errRetryable, err.Error())
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("%w: status %d", errRetryable, resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: status %d", errNotAccepted, resp.StatusCode)
+	}
+}
+
+func (emitter *SystemEventEmitter) closing() bool {
+	select {
+	case <-emitter.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (emitter *SystemEventEmitter) spill(batch []dto.EventRequestDTO) {
+	if emitter.SpillPath == "" {
+		return
+	}
+
+	emitter.spillMutex.Lock()
+	defer emitter.spillMutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(emitter.SpillPath), 0o755); err != nil {
+		emitter.Logger.Error(fmt.Sprintf("Can't create system event spill directory: %s", err.Error()))
+		return
+	}
+	file, err := os.OpenFile(emitter.SpillPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		emitter.Logger.Error(fmt.Sprintf("Can't open system event spill file: %s", err.Error()))
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for i := range batch {
+		if err := encoder.Encode(&batch[i]); err != nil {
+			emitter.Logger.Error(fmt.Sprintf("Can't spill system event: %s", err.Error()))
+			return
+		}
+	}
+}
+
+func (emitter *SystemEventEmitter) replayLoop() {
+	ticker := time.NewTicker(emitter.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		emitter.Replay()
+
+		select {
+		case <-ticker.C:
+		case <-emitter.done:
+			return
+		}
+	}
+}
+
+// Replay moves spilled events back into the queue. Events that don't fit
+// stay in the spill file.
+func (emitter *SystemEventEmitter) Replay() int {
+	emitter.spillMutex.Lock()
+	defer emitter.spillMutex.Unlock()
+
+	file, err := os.Open(emitter.SpillPath)
+	if err != nil {
+		return 0
+	}
+
+	var spilled []dto.EventRequestDTO
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event dto.EventRequestDTO
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+			spilled = append(spilled, event)
+		}
+	}
+	file.Close()
+
+	replayed := 0
+	for ; replayed < len(spilled); replayed++ {
+		if err := emitter.requeue(spilled[replayed]); err != nil {
+			break
+		}
+	}
+
+	if err := writeEvents(emitter.SpillPath, spilled[replayed:]); err != nil {
+		emitter.Logger.Error(fmt.Sprintf("Can't rewrite system event spill file: %s", err.Error()))
+	}
+	if replayed > 0 {
+		emitter.Logger.Info(fmt.Sprintf("Replaying %d spilled system events", replayed))
+	}
+	return replayed
+}
+
+// requeue is Emit without counting a full queue as a drop.
+func (emitter *SystemEventEmitter) requeue(event dto.EventRequestDTO) error {
+	emitter.mutex.RLock()
+	defer emitter.mutex.RUnlock()
+	if emitter.closed {
+		return ErrClosed
+	}
+
+	select {
+	case emitter.queue <- event:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func writeEvents(path string, events []dto.EventRequestDTO) error {
+	if len(events) == 0 {
+		return os.Remove(path)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SystemEventEmitterTestsSuite struct {
+	suite.Suite
+}
+
+func TestSystemEventEmitterTestsSuite(t *testing.T) {
+	suite.Run(t, new(SystemEventEmitterTestsSuite))
+}
+
+// eventsServer records the events it receives and answers with the given
+// status codes in turn, then with 201.
+type eventsServer struct {
+	mutex    sync.Mutex
+	statuses []int
+	events   []dto.EventRequestDTO
+	batches  int
+}
+
+func (server *eventsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(server.statuses) > 0 {
+		status := server.statuses[0]
+		server.statuses = server.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	if r.URL.Path == "/batch" {
+		var batch []dto.EventRequestDTO
+		json.NewDecoder(r.Body).Decode(&batch)
+		server.events = append(server.events, batch...)
+		server.batches++
+	} else {
+		var event dto.EventRequestDTO
+		json.NewDecoder(r.Body).Decode(&event)
+		server.events = append(server.events, event)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (server *eventsServer) received() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return len(server.events)
+}
+
+func (suite *SystemEventEmitterTestsSuite) newEmitter(url string) *SystemEventEmitter {
+	emitter := NewSystemEventEmitter(url+"/events", 10, 1, 5, time.Second, logging.Logger())
+	emitter.FlushInterval = 10 * time.Millisecond
+	emitter.MaxRetries = 2
+	return emitter
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestClose_FlushesQueuedEvents() {
+	server := &eventsServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	emitter := suite.newEmitter(httpServer.URL)
+	emitter.FlushInterval = time.Hour
+	emitter.Start()
+	for i := 0; i < 3; i++ {
+		assert.Nil(suite.T(), emitter.Emit(dto.EventRequestDTO{Message: "event"}))
+	}
+	emitter.Close()
+
+	assert.Equal(suite.T(), 3, server.received())
+	assert.Equal(suite.T(), ErrClosed, emitter.Emit(dto.EventRequestDTO{}))
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestBatchEndpoint() {
+	server := &eventsServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	emitter := suite.newEmitter(httpServer.URL)
+	emitter.BatchEndpoint = httpServer.URL + "/batch"
+	emitter.FlushInterval = time.Hour
+	emitter.Start()
+	for i := 0; i < 5; i++ {
+		emitter.Emit(dto.EventRequestDTO{Message: "event"})
+	}
+	emitter.Close()
+
+	assert.Equal(suite.T(), 5, server.received())
+	assert.Equal(suite.T(), 1, server.batches)
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestRetriesUnavailableEventsService() {
+	server := &eventsServer{statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	emitter := suite.newEmitter(httpServer.URL)
+	emitter.Start()
+	emitter.Emit(dto.EventRequestDTO{Message: "event"})
+
+	assert.Eventually(suite.T(), func() bool { return server.received() == 1 }, 2*time.Second, 10*time.Millisecond)
+	emitter.Close()
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestSpillsAndReplays() {
+	spillPath := filepath.Join(suite.T().TempDir(), "spill.ndjson")
+	emitter := suite.newEmitter("http://localhost:1")
+	emitter.SpillPath = spillPath
+	emitter.deliver([]dto.EventRequestDTO{{Message: "first"}, {Message: "second"}})
+
+	content, err := os.ReadFile(spillPath)
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), string(content), "second")
+
+	assert.Equal(suite.T(), 2, emitter.Replay())
+	assert.Equal(suite.T(), 2, len(emitter.queue))
+	_, err = os.Stat(spillPath)
+	assert.True(suite.T(), os.IsNotExist(err))
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestSpill_CreatesDirectory() {
+	spillPath := filepath.Join(suite.T().TempDir(), "spool", "events", "spill.ndjson")
+	emitter := suite.newEmitter("http://localhost:1")
+	emitter.SpillPath = spillPath
+	emitter.deliver([]dto.EventRequestDTO{{Message: "first"}})
+
+	content, err := os.ReadFile(spillPath)
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), string(content), "first")
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestEmit_QueueFull() {
+	emitter := NewSystemEventEmitter("http://localhost:1", 1, 1, 1, time.Second, logging.Logger())
+
+	assert.Nil(suite.T(), emitter.Emit(dto.EventRequestDTO{}))
+	assert.Equal(suite.T(), ErrQueueFull, emitter.Emit(dto.EventRequestDTO{}))
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestEmit_NoEmitter() {
+	var emitter *SystemEventEmitter
+
+	assert.Equal(suite.T(), ErrNoEmitter, emitter.Emit(dto.EventRequestDTO{}))
+}
+
+func (suite *SystemEventEmitterTestsSuite) TestPing() {
+	httpServer := httptest.NewServer(&eventsServer{})
+	emitter := suite.newEmitter(httpServer.URL)
+
+	assert.Nil(suite.T(), emitter.Ping(context.Background()))
+
+	httpServer.Close()
+	assert.NotNil(suite.T(), emitter.Ping(context.Background()))
+	assert.NotNil(suite.T(), suite.newEmitter("").Ping(context.Background()))
+}
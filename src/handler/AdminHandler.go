@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+)
+
+type AdminHandler struct {
+	GraphExportService *service.GraphExportService
+	ImportService      *service.ImportService
+	MaxImportRows      int
+	Permission         string
+	Role               string
+	Logger             *logrus.Entry
+}
+
+func (handler *AdminHandler) ExportGraph(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /admin/graph/export")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	var exportRequest dto.GraphExportRequestDTO
+	if err := ctx.ShouldBindQuery(&exportRequest); err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if exportRequest.Format == "" {
+		exportRequest.Format = service.GraphFormatCSV
+	}
+
+	ctx.Header("Content-Type", service.GraphContentType(exportRequest.Format))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=followers.%s", exportRequest.Format))
+	ctx.Status(http.StatusOK)
+
+	// The 200 is only sent with the first bytes of the export. Once they
+	// are out, a failure can't change it anymore; the export then ends with
+	// a failure marker, see GraphExportService.Export.
+	if err := handler.GraphExportService.Export(ctx.Writer, &exportRequest); err != nil {
+		if ctx.Writer.Size() <= 0 {
+			logger.Debug(err.Error())
+			ctx.Header("Content-Type", "")
+			ctx.Header("Content-Disposition", "")
+			ctx.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Error(fmt.Sprintf("Follow graph export failed after %d bytes: %s", ctx.Writer.Size(), err.Error()))
+		return
+	}
+
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Follow graph exported as %s", exportRequest.Format))
+}
+
+func (handler *AdminHandler) ImportUsers(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /admin/import/users")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	createIdentities := ctx.Query("createIdentities") == "true"
+
+	report, err := handler.ImportService.ImportUsers(spanCtx, ctx.Request.Body, ctx.DefaultQuery("format", service.ImportFormatNDJSON), createIdentities, handler.MaxImportRows)
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(importErrorStatus(err), err.Error())
+		return
+	}
+
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Imported users: %d created, %d skipped, %d failed", report.Created, report.Skipped, report.Failed))
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+func (handler *AdminHandler) ImportFollows(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /admin/import/follows")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	report, err := handler.ImportService.ImportFollows(spanCtx, ctx.Request.Body, ctx.DefaultQuery("format", service.ImportFormatNDJSON), handler.MaxImportRows)
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(importErrorStatus(err), err.Error())
+		return
+	}
+
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Imported follows: %d created, %d skipped, %d failed", report.Created, report.Skipped, report.Failed))
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// importErrorStatus answers an input longer than MaxImportRows with 413, it
+// has to be split or imported with the import-users/import-follows commands.
+func importErrorStatus(err error) int {
+	if errors.Is(err, service.ErrTooManyImportRows) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
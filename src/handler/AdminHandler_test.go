@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-ms/src/logging"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AdminHandlerTestsSuite struct {
+	suite.Suite
+	router *gin.Engine
+}
+
+func TestAdminHandlerTestsSuite(t *testing.T) {
+	suite.Run(t, new(AdminHandlerTestsSuite))
+}
+
+func (suite *AdminHandlerTestsSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	handler := &AdminHandler{Permission: "admin:users", Role: "admin", Logger: logging.Logger()}
+
+	// The services are nil: a request that gets past RequireAdmin into the
+	// export itself would panic.
+	suite.router = gin.New()
+	suite.router.GET("/admin/graph/export", handler.RequireAdmin, handler.ExportGraph)
+	suite.router.GET("/admin/ping", handler.RequireAdmin, func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+}
+
+func (suite *AdminHandlerTestsSuite) serve(path string, claims jwt.MapClaims) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if claims != nil {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func (suite *AdminHandlerTestsSuite) TestExportGraph_RejectsNonAdmin() {
+	code := suite.serve("/admin/graph/export", jwt.MapClaims{"sub": "auth0|1", "permissions": []string{"read:users"}})
+
+	assert.Equal(suite.T(), http.StatusForbidden, code)
+}
+
+func (suite *AdminHandlerTestsSuite) TestExportGraph_RejectsAnonymous() {
+	assert.Equal(suite.T(), http.StatusUnauthorized, suite.serve("/admin/graph/export", nil))
+}
+
+func (suite *AdminHandlerTestsSuite) TestRequireAdmin_AcceptsPermission() {
+	code := suite.serve("/admin/ping", jwt.MapClaims{"sub": "auth0|1", "permissions": []string{"read:users", "admin:users"}})
+
+	assert.Equal(suite.T(), http.StatusNoContent, code)
+}
+
+func (suite *AdminHandlerTestsSuite) TestRequireAdmin_AcceptsNamespacedRole() {
+	code := suite.serve("/admin/ping", jwt.MapClaims{"sub": "auth0|1", "https://dislinkt.com/roles": []string{"admin"}})
+
+	assert.Equal(suite.T(), http.StatusNoContent, code)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"user-ms/src/logging"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// bearerClaims reads the claims of the bearer token in the Authorization
+// header. The signature isn't checked here, the gateway in front of the
+// service already verified it.
+func bearerClaims(ctx *gin.Context) (jwt.MapClaims, bool) {
+	authorization := ctx.GetHeader("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, false
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(strings.TrimPrefix(authorization, "Bearer "), claims); err != nil {
+		return nil, false
+	}
+	return claims, true
+}
+
+// hasClaimValue reports whether the claim, a string or a list of strings,
+// contains value.
+func hasClaimValue(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []interface{}:
+		for _, item := range claim {
+			if fmt.Sprint(item) == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isAdmin reports whether claims grant permission (Auth0 RBAC puts them in
+// "permissions") or role, found in "roles" or a namespaced ".../roles"
+// claim.
+func isAdmin(claims jwt.MapClaims, permission string, role string) bool {
+	if permission != "" && hasClaimValue(claims["permissions"], permission) {
+		return true
+	}
+	if role == "" {
+		return false
+	}
+	for key, claim := range claims {
+		if (key == "roles" || strings.HasSuffix(key, "/roles")) && hasClaimValue(claim, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAdmin aborts requests without a bearer token with 401 and those
+// whose token has neither the admin permission nor the admin role with 403.
+func (handler *AdminHandler) RequireAdmin(ctx *gin.Context) {
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
+	if !isAdmin(claims, handler.Permission, handler.Role) {
+		logging.WithContext(handler.Logger, ctx.Request.Context()).Info(fmt.Sprintf("User with auth0 id %s denied access to %s", fmt.Sprint(claims["sub"]), ctx.FullPath()))
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "admin access required")
+		return
+	}
+	ctx.Next()
+}
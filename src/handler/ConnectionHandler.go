@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+)
+
+type ConnectionHandler struct {
+	Service *service.ConnectionService
+	Logger  *logrus.Entry
+}
+
+func (handler *ConnectionHandler) Invite(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /connections")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	var invitationDTO dto.ConnectionInvitationDTO
+	if err := ctx.ShouldBindJSON(&invitationDTO); err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
+
+	connectionId, err := handler.Service.Invite(spanCtx, fmt.Sprint(claims["sub"]), invitationDTO.InviteeId)
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s invited user with id %d to connect", fmt.Sprint(claims["sub"]), invitationDTO.InviteeId))
+
+	ctx.JSON(http.StatusCreated, connectionId)
+}
+
+func (handler *ConnectionHandler) Accept(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /connections/:id/accept")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
+
+	connection, err := handler.Service.Accept(spanCtx, fmt.Sprint(claims["sub"]), id)
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Connection with id %d accepted", id))
+
+	ctx.JSON(http.StatusOK, connection)
+}
+
+func (handler *ConnectionHandler) Remove(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "DELETE /connections/:id")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
+
+	if err := handler.Service.Remove(fmt.Sprint(claims["sub"]), id); err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s removed connection with id %d", fmt.Sprint(claims["sub"]), id))
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (handler *ConnectionHandler) GetConnections(ctx *gin.Context) {
+	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /user/:id/connections")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, handler.Service.GetConnections(id))
+}
+
+func (handler *ConnectionHandler) GetInvitations(ctx *gin.Context) {
+	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/me/connection-invitations")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
+
+	invitations, err := handler.Service.GetInvitations(fmt.Sprint(claims["sub"]))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+func (handler *ConnectionHandler) CheckConnection(ctx *gin.Context) {
+	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /connections/check")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	userId, err := strconv.Atoi(ctx.Query("userId"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	otherUserId, err := strconv.Atoi(ctx.Query("otherUserId"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.ConnectionCheckDTO{
+		UserId:      userId,
+		OtherUserId: otherUserId,
+		Connected:   handler.Service.AreConnected(userId, otherUserId),
+	})
+}
@@ -1,19 +1,23 @@
 package handler
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 	"user-ms/src/dto"
+	"user-ms/src/events"
+	"user-ms/src/logging"
+	"user-ms/src/repository"
+	"user-ms/src/requestid"
 	"user-ms/src/service"
-	"user-ms/src/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,65 +27,85 @@ type FollowingHandler struct {
 }
 
 func (handler *FollowingHandler) UpdateRequest(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /requests/:id")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /requests/:id")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	id, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
 	var requestDTO dto.FollowingRequestDTO
 	if err := ctx.ShouldBindJSON(&requestDTO); err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	requestId, err := handler.Service.UpdateRequest(id, &requestDTO)
+	requestId, err := handler.Service.UpdateRequest(spanCtx, id, &requestDTO)
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
+		if errors.Is(err, repository.ErrRequestNotPending) {
+			ctx.JSON(http.StatusConflict, err.Error())
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Following request with id %d updated", id))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Following request with id %d updated", id))
 
 	ctx.JSON(http.StatusCreated, requestId)
 }
 
 func (handler *FollowingHandler) CreateRequest(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /requests")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /requests")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
 
 	var requestDTO dto.FollowingRequestDTO
 	if err := ctx.ShouldBindJSON(&requestDTO); err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	requestId, err := handler.Service.CreateRequest(&requestDTO)
+	requestId, err := handler.Service.CreateRequest(spanCtx, fmt.Sprint(claims["sub"]), &requestDTO)
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
+		if tooManyRequests(ctx, err) {
+			return
+		}
+		if errors.Is(err, service.ErrNotTheCaller) {
+			ctx.JSON(http.StatusForbidden, err.Error())
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Following request with id %d created", requestId))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("Following request with id %d created", requestId))
 
 	ctx.JSON(http.StatusCreated, requestId)
 }
 
 func (handler *FollowingHandler) GetRequest(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /requests")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /requests")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
-	requests, err := handler.Service.GetRequests()
+	requests, err := handler.Service.GetRequests(spanCtx, ctx.Query("sort"), ctx.Query("order"))
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
@@ -90,13 +114,14 @@ func (handler *FollowingHandler) GetRequest(ctx *gin.Context) {
 }
 
 func (handler *FollowingHandler) GetRequestsByFollowingID(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /requests/:id")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /requests/:id")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	id, err := strconv.Atoi(ctx.Param("id"))
-	requests, err := handler.Service.GetRequestsByFollowingID(id)
+	requests, err := handler.Service.GetRequestsByFollowingID(spanCtx, id, ctx.Query("sort"), ctx.Query("order"))
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
@@ -105,36 +130,51 @@ func (handler *FollowingHandler) GetRequestsByFollowingID(ctx *gin.Context) {
 }
 
 func (handler *FollowingHandler) CreatFollower(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /follower")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /follower")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
 
 	var requestDTO dto.FollowingRequestDTO
 	if err := ctx.ShouldBindJSON(&requestDTO); err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	requestId, err := handler.Service.CreateFollower(&requestDTO)
+	requestId, err := handler.Service.CreateFollower(spanCtx, fmt.Sprint(claims["sub"]), &requestDTO)
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
+		if tooManyRequests(ctx, err) {
+			return
+		}
+		if errors.Is(err, service.ErrNotTheCaller) {
+			ctx.JSON(http.StatusForbidden, err.Error())
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d started following user with id %d", requestDTO.FollowerId, requestDTO.FollowingId))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d started following user with id %d", requestDTO.FollowerId, requestDTO.FollowingId))
 
 	ctx.JSON(http.StatusCreated, requestId)
 }
 
 func (handler *FollowingHandler) GetFollowers(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /user/:id/followers")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /user/:id/followers")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	id, err := strconv.Atoi(ctx.Param("id"))
-	requests, err := handler.Service.GetFollowers(id)
+	requests, err := handler.Service.GetFollowers(spanCtx, id, ctx.Query("sort"), ctx.Query("order"))
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
@@ -143,13 +183,14 @@ func (handler *FollowingHandler) GetFollowers(ctx *gin.Context) {
 }
 
 func (handler *FollowingHandler) GetFollowing(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET user/:id/following")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET user/:id/following")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	id, err := strconv.Atoi(ctx.Param("id"))
-	requests, err := handler.Service.GetFollowing(id)
+	requests, err := handler.Service.GetFollowing(spanCtx, id, ctx.Query("sort"), ctx.Query("order"))
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
@@ -158,41 +199,85 @@ func (handler *FollowingHandler) GetFollowing(ctx *gin.Context) {
 }
 
 func (handler *FollowingHandler) RemoveFollowing(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "DELETE /user/:id/removeFollower/:followingId+")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "DELETE /user/:id/removeFollower/:followingId+")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	id, err := strconv.Atoi(ctx.Param("id"))
 	followingId, err := strconv.Atoi(ctx.Param("followingId"))
-	err = handler.Service.RemoveFollowing(id, followingId)
+	err = handler.Service.RemoveFollowing(spanCtx, id, followingId)
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d is not foolowing user %d anymore", id, followingId))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d is not foolowing user %d anymore", id, followingId))
 
 	ctx.JSON(http.StatusCreated, nil)
 }
 
-func AddSystemEvent(time string, message string) error {
-	logger := utils.Logger()
-	event := dto.EventRequestDTO{
-		Timestamp: time,
-		Message:   message,
+func (handler *FollowingHandler) RemoveFollower(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "DELETE /users/me/followers/:followerId")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	followerId, err := strconv.Atoi(ctx.Param("followerId"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
 	}
 
-	b, _ := json.Marshal(&event)
-	endpoint := os.Getenv("EVENTS_MS")
-	logger.Info("Sending system event to events-ms")
-	req, _ := http.NewRequest("POST", endpoint, bytes.NewBuffer(b))
-	req.Header.Set("content-type", "application/json")
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
 
-	_, err := http.DefaultClient.Do(req)
+	err = handler.Service.RemoveFollower(spanCtx, fmt.Sprint(claims["sub"]), followerId)
 	if err != nil {
-		logger.Debug("Error happened during sending system event")
-		return err
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusNotFound, err.Error())
+		return
 	}
 
-	return nil
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s removed follower with id %d", fmt.Sprint(claims["sub"]), followerId))
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// tooManyRequests answers with 429 and a Retry-After header when err comes
+// from the follow rate limiter.
+func tooManyRequests(ctx *gin.Context, err error) bool {
+	var rateLimitErr *service.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		return false
+	}
+
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))))
+	ctx.JSON(http.StatusTooManyRequests, err.Error())
+	return true
+}
+
+// SystemEvents delivers the events recorded with AddSystemEvent. It is set up
+// in main.
+var SystemEvents *events.SystemEventEmitter
+
+// AddSystemEvent queues an audit event for events-ms. It never blocks the
+// request; delivery happens in the background.
+func AddSystemEvent(ctx context.Context, time string, message string) error {
+	span, _ := opentracing.StartSpanFromContext(ctx, "AddSystemEvent")
+	defer span.Finish()
+
+	err := SystemEvents.Emit(dto.EventRequestDTO{
+		Timestamp: time,
+		Message:   message,
+		RequestId: requestid.FromContext(ctx),
+	})
+	if err != nil && SystemEvents != nil {
+		ext.Error.Set(span, true)
+		SystemEvents.Logger.Error(fmt.Sprintf("Can't queue system event: %s", err.Error()))
+	}
+	return err
 }
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-ms/src/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FollowingHandlerTestsSuite struct {
+	suite.Suite
+	router *gin.Engine
+}
+
+func TestFollowingHandlerTestsSuite(t *testing.T) {
+	suite.Run(t, new(FollowingHandlerTestsSuite))
+}
+
+func (suite *FollowingHandlerTestsSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	// The service is nil: a request that gets past the token check would
+	// panic.
+	handler := &FollowingHandler{Logger: logging.Logger()}
+
+	suite.router = gin.New()
+	suite.router.DELETE("/users/me/followers/:followerId", handler.RemoveFollower)
+}
+
+func (suite *FollowingHandlerTestsSuite) serve(method string, path string, authorization string) int {
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func (suite *FollowingHandlerTestsSuite) TestRemoveFollower_MissingToken() {
+	assert.Equal(suite.T(), http.StatusUnauthorized, suite.serve(http.MethodDelete, "/users/me/followers/2", ""))
+}
+
+func (suite *FollowingHandlerTestsSuite) TestRemoveFollower_MalformedToken() {
+	assert.Equal(suite.T(), http.StatusUnauthorized, suite.serve(http.MethodDelete, "/users/me/followers/2", "Bearer"))
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"user-ms/src/dto"
+	"user-ms/src/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+// Liveness only tells that the process is serving requests; dependencies
+// being down is for Readiness to report.
+func (handler *HealthHandler) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dto.HealthReportDTO{Status: health.StatusUp})
+}
+
+// Readiness runs the dependency checks. It responds 503 when a critical check
+// fails; a DEGRADED service is still ready to take traffic.
+func (handler *HealthHandler) Readiness(ctx *gin.Context) {
+	report := handler.Checker.Run(ctx.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, report)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+)
+
+type NotificationHandler struct {
+	Policy            *service.NotificationPolicy
+	PreferenceService *service.NotificationPreferenceService
+	Logger            *logrus.Entry
+}
+
+// CheckNotification tells other services whether a user wants to receive a
+// notification of the given type, e.g. GET /notifications/check?userId=1&type=like.
+func (handler *NotificationHandler) CheckNotification(ctx *gin.Context) {
+	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /notifications/check")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	userId, err := strconv.Atoi(ctx.Query("userId"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notificationType, err := dto.ParseNotificationType(ctx.Query("type"))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	check, err := handler.Policy.Check(userId, notificationType)
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, check)
+}
+
+// GetTemplates returns the notification message catalog, so the notification
+// service can render notifications again from their template and params.
+func (handler *NotificationHandler) GetTemplates(ctx *gin.Context) {
+	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /notifications/templates")
+	defer span.Finish()
+
+	ctx.JSON(http.StatusOK, service.NotificationTemplates())
+}
+
+func (handler *NotificationHandler) GetPreferences(ctx *gin.Context) {
+	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/me/notification-preferences")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
+
+	preferences, err := handler.PreferenceService.GetPreferences(fmt.Sprint(claims["sub"]))
+	if err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, preferences)
+}
+
+func (handler *NotificationHandler) UpdatePreferences(ctx *gin.Context) {
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /users/me/notification-preferences")
+	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
+
+	var preferences dto.NotificationPreferencesDTO
+	if err := ctx.ShouldBindJSON(&preferences); err != nil {
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	claims, ok := bearerClaims(ctx)
+	if !ok {
+		ctx.JSON(http.StatusUnauthorized, "missing or malformed bearer token")
+		return
+	}
+
+	updated, err := handler.PreferenceService.UpdatePreferences(fmt.Sprint(claims["sub"]), &preferences)
+	if err != nil {
+		AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s failed to update notification preferences", fmt.Sprint(claims["sub"])))
+		logger.Debug(err.Error())
+		ctx.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s updated notification preferences", fmt.Sprint(claims["sub"])))
+
+	ctx.JSON(http.StatusOK, updated)
+}
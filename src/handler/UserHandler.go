@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 	"user-ms/src/dto"
+	"user-ms/src/logging"
 	"user-ms/src/service"
 
 	"github.com/dgrijalva/jwt-go"
@@ -23,39 +24,41 @@ type UserHandler struct {
 }
 
 func (handler *UserHandler) Register(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /register")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /register")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	var userToRegister dto.RegistrationRequestDTO
 	if err := ctx.ShouldBindJSON(&userToRegister); err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	userID, err := handler.Service.Register(&userToRegister)
+	userID, err := handler.Service.Register(spanCtx, &userToRegister)
 
 	if err != nil {
-		AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with username %s failed to register", userToRegister.Username))
-		handler.Logger.Debug(err.Error())
+		AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with username %s failed to register", userToRegister.Username))
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("New user registered with id %d", userID))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("New user registered with id %d", userID))
 
 	ctx.JSON(http.StatusCreated, userID)
 }
 
 func (handler *UserHandler) GetByEmail(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	email := ctx.Query("email")
-	user, err := handler.Service.GetByEmail(email)
+	user, err := handler.Service.GetByEmail(spanCtx, email)
 	fmt.Println(err)
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusNotFound, err)
 		return
 	}
@@ -63,26 +66,27 @@ func (handler *UserHandler) GetByEmail(ctx *gin.Context) {
 }
 
 func (handler *UserHandler) GetByUsername(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/username")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/username")
 	defer span.Finish()
 
 	username := ctx.Query("username")
 
-	users := handler.Service.GetByUsername(username)
+	users := handler.Service.GetByUsername(spanCtx, username)
 
 	ctx.JSON(http.StatusOK, users)
 }
 
 func (handler *UserHandler) GetByID(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/:id")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/:id")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	idStr := ctx.Param("id")
 	id, _ := getId(idStr)
-	user, err := handler.Service.GetByID(id)
+	user, err := handler.Service.GetByID(spanCtx, id)
 	fmt.Println(err)
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusNotFound, err)
 		return
 	}
@@ -90,26 +94,27 @@ func (handler *UserHandler) GetByID(ctx *gin.Context) {
 }
 
 func (handler *UserHandler) Update(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /users")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /users")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	var userToUpdate dto.UserUpdateDTO
 	if err := ctx.ShouldBindJSON(&userToUpdate); err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	userDTO, err := handler.Service.Update(&userToUpdate)
+	userDTO, err := handler.Service.Update(spanCtx, &userToUpdate)
 
 	if err != nil {
-		AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d failed to update his profile info", userDTO.ID))
-		handler.Logger.Debug(err.Error())
+		AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d failed to update his profile info", userDTO.ID))
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d updated his profile info", userDTO.ID))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with id %d updated his profile info", userDTO.ID))
 	ctx.JSON(http.StatusOK, userDTO)
 }
 
@@ -133,8 +138,9 @@ func getId(idParam string) (int, error) {
 }
 
 func (handler *UserHandler) BlockUser(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /users/block-user")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /users/block-user")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	blockingID := ctx.Query("id")
 
@@ -142,22 +148,23 @@ func (handler *UserHandler) BlockUser(ctx *gin.Context) {
 
 	id, _ := getId(blockingID)
 
-	err := handler.Service.BlockUser(id, fmt.Sprint(claims["sub"]))
+	err := handler.Service.BlockUser(spanCtx, id, fmt.Sprint(claims["sub"]))
 
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusNotFound, err.Error())
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s blocked user with id %d", fmt.Sprint(claims["sub"]), id))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s blocked user with id %d", fmt.Sprint(claims["sub"]), id))
 
 	ctx.JSON(http.StatusOK, "User successfully blocked")
 }
 
 func (handler *UserHandler) UnblockUser(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /users/unblock-user")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "PUT /users/unblock-user")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	blockingID := ctx.Query("id")
 
@@ -165,69 +172,70 @@ func (handler *UserHandler) UnblockUser(ctx *gin.Context) {
 
 	id, _ := getId(blockingID)
 
-	err := handler.Service.UnblockUser(id, fmt.Sprint(claims["sub"]))
+	err := handler.Service.UnblockUser(spanCtx, id, fmt.Sprint(claims["sub"]))
 	fmt.Println(err)
 	if err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusNotFound, err.Error())
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s unblocked user with id %d", fmt.Sprint(claims["sub"]), id))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s unblocked user with id %d", fmt.Sprint(claims["sub"]), id))
 
 	ctx.JSON(http.StatusOK, "User successfully unblocked")
 }
 
 func (handler *UserHandler) GetBlockedUsers(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/blocked-users")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/blocked-users")
 	defer span.Finish()
 
 	claims, _ := extractClaims(ctx.Request.Header.Get("Authorization"))
 
-	blockedUsers := handler.Service.GetBlockedUsers(fmt.Sprint(claims["sub"]))
+	blockedUsers := handler.Service.GetBlockedUsers(spanCtx, fmt.Sprint(claims["sub"]))
 
 	ctx.JSON(http.StatusOK, blockedUsers)
 }
 
 func (handler *UserHandler) SetNotifications(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /users/set-notifications")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "POST /users/set-notifications")
 	defer span.Finish()
+	logger := logging.WithContext(handler.Logger, ctx.Request.Context())
 
 	var notificationSettings dto.NotificationsUpdateDTO
 	if err := ctx.ShouldBindJSON(&notificationSettings); err != nil {
-		handler.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
 	claims, _ := extractClaims(ctx.Request.Header.Get("Authorization"))
 
-	err := handler.Service.SetNotifications(&notificationSettings, fmt.Sprint(claims["sub"]))
+	err := handler.Service.SetNotifications(spanCtx, &notificationSettings, fmt.Sprint(claims["sub"]))
 	if err != nil {
-		AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s failed to update notifications settings", fmt.Sprint(claims["sub"])))
-		handler.Logger.Debug(err.Error())
+		AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s failed to update notifications settings", fmt.Sprint(claims["sub"])))
+		logger.Debug(err.Error())
 		ctx.JSON(http.StatusBadRequest, err)
 		return
 	}
 
-	AddSystemEvent(time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s updated notifications settings", fmt.Sprint(claims["sub"])))
+	AddSystemEvent(spanCtx, time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf("User with auth0 id %s updated notifications settings", fmt.Sprint(claims["sub"])))
 
 	ctx.JSON(http.StatusOK, true)
 }
 
 func (handler *UserHandler) GetNotifications(ctx *gin.Context) {
-	span, _ := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/get-notifications")
+	span, spanCtx := opentracing.StartSpanFromContext(ctx.Request.Context(), "GET /users/get-notifications")
 	defer span.Finish()
 
 	claims, _ := extractClaims(ctx.Request.Header.Get("Authorization"))
 
-	notificationSettings := handler.Service.GetNotifications(fmt.Sprint(claims["sub"]))
+	notificationSettings := handler.Service.GetNotifications(spanCtx, fmt.Sprint(claims["sub"]))
 
 	ctx.JSON(http.StatusOK, notificationSettings)
 }
 
 func (handler *UserHandler) GetAll(ctx *gin.Context) {
-	users, err := handler.Service.GetAll()
+	users, err := handler.Service.GetAll(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, err.Error())
 		return
@@ -238,7 +246,7 @@ func (handler *UserHandler) GetAll(ctx *gin.Context) {
 
 func (handler *UserHandler) GetByParam(ctx *gin.Context) {
 	searchParam := ctx.Query("param")
-	users, err := handler.Service.GetByParam(searchParam)
+	users, err := handler.Service.GetByParam(ctx.Request.Context(), searchParam)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"user-ms/src/dto"
+)
+
+const (
+	StatusUp       = "UP"
+	StatusDegraded = "DEGRADED"
+	StatusDown     = "DOWN"
+)
+
+// Check is one dependency of the service. A failing critical check makes the
+// service not ready; other failures only degrade it.
+type Check struct {
+	Name     string
+	Critical bool
+	Timeout  time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Checker runs all checks concurrently, each bounded by its own timeout.
+type Checker struct {
+	Checks []Check
+}
+
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{Checks: checks}
+}
+
+func (checker *Checker) Run(ctx context.Context) dto.HealthReportDTO {
+	results := make([]dto.HealthCheckDTO, len(checker.Checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checker.Checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := dto.HealthReportDTO{Status: StatusUp, Checks: results}
+	for _, result := range results {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusDown
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+// run returns when the check does or its timeout expires. Checks that don't
+// honour the context are left to finish in the background.
+func run(ctx context.Context, check Check) dto.HealthCheckDTO {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", check.Timeout)
+	}
+
+	result := dto.HealthCheckDTO{Name: check.Name, Status: StatusUp, Critical: check.Critical, Duration: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CheckerTestsSuite struct {
+	suite.Suite
+}
+
+func TestCheckerTestsSuite(t *testing.T) {
+	suite.Run(t, new(CheckerTestsSuite))
+}
+
+func check(name string, critical bool, err error) Check {
+	return Check{Name: name, Critical: critical, Timeout: time.Second, Run: func(ctx context.Context) error {
+		return err
+	}}
+}
+
+func (suite *CheckerTestsSuite) TestRun_Up() {
+	report := NewChecker(check("postgres", true, nil), check("auth0", false, nil)).Run(context.Background())
+
+	assert.Equal(suite.T(), StatusUp, report.Status)
+	assert.Len(suite.T(), report.Checks, 2)
+	assert.Equal(suite.T(), "postgres", report.Checks[0].Name)
+}
+
+func (suite *CheckerTestsSuite) TestRun_NonCriticalFailureDegrades() {
+	report := NewChecker(check("postgres", true, nil), check("auth0", false, errors.New("unauthorized"))).Run(context.Background())
+
+	assert.Equal(suite.T(), StatusDegraded, report.Status)
+	assert.Equal(suite.T(), StatusDown, report.Checks[1].Status)
+	assert.Equal(suite.T(), "unauthorized", report.Checks[1].Error)
+}
+
+func (suite *CheckerTestsSuite) TestRun_CriticalFailure() {
+	report := NewChecker(check("auth0", false, errors.New("unauthorized")), check("postgres", true, errors.New("connection refused"))).Run(context.Background())
+
+	assert.Equal(suite.T(), StatusDown, report.Status)
+}
+
+func (suite *CheckerTestsSuite) TestRun_Timeout() {
+	hanging := Check{Name: "events-ms", Timeout: 20 * time.Millisecond, Run: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}}
+
+	start := time.Now()
+	report := NewChecker(hanging).Run(context.Background())
+
+	assert.Less(suite.T(), time.Since(start), 500*time.Millisecond)
+	assert.Equal(suite.T(), StatusDegraded, report.Status)
+	assert.Equal(suite.T(), "timed out after 20ms", report.Checks[0].Error)
+}
+
+func (suite *CheckerTestsSuite) TestConnectionChecks() {
+	assert.Nil(suite.T(), connectionError(true, ""))
+	assert.EqualError(suite.T(), connectionError(false, ""), "not connected")
+	assert.EqualError(suite.T(), connectionError(false, "dial tcp: connection refused"), "dial tcp: connection refused")
+}
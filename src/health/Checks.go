@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"time"
+	"user-ms/src/auth0"
+	"user-ms/src/events"
+	"user-ms/src/rabbitmq"
+
+	"github.com/jinzhu/gorm"
+)
+
+func DatabaseCheck(database *gorm.DB, timeout time.Duration) Check {
+	return Check{Name: "postgres", Critical: true, Timeout: timeout, Run: func(ctx context.Context) error {
+		if database == nil {
+			return errors.New("no database connection")
+		}
+		return database.DB().PingContext(ctx)
+	}}
+}
+
+// PublisherCheck fails while the publisher has no open channel. Messages are
+// kept in the outbox meanwhile, but nothing reaches other services.
+func PublisherCheck(publisher rabbitmq.Publisher, timeout time.Duration) Check {
+	return Check{Name: "rabbitmq-publisher", Critical: true, Timeout: timeout, Run: func(ctx context.Context) error {
+		status := publisher.Status()
+		return connectionError(status.Connected, status.LastError)
+	}}
+}
+
+func ConsumerCheck(consumer *rabbitmq.AMQPConsumer, timeout time.Duration) Check {
+	return Check{Name: "rabbitmq-consumer", Timeout: timeout, Run: func(ctx context.Context) error {
+		status := consumer.Status()
+		return connectionError(status.Connected, status.LastError)
+	}}
+}
+
+// Auth0Check fetches a management API token. The client caches tokens, so
+// probes don't call Auth0 on every request.
+func Auth0Check(client auth0.Auth0Client, timeout time.Duration) Check {
+	return Check{Name: "auth0", Timeout: timeout, Run: func(ctx context.Context) error {
+		return client.Ping(ctx)
+	}}
+}
+
+func EventsCheck(emitter *events.SystemEventEmitter, timeout time.Duration) Check {
+	return Check{Name: "events-ms", Timeout: timeout, Run: emitter.Ping}
+}
+
+func connectionError(connected bool, lastError string) error {
+	if connected {
+		return nil
+	}
+	if lastError == "" {
+		return errors.New("not connected")
+	}
+	return errors.New(lastError)
+}
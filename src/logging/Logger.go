@@ -0,0 +1,159 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+	OutputBoth   = "both"
+
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config describes the root logger. MaxSize is in bytes; a file output is
+// rotated once it grows past it, keeping MaxBackups old files.
+type Config struct {
+	Service    string
+	Level      string
+	Format     string
+	Output     string
+	File       string
+	MaxSize    int64
+	MaxBackups int
+}
+
+var (
+	rootMutex sync.RWMutex
+	root      *logrus.Entry
+	rootFile  *RotatingFile
+)
+
+// Configure replaces the root logger every component logs through. It is
+// meant to be called once at startup, before the components are built.
+func Configure(config Config) (*logrus.Entry, error) {
+	level, err := logrus.ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logrus.New()
+	logger.Level = level
+
+	switch strings.ToLower(config.Format) {
+	case FormatJSON:
+		logger.Formatter = &logrus.JSONFormatter{}
+	case FormatText:
+		logger.Formatter = &logrus.TextFormatter{FullTimestamp: true}
+	default:
+		return nil, fmt.Errorf("unknown log format %s", config.Format)
+	}
+
+	var file *RotatingFile
+	switch strings.ToLower(config.Output) {
+	case OutputStdout:
+		logger.Out = os.Stdout
+	case OutputFile, OutputBoth:
+		file, err = NewRotatingFile(config.File, config.MaxSize, config.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		logger.Out = file
+		if strings.ToLower(config.Output) == OutputBoth {
+			logger.Out = io.MultiWriter(os.Stdout, file)
+		}
+	default:
+		return nil, fmt.Errorf("unknown log output %s", config.Output)
+	}
+
+	entry := logger.WithField("service", config.Service)
+
+	rootMutex.Lock()
+	previous := rootFile
+	root, rootFile = entry, file
+	rootMutex.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	return entry, nil
+}
+
+// Logger returns the root logger. Until Configure is called it logs JSON at
+// info level to stdout.
+func Logger() *logrus.Entry {
+	rootMutex.RLock()
+	entry := root
+	rootMutex.RUnlock()
+	if entry != nil {
+		return entry
+	}
+
+	rootMutex.Lock()
+	defer rootMutex.Unlock()
+	if root == nil {
+		logger := logrus.New()
+		logger.Formatter = &logrus.JSONFormatter{}
+		logger.Out = os.Stdout
+		root = logger.WithField("service", os.Getenv("DATABASE_SCHEMA"))
+	}
+	return root
+}
+
+// Close closes the log file of the root logger, if it has one.
+func Close() error {
+	rootMutex.Lock()
+	defer rootMutex.Unlock()
+	if rootFile == nil {
+		return nil
+	}
+	err := rootFile.Close()
+	rootFile = nil
+	return err
+}
+
+type fieldsKey struct{}
+
+// NewContext returns ctx carrying fields, added to those already in it. Every
+// logger passed through WithContext with the new context logs them.
+func NewContext(ctx context.Context, fields logrus.Fields) context.Context {
+	all := logrus.Fields{}
+	for key, value := range Fields(ctx) {
+		all[key] = value
+	}
+	for key, value := range fields {
+		all[key] = value
+	}
+	return context.WithValue(ctx, fieldsKey{}, all)
+}
+
+// Fields returns the fields carried by ctx.
+func Fields(ctx context.Context) logrus.Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	return fields
+}
+
+// WithContext returns logger with the request fields carried by ctx, or the
+// root logger with them when logger is nil.
+func WithContext(logger *logrus.Entry, ctx context.Context) *logrus.Entry {
+	if logger == nil {
+		logger = Logger()
+	}
+	fields := Fields(ctx)
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.WithFields(fields)
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggerTestsSuite struct {
+	suite.Suite
+}
+
+func TestLoggerTestsSuite(t *testing.T) {
+	suite.Run(t, new(LoggerTestsSuite))
+}
+
+func (suite *LoggerTestsSuite) TearDownTest() {
+	Close()
+}
+
+func (suite *LoggerTestsSuite) TestConfigure_File() {
+	path := filepath.Join(suite.T().TempDir(), "logs", "logs.log")
+
+	logger, err := Configure(Config{Service: "users", Level: "warn", Format: FormatJSON, Output: OutputFile, File: path})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), logger, Logger())
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	content, err := os.ReadFile(path)
+	assert.Nil(suite.T(), err)
+	var line map[string]interface{}
+	assert.Nil(suite.T(), json.Unmarshal(content, &line))
+	assert.Equal(suite.T(), "kept", line["msg"])
+	assert.Equal(suite.T(), "users", line["service"])
+}
+
+func (suite *LoggerTestsSuite) TestConfigure_Invalid() {
+	_, err := Configure(Config{Level: "loud", Format: FormatJSON, Output: OutputStdout})
+	assert.NotNil(suite.T(), err)
+
+	_, err = Configure(Config{Level: "info", Format: "xml", Output: OutputStdout})
+	assert.NotNil(suite.T(), err)
+
+	_, err = Configure(Config{Level: "info", Format: FormatText, Output: "syslog"})
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *LoggerTestsSuite) TestWithContext() {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Formatter = &logrus.JSONFormatter{}
+	logger.Out = &out
+
+	ctx := NewContext(context.Background(), logrus.Fields{"request_id": "abc"})
+	ctx = NewContext(ctx, logrus.Fields{"sub": "auth0|1"})
+	WithContext(logger.WithField("component", "test"), ctx).Info("handled")
+
+	var line map[string]interface{}
+	assert.Nil(suite.T(), json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(suite.T(), "abc", line["request_id"])
+	assert.Equal(suite.T(), "auth0|1", line["sub"])
+	assert.Equal(suite.T(), "test", line["component"])
+}
+
+func (suite *LoggerTestsSuite) TestWithContext_NoFields() {
+	entry := logrus.NewEntry(logrus.New())
+
+	assert.Equal(suite.T(), entry, WithContext(entry, context.Background()))
+	assert.NotNil(suite.T(), WithContext(nil, nil))
+}
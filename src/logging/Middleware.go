@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"user-ms/src/requestid"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Middleware puts the request fields (request ID, trace ID, route and the
+// caller's sub) in the request context and logs every request once it has
+// been handled. It must run after the tracing and request ID middlewares.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Request = ctx.Request.WithContext(NewContext(ctx.Request.Context(), RequestFields(ctx)))
+
+		ctx.Next()
+
+		WithContext(Logger(), ctx.Request.Context()).WithFields(logrus.Fields{
+			"method":     ctx.Request.Method,
+			"path":       ctx.Request.URL.Path,
+			"status":     ctx.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  ctx.ClientIP(),
+		}).Info("Request handled")
+	}
+}
+
+// RequestFields returns the fields identifying the request; those that are
+// unknown are left out.
+func RequestFields(ctx *gin.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if route := ctx.FullPath(); route != "" {
+		fields["route"] = route
+	}
+	if requestID := requestid.FromContext(ctx.Request.Context()); requestID != "" {
+		fields["request_id"] = requestID
+	}
+	if span := opentracing.SpanFromContext(ctx.Request.Context()); span != nil {
+		if spanContext, ok := span.Context().(jaeger.SpanContext); ok {
+			fields["trace_id"] = spanContext.TraceID().String()
+		}
+	}
+	if sub := subject(ctx.GetHeader("Authorization")); sub != "" {
+		fields["sub"] = sub
+	}
+	return fields
+}
+
+// subject reads the sub claim of a bearer token without verifying it, the
+// gateway in front of the service already did.
+func subject(authorization string) string {
+	tokenStr := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer"))
+	if tokenStr == "" {
+		return ""
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenStr, claims); err != nil {
+		return ""
+	}
+	if sub, ok := claims["sub"]; ok {
+		return fmt.Sprint(sub)
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user-ms/src/requestid"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber/jaeger-client-go"
+)
+
+type MiddlewareTestsSuite struct {
+	suite.Suite
+}
+
+func TestMiddlewareTestsSuite(t *testing.T) {
+	suite.Run(t, new(MiddlewareTestsSuite))
+}
+
+func (suite *MiddlewareTestsSuite) TestMiddleware_RequestFields() {
+	tracer, closer := jaeger.NewTracer("users-ms", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span := tracer.StartSpan("test")
+	defer span.Finish()
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "auth0|1"}).SignedString([]byte("secret"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(opentracing.ContextWithSpan(ctx.Request.Context(), span))
+	})
+	router.Use(requestid.Middleware())
+	router.Use(Middleware())
+	var fields logrus.Fields
+	router.GET("/users/:id", func(ctx *gin.Context) {
+		fields = Fields(ctx.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(requestid.Header, "abc")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(suite.T(), "abc", fields["request_id"])
+	assert.Equal(suite.T(), "auth0|1", fields["sub"])
+	assert.Equal(suite.T(), "/users/:id", fields["route"])
+	assert.Equal(suite.T(), span.Context().(jaeger.SpanContext).TraceID().String(), fields["trace_id"])
+}
+
+func (suite *MiddlewareTestsSuite) TestMiddleware_Anonymous() {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	var fields logrus.Fields
+	router.GET("/healthz", func(ctx *gin.Context) {
+		fields = Fields(ctx.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(suite.T(), logrus.Fields{"route": "/healthz"}, fields)
+}
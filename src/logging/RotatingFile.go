@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile appends to a log file and rotates it once it grows past
+// MaxSize bytes. Old files are kept as Path.1 (the newest) to
+// Path.MaxBackups. A MaxSize of zero never rotates.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	file := &RotatingFile{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (file *RotatingFile) Write(p []byte) (int, error) {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	if file.file == nil {
+		return 0, os.ErrClosed
+	}
+	if file.MaxSize > 0 && file.size > 0 && file.size+int64(len(p)) > file.MaxSize {
+		if err := file.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := file.file.Write(p)
+	file.size += int64(n)
+	return n, err
+}
+
+func (file *RotatingFile) Close() error {
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+
+	if file.file == nil {
+		return nil
+	}
+	err := file.file.Close()
+	file.file = nil
+	return err
+}
+
+func (file *RotatingFile) open() error {
+	opened, err := os.OpenFile(file.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := opened.Stat()
+	if err != nil {
+		opened.Close()
+		return err
+	}
+
+	file.file = opened
+	file.size = info.Size()
+	return nil
+}
+
+func (file *RotatingFile) rotate() error {
+	if err := file.file.Close(); err != nil {
+		return err
+	}
+	file.file = nil
+
+	if file.MaxBackups > 0 {
+		for i := file.MaxBackups - 1; i > 0; i-- {
+			os.Rename(file.backup(i), file.backup(i+1))
+		}
+		if err := os.Rename(file.Path, file.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(file.Path); err != nil {
+		return err
+	}
+
+	return file.open()
+}
+
+func (file *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", file.Path, i)
+}
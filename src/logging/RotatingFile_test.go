@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RotatingFileTestsSuite struct {
+	suite.Suite
+	path string
+}
+
+func TestRotatingFileTestsSuite(t *testing.T) {
+	suite.Run(t, new(RotatingFileTestsSuite))
+}
+
+func (suite *RotatingFileTestsSuite) SetupTest() {
+	suite.path = filepath.Join(suite.T().TempDir(), "logs.log")
+}
+
+func (suite *RotatingFileTestsSuite) read(path string) string {
+	content, err := os.ReadFile(path)
+	assert.Nil(suite.T(), err)
+	return string(content)
+}
+
+func (suite *RotatingFileTestsSuite) TestWrite_Rotates() {
+	file, err := NewRotatingFile(suite.path, 10, 2)
+	assert.Nil(suite.T(), err)
+	defer file.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := file.Write([]byte(line))
+		assert.Nil(suite.T(), err)
+	}
+
+	assert.Equal(suite.T(), "fourth\n", suite.read(suite.path))
+	assert.Equal(suite.T(), "third\n", suite.read(suite.path+".1"))
+	assert.Equal(suite.T(), "second\n", suite.read(suite.path+".2"))
+	_, err = os.Stat(suite.path + ".3")
+	assert.True(suite.T(), os.IsNotExist(err))
+}
+
+func (suite *RotatingFileTestsSuite) TestWrite_NoBackups() {
+	file, err := NewRotatingFile(suite.path, 10, 0)
+	assert.Nil(suite.T(), err)
+	defer file.Close()
+
+	file.Write([]byte("first\n"))
+	file.Write([]byte("second\n"))
+
+	assert.Equal(suite.T(), "second\n", suite.read(suite.path))
+	_, err = os.Stat(suite.path + ".1")
+	assert.True(suite.T(), os.IsNotExist(err))
+}
+
+func (suite *RotatingFileTestsSuite) TestNewRotatingFile_Appends() {
+	assert.Nil(suite.T(), os.WriteFile(suite.path, []byte("old\n"), 0666))
+
+	file, err := NewRotatingFile(suite.path, 0, 1)
+	assert.Nil(suite.T(), err)
+	file.Write([]byte("new\n"))
+	assert.Nil(suite.T(), file.Close())
+
+	assert.Equal(suite.T(), "old\nnew\n", suite.read(suite.path))
+	_, err = file.Write([]byte("closed\n"))
+	assert.NotNil(suite.T(), err)
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 	"time"
 	"user-ms/src/auth0"
+	"user-ms/src/events"
 	"user-ms/src/handler"
+	"user-ms/src/health"
+	"user-ms/src/logging"
+	"user-ms/src/metrics"
 	"user-ms/src/model"
 	"user-ms/src/rabbitmq"
 	"user-ms/src/repository"
+	"user-ms/src/requestid"
 	"user-ms/src/service"
+	"user-ms/src/tracing"
 	"user-ms/src/utils"
 
 	"github.com/gin-gonic/gin"
@@ -20,10 +28,9 @@ import (
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
-	"github.com/streadway/amqp"
+	"github.com/sirupsen/logrus"
 	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 )
@@ -31,6 +38,27 @@ import (
 var db *gorm.DB
 var err error
 
+// initLogger configures the root logger by LOG_OUTPUT: "stdout", "file" or
+// "both". A broken configuration falls back to JSON on stdout.
+func initLogger() *logrus.Entry {
+	config := logging.Config{
+		Service:    os.Getenv("DATABASE_SCHEMA"),
+		Level:      utils.GetEnvString("LOG_LEVEL", "info"),
+		Format:     utils.GetEnvString("LOG_FORMAT", logging.FormatJSON),
+		Output:     utils.GetEnvString("LOG_OUTPUT", logging.OutputStdout),
+		File:       utils.GetEnvString("LOG_FILE", "./logs/logs.log"),
+		MaxSize:    int64(utils.GetEnvInt("LOG_FILE_MAX_SIZE_MB", 100)) * 1024 * 1024,
+		MaxBackups: utils.GetEnvInt("LOG_FILE_MAX_BACKUPS", 5),
+	}
+
+	logger, err := logging.Configure(config)
+	if err != nil {
+		logger = logging.Logger()
+		logger.Error(fmt.Sprintf("Can't configure logging, using stdout: %s", err.Error()))
+	}
+	return logger
+}
+
 func initDB() (*gorm.DB, error) {
 	host := os.Getenv("DATABASE_DOMAIN")
 	user := os.Getenv("DATABASE_USERNAME")
@@ -51,33 +79,190 @@ func initDB() (*gorm.DB, error) {
 	if err != nil {
 		panic("failed to connect database")
 	}
+	tracing.RegisterGormCallbacks(db)
 
 	db.AutoMigrate(model.User{})
 	db.AutoMigrate(model.FollowingRequest{})
 	db.AutoMigrate(model.Follower{})
+	db.AutoMigrate(model.FollowAttempt{})
+	db.AutoMigrate(model.Connection{})
+	db.AutoMigrate(model.OutboxMessage{})
+	db.AutoMigrate(model.ProcessedMessage{})
+	db.AutoMigrate(model.NotificationPreference{})
+	db.AutoMigrate(model.NotificationSchedule{})
+	if err := repository.RunMigrations(db, migrations); err != nil {
+		logging.Logger().Error(fmt.Sprintf("Can't run migrations: %s", err.Error()))
+	}
 	return db, err
 }
 
+// migrations run once per database, after AutoMigrate, in this order.
+var migrations = []repository.Migration{
+	// Users used to be created with active left false, so every user that
+	// existed before users were created active is activated.
+	{Name: "activate_existing_users", Up: func(tx *gorm.DB) error {
+		return tx.Model(&model.User{}).UpdateColumn("active", true).Error
+	}},
+	// Requests made before created_at was added have none. They get a full
+	// TTL from now rather than being expired on the first run.
+	{Name: "backfill_following_request_created_at", Up: func(tx *gorm.DB) error {
+		return tx.Exec("update following_requests set created_at = coalesce(updated_at, decided_at, now()) where created_at is null").Error
+	}},
+	// At most one pending or accepted connection per pair of users, whoever
+	// invited whom. Duplicates made before the index existed are rejected,
+	// keeping the oldest.
+	{Name: "unique_connection_pair", Up: func(tx *gorm.DB) error {
+		err := tx.Exec(`update connections set request_status = ? where request_status <> ? and exists (
+			select 1 from connections older where older.id < connections.id and older.request_status <> ?
+			and least(older.inviter_id, older.invitee_id) = least(connections.inviter_id, connections.invitee_id)
+			and greatest(older.inviter_id, older.invitee_id) = greatest(connections.inviter_id, connections.invitee_id))`,
+			model.REJECTED, model.REJECTED, model.REJECTED).Error
+		if err != nil {
+			return err
+		}
+		// DDL takes no bind parameters.
+		return tx.Exec(fmt.Sprintf("create unique index if not exists idx_connections_pair on connections (least(inviter_id, invitee_id), greatest(inviter_id, invitee_id)) where request_status <> %d", model.REJECTED)).Error
+	}},
+	// Only notifications are published as mandatory now. The ones still
+	// waiting in the outbox were stored before the column existed.
+	{Name: "mandatory_pending_notifications", Up: func(tx *gorm.DB) error {
+		return tx.Model(&model.OutboxMessage{}).Where("exchange = ? and delivered_at is null and failed_at is null", rabbitmq.NotificationExchange).
+			UpdateColumn("mandatory", true).Error
+	}},
+}
+
+// initPublisher picks the message broker by MESSAGE_BROKER: "amqp" (the
+// default) for RabbitMQ, "memory" to keep messages in the process.
+func initPublisher() rabbitmq.Publisher {
+	rabbitmq.NotificationExchange = utils.GetEnvString("RABBITMQ_NOTIFICATION_EXCHANGE", rabbitmq.NotificationExchange)
+	rabbitmq.NotificationRoutingKey = utils.GetEnvString("RABBITMQ_NOTIFICATION_ROUTING_KEY", rabbitmq.NotificationRoutingKey)
+	rabbitmq.NotificationQueue = utils.GetEnvString("RABBITMQ_NOTIFICATION_QUEUE", rabbitmq.NotificationQueue)
+	rabbitmq.DomainEventsExchange = utils.GetEnvString("RABBITMQ_EVENTS_EXCHANGE", rabbitmq.DomainEventsExchange)
+	rabbitmq.DeadLetterExchange = utils.GetEnvString("RABBITMQ_DEAD_LETTER_EXCHANGE", rabbitmq.DeadLetterExchange)
+	rabbitmq.DeadLetterQueue = utils.GetEnvString("RABBITMQ_DEAD_LETTER_QUEUE", rabbitmq.DeadLetterQueue)
+
+	broker := utils.GetEnvString("MESSAGE_BROKER", "amqp")
+	switch broker {
+	case "memory":
+		return rabbitmq.NewInMemoryPublisher(logging.Logger())
+	case "amqp":
+	default:
+		logging.Logger().Error(fmt.Sprintf("Unknown message broker %s, using amqp", broker))
+	}
+
+	amqpServerURL := os.Getenv("AMQP_SERVER_URL")
+	bufferSize := utils.GetEnvInt("RABBITMQ_BUFFER_SIZE", 1000)
+	confirmTimeout := utils.GetEnvDuration("RABBITMQ_CONFIRM_TIMEOUT", 5*time.Second)
+
+	publisher := rabbitmq.NewAMQPPublisher(amqpServerURL, bufferSize, confirmTimeout, logging.Logger())
+	publisher.Topology = initTopology()
+	return publisher
+}
+
+// initTopology reads the topology from RABBITMQ_TOPOLOGY_FILE, falling back
+// to the default one. Declaring it can be turned off when the broker is
+// provisioned by someone else.
+func initTopology() *rabbitmq.Topology {
+	if !utils.GetEnvBool("RABBITMQ_DECLARE_TOPOLOGY", true) {
+		return nil
+	}
+
+	path := os.Getenv("RABBITMQ_TOPOLOGY_FILE")
+	if path == "" {
+		return rabbitmq.DefaultTopology()
+	}
+
+	topology, err := rabbitmq.LoadTopology(path)
+	if err != nil {
+		logging.Logger().Error(fmt.Sprintf("Can't load RabbitMQ topology from %s, using the default one: %s", path, err.Error()))
+		return rabbitmq.DefaultTopology()
+	}
+	return topology
+}
+
+func initTransactionManager(database *gorm.DB) *repository.TransactionManager {
+	return &repository.TransactionManager{Database: database}
+}
+
+func initOutboxRelay(transactions *repository.TransactionManager, publisher rabbitmq.Publisher, domainMetrics *metrics.DomainMetrics) *service.OutboxRelay {
+	batchSize := utils.GetEnvInt("OUTBOX_BATCH_SIZE", 100)
+	interval := utils.GetEnvDuration("OUTBOX_RELAY_INTERVAL", time.Second)
+	retention := utils.GetEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour)
+	maxAttempts := utils.GetEnvInt("OUTBOX_MAX_ATTEMPTS", 10)
+	batchTimeout := utils.GetEnvDuration("OUTBOX_BATCH_TIMEOUT", 10*time.Second)
+
+	return service.NewOutboxRelay(transactions, publisher, batchSize, interval, retention, maxAttempts, batchTimeout, domainMetrics, logging.Logger())
+}
+
+// initConsumer consumes events from other services. With the in-memory broker
+// the handlers are subscribed to the publisher and nil is returned.
+func initConsumer(transactions *repository.TransactionManager, publisher rabbitmq.Publisher) *rabbitmq.AMQPConsumer {
+	if !utils.GetEnvBool("CONSUMER_ENABLED", true) {
+		return nil
+	}
+
+	rabbitmq.RetryExchange = utils.GetEnvString("RABBITMQ_RETRY_EXCHANGE", rabbitmq.RetryExchange)
+	contentExchange := utils.GetEnvString("CONSUMER_CONTENT_EXCHANGE", "content-moderation")
+	auth0Exchange := utils.GetEnvString("CONSUMER_AUTH0_EXCHANGE", "auth0-log-stream")
+	bindings := []rabbitmq.BindingConfig{
+		{Exchange: contentExchange, RoutingKey: service.ContentReportedEvents},
+		{Exchange: auth0Exchange, RoutingKey: service.Auth0UserBlockedEvents},
+		{Exchange: auth0Exchange, RoutingKey: service.Auth0PasswordChangedEvents},
+	}
+
+	router := rabbitmq.NewRouter(logging.Logger())
+	externalEventService := service.NewExternalEventService(transactions, utils.GetEnvInt("CONTENT_REPORT_THRESHOLD", 5), logging.Logger())
+	service.RegisterExternalEvents(router, externalEventService)
+
+	if memory, ok := publisher.(*rabbitmq.InMemoryPublisher); ok {
+		memory.Consume(bindings, router.Dispatch)
+		return nil
+	}
+
+	queue := utils.GetEnvString("CONSUMER_QUEUE", "users-ms-external-events")
+	prefetch := utils.GetEnvInt("CONSUMER_PREFETCH", 10)
+	maxRetries := utils.GetEnvInt("CONSUMER_MAX_RETRIES", 5)
+	retryDelay := utils.GetEnvDuration("CONSUMER_RETRY_DELAY", 30*time.Second)
+
+	return rabbitmq.NewAMQPConsumer(os.Getenv("AMQP_SERVER_URL"), queue, bindings, prefetch, maxRetries, retryDelay, router.Dispatch, logging.Logger())
+}
+
+func initSystemEventEmitter() *events.SystemEventEmitter {
+	endpoint := os.Getenv("EVENTS_MS")
+	queueSize := utils.GetEnvInt("EVENTS_QUEUE_SIZE", 10000)
+	workers := utils.GetEnvInt("EVENTS_WORKERS", 2)
+	batchSize := utils.GetEnvInt("EVENTS_BATCH_SIZE", 50)
+	timeout := utils.GetEnvDuration("EVENTS_TIMEOUT", 5*time.Second)
+
+	emitter := events.NewSystemEventEmitter(endpoint, queueSize, workers, batchSize, timeout, logging.Logger())
+	emitter.BatchEndpoint = os.Getenv("EVENTS_MS_BATCH")
+	emitter.FlushInterval = utils.GetEnvDuration("EVENTS_FLUSH_INTERVAL", time.Second)
+	emitter.MaxRetries = utils.GetEnvInt("EVENTS_MAX_RETRIES", 5)
+	emitter.SpillPath = utils.GetEnvString("EVENTS_SPILL_PATH", "./logs/system-events.ndjson")
+	emitter.ReplayInterval = utils.GetEnvDuration("EVENTS_REPLAY_INTERVAL", time.Minute)
+	return emitter
+}
+
 func initUserRepo(database *gorm.DB) *repository.UserRepository {
 	return &repository.UserRepository{Database: database}
 }
 
-func initAuth0Client() *auth0.Auth0Client {
+func initAuth0Client(domainMetrics *metrics.DomainMetrics) *auth0.Auth0Client {
 	domain := os.Getenv("AUTH0_DOMAIN")
 	clientId := os.Getenv("AUTH0_CLIENT_ID")
 	clientSecret := os.Getenv("AUTH0_CLIENT_SECRET")
 	audience := os.Getenv("AUTH0_AUDIENCE")
 
-	client := auth0.NewAuth0Client(domain, clientId, clientSecret, audience)
+	client := auth0.NewInstrumentedAuth0Client(auth0.NewAuth0Client(domain, clientId, clientSecret, audience), domainMetrics)
 	return &client
 }
 
-func initUserService(userRepo *repository.UserRepository, auth0Client *auth0.Auth0Client) *service.UserService {
-	return &service.UserService{UserRepo: userRepo, Auth0Client: *auth0Client, Logger: utils.Logger()}
+func initUserService(userRepo *repository.UserRepository, auth0Client *auth0.Auth0Client, transactions *repository.TransactionManager, domainMetrics *metrics.DomainMetrics) *service.UserService {
+	return &service.UserService{UserRepo: userRepo, Auth0Client: *auth0Client, Transactions: transactions, Metrics: domainMetrics, Logger: logging.Logger()}
 }
 
 func initUserHandler(service *service.UserService) *handler.UserHandler {
-	return &handler.UserHandler{Service: service, Logger: utils.Logger()}
+	return &handler.UserHandler{Service: service, Logger: logging.Logger()}
 }
 
 func handleUserFunc(handler *handler.UserHandler, router *gin.Engine) {
@@ -103,12 +288,68 @@ func initFollowingRequestRepository(database *gorm.DB) *repository.FollowingRequ
 	return &repository.FollowingRequestRepository{Database: database}
 }
 
-func initFollowingService(followerRepository *repository.FollowerRepository, followingRequestRepository *repository.FollowingRequestRepository, userRepository *repository.UserRepository, channel *amqp.Channel) *service.FollowingService {
-	return &service.FollowingService{FollowerRepository: followerRepository, FollowingRequestRepository: followingRequestRepository, UserRepository: userRepository, RabbitMQChannel: channel, Logger: utils.Logger()}
+func initFollowRateLimiter(followerRepository *repository.FollowerRepository, followingRequestRepository *repository.FollowingRequestRepository, domainMetrics *metrics.DomainMetrics) service.IFollowRateLimiter {
+	limits := service.FollowLimits{
+		FollowsPerHour:    utils.GetEnvInt("FOLLOW_LIMIT_PER_HOUR", 30),
+		FollowsPerDay:     utils.GetEnvInt("FOLLOW_LIMIT_PER_DAY", 200),
+		RequestsPerHour:   utils.GetEnvInt("FOLLOW_REQUEST_LIMIT_PER_HOUR", 20),
+		RequestsPerDay:    utils.GetEnvInt("FOLLOW_REQUEST_LIMIT_PER_DAY", 100),
+		RejectionCooldown: utils.GetEnvDuration("FOLLOW_REJECTION_COOLDOWN", 7*24*time.Hour),
+	}
+
+	divisor := utils.GetEnvInt("NEW_ACCOUNT_LIMIT_DIVISOR", 4)
+	if divisor < 1 {
+		divisor = 1
+	}
+	newAccountLimits := service.FollowLimits{
+		FollowsPerHour:    limits.FollowsPerHour / divisor,
+		FollowsPerDay:     limits.FollowsPerDay / divisor,
+		RequestsPerHour:   limits.RequestsPerHour / divisor,
+		RequestsPerDay:    limits.RequestsPerDay / divisor,
+		RejectionCooldown: limits.RejectionCooldown,
+	}
+	newAccountAge := utils.GetEnvDuration("NEW_ACCOUNT_AGE", 7*24*time.Hour)
+
+	return service.NewFollowRateLimiter(followerRepository, followingRequestRepository, limits, newAccountLimits, newAccountAge, domainMetrics, logging.Logger())
+}
+
+func initFollowingService(followerRepository *repository.FollowerRepository, followingRequestRepository *repository.FollowingRequestRepository, userRepository *repository.UserRepository, transactions *repository.TransactionManager, rateLimiter service.IFollowRateLimiter, notificationPolicy *service.NotificationPolicy, domainMetrics *metrics.DomainMetrics) *service.FollowingService {
+	return &service.FollowingService{FollowerRepository: followerRepository, FollowingRequestRepository: followingRequestRepository, UserRepository: userRepository, Transactions: transactions, RateLimiter: rateLimiter, NotificationPolicy: notificationPolicy, NotifyRejections: utils.GetEnvBool("FOLLOW_REQUEST_REJECTION_NOTIFY", false), Metrics: domainMetrics, Logger: logging.Logger()}
+}
+
+func initFollowingRequestExpiryJob(transactions *repository.TransactionManager, notificationPolicy *service.NotificationPolicy) *service.FollowingRequestExpiryJob {
+	ttl := utils.GetEnvDuration("FOLLOW_REQUEST_TTL", service.DefaultRequestTTL)
+	interval := utils.GetEnvDuration("FOLLOW_REQUEST_EXPIRY_INTERVAL", service.DefaultExpiryInterval)
+	notify := utils.GetEnvBool("FOLLOW_REQUEST_EXPIRY_NOTIFY", false)
+
+	return service.NewFollowingRequestExpiryJob(transactions, notificationPolicy, ttl, interval, notify, logging.Logger())
+}
+
+func initNotificationPreferenceRepository(database *gorm.DB) *repository.NotificationPreferenceRepository {
+	return &repository.NotificationPreferenceRepository{Database: database}
+}
+
+func initNotificationPolicy(userRepository *repository.UserRepository, preferenceRepository *repository.NotificationPreferenceRepository) *service.NotificationPolicy {
+	return &service.NotificationPolicy{UserRepository: userRepository, PreferenceRepository: preferenceRepository, Logger: logging.Logger()}
+}
+
+func initNotificationPreferenceService(userRepository *repository.UserRepository, transactions *repository.TransactionManager) *service.NotificationPreferenceService {
+	return &service.NotificationPreferenceService{UserRepository: userRepository, Transactions: transactions, Logger: logging.Logger()}
+}
+
+func initNotificationHandler(policy *service.NotificationPolicy, preferenceService *service.NotificationPreferenceService) *handler.NotificationHandler {
+	return &handler.NotificationHandler{Policy: policy, PreferenceService: preferenceService, Logger: logging.Logger()}
+}
+
+func handleNotificationFunc(handler *handler.NotificationHandler, router *gin.Engine) {
+	router.GET("/notifications/check", handler.CheckNotification)
+	router.GET("/notifications/templates", handler.GetTemplates)
+	router.GET("/users/me/notification-preferences", handler.GetPreferences)
+	router.PUT("/users/me/notification-preferences", handler.UpdatePreferences)
 }
 
 func initFollowingHandler(service *service.FollowingService) *handler.FollowingHandler {
-	return &handler.FollowingHandler{Service: service, Logger: utils.Logger()}
+	return &handler.FollowingHandler{Service: service, Logger: logging.Logger()}
 }
 
 func handleFollowingFunc(handler *handler.FollowingHandler, router *gin.Engine) {
@@ -120,6 +361,47 @@ func handleFollowingFunc(handler *handler.FollowingHandler, router *gin.Engine)
 	router.GET("user/:id/followers", handler.GetFollowers)
 	router.GET("user/:id/following", handler.GetFollowing)
 	router.DELETE("user/:id/removeFollower/:followingId", handler.RemoveFollowing)
+	router.DELETE("/users/me/followers/:followerId", handler.RemoveFollower)
+}
+
+func initConnectionRepository(database *gorm.DB) *repository.ConnectionRepository {
+	return &repository.ConnectionRepository{Database: database}
+}
+
+func initConnectionService(connectionRepository *repository.ConnectionRepository, userRepository *repository.UserRepository, transactions *repository.TransactionManager, notificationPolicy *service.NotificationPolicy) *service.ConnectionService {
+	return &service.ConnectionService{ConnectionRepository: connectionRepository, UserRepository: userRepository, Transactions: transactions, NotificationPolicy: notificationPolicy, Logger: logging.Logger()}
+}
+
+func initConnectionHandler(service *service.ConnectionService) *handler.ConnectionHandler {
+	return &handler.ConnectionHandler{Service: service, Logger: logging.Logger()}
+}
+
+func handleConnectionFunc(handler *handler.ConnectionHandler, router *gin.Engine) {
+	router.POST("/connections", handler.Invite)
+	router.PUT("/connections/:id/accept", handler.Accept)
+	router.DELETE("/connections/:id", handler.Remove)
+	router.GET("/connections/check", handler.CheckConnection)
+	router.GET("/users/me/connection-invitations", handler.GetInvitations)
+	router.GET("user/:id/connections", handler.GetConnections)
+}
+
+func initGraphExportService(followerRepository *repository.FollowerRepository, userRepository *repository.UserRepository) *service.GraphExportService {
+	return &service.GraphExportService{FollowerRepository: followerRepository, UserRepository: userRepository, Logger: logging.Logger()}
+}
+
+func initImportService(userRepository *repository.UserRepository, followerRepository *repository.FollowerRepository, transactions *repository.TransactionManager, auth0Client *auth0.Auth0Client) *service.ImportService {
+	interval := utils.GetEnvDuration("AUTH0_IMPORT_INTERVAL", 500*time.Millisecond)
+	return &service.ImportService{UserRepo: userRepository, FollowerRepository: followerRepository, Transactions: transactions, Auth0Client: *auth0Client, Auth0Interval: interval, Logger: logging.Logger()}
+}
+
+func initAdminHandler(graphExportService *service.GraphExportService, importService *service.ImportService) *handler.AdminHandler {
+	return &handler.AdminHandler{GraphExportService: graphExportService, ImportService: importService, MaxImportRows: utils.GetEnvInt("IMPORT_MAX_ROWS", 1000), Permission: utils.GetEnvString("ADMIN_PERMISSION", "admin:users"), Role: utils.GetEnvString("ADMIN_ROLE", "admin"), Logger: logging.Logger()}
+}
+
+func handleAdminFunc(handler *handler.AdminHandler, router *gin.Engine) {
+	router.GET("/admin/graph/export", handler.RequireAdmin, handler.ExportGraph)
+	router.POST("/admin/import/users", handler.RequireAdmin, handler.ImportUsers)
+	router.POST("/admin/import/follows", handler.RequireAdmin, handler.ImportFollows)
 }
 
 func addPredefinedAdmins(repo *repository.UserRepository) {
@@ -134,6 +416,7 @@ func addPredefinedAdmins(repo *repository.UserRepository) {
 		Gender:      &gender,
 		Password:    "$2a$10$GNysTh1mfPQbnNUHQM.iCe5cLIejAWU.6A1TTPDUOa/3.aUvlyG3a",
 		Auth0ID:     "auth0|62af383e504e5680df88c742",
+		Active:      true,
 	}
 
 	admin2 := model.User{
@@ -146,6 +429,7 @@ func addPredefinedAdmins(repo *repository.UserRepository) {
 		Gender:      &gender,
 		Password:    "$2a$10$GNysTh1mfPQbnNUHQM.iCe5cLIejAWU.6A1TTPDUOa/3.aUvlyG3a",
 		Auth0ID:     "auth0|62af385cb690199c1c89faab",
+		Active:      true,
 	}
 
 	admin3 := model.User{
@@ -158,6 +442,7 @@ func addPredefinedAdmins(repo *repository.UserRepository) {
 		Gender:      &gender,
 		Password:    "$2a$10$GNysTh1mfPQbnNUHQM.iCe5cLIejAWU.6A1TTPDUOa/3.aUvlyG3a",
 		Auth0ID:     "auth0|62af387270e7f4c2c978fbc4",
+		Active:      true,
 	}
 	admins := []model.User{}
 	admins = append(admins, admin1)
@@ -169,14 +454,14 @@ func addPredefinedAdmins(repo *repository.UserRepository) {
 
 func InitJaeger() (opentracing.Tracer, io.Closer, error) {
 	cfg := config.Configuration{
-		ServiceName: "users-ms",
+		ServiceName: utils.GetEnvString("JAEGER_SERVICE_NAME", "users-ms"),
 		Sampler: &config.SamplerConfig{
-			Type:  "const",
-			Param: 1,
+			Type:  utils.GetEnvString("JAEGER_SAMPLER_TYPE", "const"),
+			Param: utils.GetEnvFloat("JAEGER_SAMPLER_PARAM", 1),
 		},
 		Reporter: &config.ReporterConfig{
-			LogSpans:           true,
-			LocalAgentHostPort: "jaeger:6831",
+			LogSpans:           utils.GetEnvBool("JAEGER_REPORTER_LOG_SPANS", true),
+			LocalAgentHostPort: utils.GetEnvString("JAEGER_AGENT_HOST_PORT", "jaeger:6831"),
 		},
 	}
 
@@ -184,85 +469,54 @@ func InitJaeger() (opentracing.Tracer, io.Closer, error) {
 	return tracer, closer, err
 }
 
-var totalTrafficSizeInGB = prometheus.NewCounter(
-	prometheus.CounterOpts{
-		Name: "http_requests_total_traffic_size_in_gb",
-		Help: "Total traffic size in GB.",
-	},
-)
-
-var total404Requests = prometheus.NewCounterVec(
-	prometheus.CounterOpts{
-		Name: "http_requests_total_404",
-		Help: "Total number of 404 requests.",
-	},
-	[]string{"path"},
-)
-
-var totalRequests = prometheus.NewCounterVec(
-	prometheus.CounterOpts{
-		Name: "http_requests_total",
-		Help: "Total number of requests.",
-	},
-	[]string{"path"},
-)
+// initHealthHandler sets up the readiness checks. The consumer is only checked
+// when one was started.
+func initHealthHandler(database *gorm.DB, publisher rabbitmq.Publisher, consumer *rabbitmq.AMQPConsumer, auth0Client auth0.Auth0Client, systemEvents *events.SystemEventEmitter) *handler.HealthHandler {
+	timeout := utils.GetEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second)
 
-var responseStatus = prometheus.NewCounterVec(
-	prometheus.CounterOpts{
-		Name: "http_response_status",
-		Help: "Status of HTTP response",
-	},
-	[]string{"status"},
-)
-
-var httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
-	Name: "http_response_time_seconds",
-	Help: "Duration of HTTP requests.",
-}, []string{"path"})
-
-var uniqueClients = promauto.NewCounterVec(prometheus.CounterOpts{
-	Name: "http_unique_clients",
-	Help: "Number of unique clients.",
-}, []string{"ip", "timestamp", "browser"})
-
-func prometheusMiddleware() gin.HandlerFunc {
-	return gin.HandlerFunc(func(ctx *gin.Context) {
-		path := ctx.Request.RequestURI
-
-		requestSize := ctx.Request.ContentLength
-
-		ip := ctx.ClientIP()
-		browser := ctx.Request.UserAgent()
-
-		timer := prometheus.NewTimer(httpDuration.WithLabelValues(path))
-
-		ctx.Next()
-
-		responseSize := ctx.Writer.Size()
-
-		responseStatus.WithLabelValues(strconv.Itoa(ctx.Writer.Status())).Inc()
-		totalRequests.WithLabelValues(path).Inc()
-		uniqueClients.WithLabelValues(ip, time.Now().Format(time.UnixDate), browser).Inc()
-
-		if responseSize < 0 {
-			responseSize = 0
-		}
-		totalTrafficSizeInGB.Add((float64(requestSize + int64(responseSize))) / 1073741824)
+	checks := []health.Check{
+		health.DatabaseCheck(database, timeout),
+		health.PublisherCheck(publisher, timeout),
+		health.Auth0Check(auth0Client, utils.GetEnvDuration("HEALTH_CHECK_AUTH0_TIMEOUT", 5*time.Second)),
+		health.EventsCheck(systemEvents, timeout),
+	}
+	if consumer != nil {
+		checks = append(checks, health.ConsumerCheck(consumer, timeout))
+	}
+	return &handler.HealthHandler{Checker: health.NewChecker(checks...)}
+}
 
-		if ctx.Writer.Status() == 404 {
-			total404Requests.WithLabelValues(path).Inc()
-		}
+func initHTTPMetrics() *metrics.HTTPMetrics {
+	window := utils.GetEnvDuration("METRICS_UNIQUE_CLIENTS_WINDOW", 24*time.Hour)
+	uniqueClients, err := metrics.NewUniqueClients(utils.GetEnvInt("METRICS_HLL_PRECISION", metrics.DefaultPrecision), window)
+	if err != nil {
+		logging.Logger().Warn(fmt.Sprintf("Invalid METRICS_HLL_PRECISION, using %d: %s", metrics.DefaultPrecision, err.Error()))
+		uniqueClients, _ = metrics.NewUniqueClients(metrics.DefaultPrecision, window)
+	}
+	httpMetrics, err := metrics.NewHTTPMetrics(prometheus.DefaultRegisterer, uniqueClients)
+	if err != nil {
+		panic(err)
+	}
+	return httpMetrics
+}
 
-		timer.ObserveDuration()
-	})
+func initDomainMetrics() *metrics.DomainMetrics {
+	domainMetrics, err := metrics.NewDomainMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		panic(err)
+	}
+	return domainMetrics
 }
 
-func setupPrometherus() {
-	prometheus.Register(totalRequests)
-	prometheus.Register(responseStatus)
-	prometheus.Register(httpDuration)
-	prometheus.Register(total404Requests)
-	prometheus.Register(totalTrafficSizeInGB)
+// initCORS allows every origin like cors.AllowAll, and lets browsers read
+// the request ID of responses.
+func initCORS() *cors.Cors {
+	return cors.New(cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodHead, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{requestid.Header},
+	})
 }
 
 func prometheusGin() gin.HandlerFunc {
@@ -273,21 +527,33 @@ func prometheusGin() gin.HandlerFunc {
 }
 
 func main() {
-	logger := utils.Logger()
-
-	logger.Info("Connecting with DB")
-	database, _ := initDB()
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
 
-	amqpServerURL := os.Getenv("AMQP_SERVER_URL")
+	logger := initLogger()
+	defer logging.Close()
 
-	rabbit := rabbitmq.RMQProducer{
-		ConnectionString: amqpServerURL,
+	if code, ok := runCommand(os.Args[1:]); ok {
+		logging.Close()
+		os.Exit(code)
 	}
 
-	logger.Info("Connecting with RabbitMQ")
-	channel, _ := rabbit.StartRabbitMQ()
+	logger.Info("Connecting with DB")
+	database, _ := initDB()
+
+	logger.Info("Connecting with the message broker")
+	publisher := initPublisher()
+	publisher.Start()
+	defer publisher.Close()
 
-	defer channel.Close()
+	systemEvents := initSystemEventEmitter()
+	systemEvents.Start()
+	defer systemEvents.Close()
+	handler.SystemEvents = systemEvents
 
 	port := fmt.Sprintf(":%s", os.Getenv("SERVER_PORT"))
 
@@ -301,29 +567,95 @@ func main() {
 		opentracing.SetGlobalTracer(tracer)
 	}
 
+	transactions := initTransactionManager(database)
+	domainMetrics := initDomainMetrics()
+
 	userRepo := initUserRepo(database)
-	auth0Client := initAuth0Client()
-	userService := initUserService(userRepo, auth0Client)
+	auth0Client := initAuth0Client(domainMetrics)
+	userService := initUserService(userRepo, auth0Client, transactions, domainMetrics)
 	userHandler := initUserHandler(userService)
 
+	notificationPreferenceRepo := initNotificationPreferenceRepository(database)
+	notificationPolicy := initNotificationPolicy(userRepo, notificationPreferenceRepo)
+	notificationPreferenceService := initNotificationPreferenceService(userRepo, transactions)
+	notificationHandler := initNotificationHandler(notificationPolicy, notificationPreferenceService)
+
 	followingReqRepo := initFollowingRequestRepository(database)
 	followerRepo := initFollowerRepository(database)
-	followingService := initFollowingService(followerRepo, followingReqRepo, userRepo, channel)
+	followRateLimiter := initFollowRateLimiter(followerRepo, followingReqRepo, domainMetrics)
+	followingService := initFollowingService(followerRepo, followingReqRepo, userRepo, transactions, followRateLimiter, notificationPolicy, domainMetrics)
 	followingHandler := initFollowingHandler(followingService)
 
-	router := gin.Default()
+	stopJobs := make(chan struct{})
+	defer close(stopJobs)
+
+	outboxRelay := initOutboxRelay(transactions, publisher, domainMetrics)
+	go outboxRelay.Start(stopJobs)
+
+	expiryJob := initFollowingRequestExpiryJob(transactions, notificationPolicy)
+	go expiryJob.Start(stopJobs)
+
+	consumer := initConsumer(transactions, publisher)
+	if consumer != nil {
+		consumer.Start()
+	}
+
+	connectionRepo := initConnectionRepository(database)
+	connectionService := initConnectionService(connectionRepo, userRepo, transactions, notificationPolicy)
+	connectionHandler := initConnectionHandler(connectionService)
 
-	setupPrometherus()
+	graphExportService := initGraphExportService(followerRepo, userRepo)
+	importService := initImportService(userRepo, followerRepo, transactions, auth0Client)
+	adminHandler := initAdminHandler(graphExportService, importService)
 
-	router.Use(prometheusMiddleware())
+	healthHandler := initHealthHandler(database, publisher, consumer, *auth0Client, systemEvents)
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	router.Use(requestid.Middleware())
+	router.Use(tracing.Middleware())
+	router.Use(logging.Middleware())
+	router.Use(initHTTPMetrics().Middleware())
 
 	router.GET("/api/metrics", prometheusGin())
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
 
 	handleFollowingFunc(followingHandler, router)
 	handleUserFunc(userHandler, router)
+	handleConnectionFunc(connectionHandler, router)
+	handleAdminFunc(adminHandler, router)
+	handleNotificationFunc(notificationHandler, router)
 
 	addPredefinedAdmins(userRepo)
 
-	logger.Info(fmt.Sprintf("Starting server on port %s", port))
-	http.ListenAndServe(port, cors.AllowAll().Handler(router))
+	server := &http.Server{Addr: port, Handler: initCORS().Handler(router)}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info(fmt.Sprintf("Starting server on port %s", port))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-shutdown:
+	case err := <-serverErr:
+		// Exit non-zero once everything below and the deferred closes ran.
+		logger.Error(fmt.Sprintf("Server failed: %s", err.Error()))
+		exitCode = 1
+	}
+
+	logger.Info("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), utils.GetEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second))
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error(err.Error())
+	}
+	if consumer != nil {
+		consumer.Close()
+	}
 }
@@ -0,0 +1,15 @@
+package mapper
+
+import (
+	"user-ms/src/dto"
+	"user-ms/src/model"
+)
+
+func ConnectionToDTO(connection *model.Connection) *dto.ConnectionDTO {
+	var connectionDTO dto.ConnectionDTO
+	connectionDTO.ID = connection.ID
+	connectionDTO.InviterId = connection.InviterId
+	connectionDTO.InviteeId = connection.InviteeId
+	connectionDTO.RequestStatus = int(connection.RequestStatus)
+	return &connectionDTO
+}
@@ -12,6 +12,13 @@ func FollowingDTOToFollower(followingRequestDTO *dto.FollowingRequestDTO) *model
 	return &follower
 }
 
+func RequestToFollower(request *model.FollowingRequest) *model.Follower {
+	var follower model.Follower
+	follower.FollowerId = request.FollowerId
+	follower.FollowingId = request.FollowingId
+	return &follower
+}
+
 func FollowingDTOToRequestFollower(followingRequestDTO *dto.FollowingRequestDTO) *model.FollowingRequest {
 	var followingRequest model.FollowingRequest
 	followingRequest.ID = followingRequestDTO.ID
@@ -26,6 +33,10 @@ func RequestToFollowingDTO(request *model.FollowingRequest) *dto.FollowingReques
 	follower.FollowingId = request.FollowerId
 	follower.FollowerId = request.FollowingId
 	follower.RequestStatus = int(request.RequestStatus)
+	follower.ActorId = request.ActorId
+	follower.CreatedAt = request.CreatedAt
+	follower.UpdatedAt = request.UpdatedAt
+	follower.DecidedAt = request.DecidedAt
 	return &follower
 }
 
@@ -35,3 +46,21 @@ func FollowingRequestToFollower(request *model.FollowingRequest) *model.Follower
 	follower.FollowerId = request.FollowingId
 	return &follower
 }
+
+func FollowerToEventDTO(follower *model.Follower) *dto.FollowEventDTO {
+	return &dto.FollowEventDTO{
+		FollowerId:  follower.FollowerId,
+		FollowingId: follower.FollowingId,
+		ActorId:     follower.ActorId,
+	}
+}
+
+func RequestToEventDTO(request *model.FollowingRequest) *dto.FollowRequestEventDTO {
+	return &dto.FollowRequestEventDTO{
+		RequestId:     request.ID,
+		FollowerId:    request.FollowerId,
+		FollowingId:   request.FollowingId,
+		ActorId:       request.ActorId,
+		RequestStatus: request.RequestStatus.String(),
+	}
+}
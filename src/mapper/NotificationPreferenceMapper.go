@@ -0,0 +1,41 @@
+package mapper
+
+import (
+	"user-ms/src/dto"
+	"user-ms/src/model"
+)
+
+func NotificationPreferencesToDTO(preferences []model.NotificationPreference, schedule *model.NotificationSchedule) *dto.NotificationPreferencesDTO {
+	var preferencesDTO dto.NotificationPreferencesDTO
+
+	preferencesDTO.Preferences = make([]dto.NotificationPreferenceDTO, len(preferences))
+	for i, preference := range preferences {
+		preferencesDTO.Preferences[i] = dto.NotificationPreferenceDTO{
+			NotificationType: preference.NotificationType,
+			InApp:            preference.InApp,
+			Email:            preference.Email,
+			Push:             preference.Push,
+			Digest:           string(preference.Digest),
+		}
+	}
+
+	if schedule != nil {
+		preferencesDTO.QuietHoursStart = schedule.QuietHoursStart
+		preferencesDTO.QuietHoursEnd = schedule.QuietHoursEnd
+		preferencesDTO.Timezone = schedule.Timezone
+	}
+
+	return &preferencesDTO
+}
+
+func NotificationPreferenceDTOToPreference(preferenceDTO *dto.NotificationPreferenceDTO) *model.NotificationPreference {
+	var preference model.NotificationPreference
+
+	preference.NotificationType = preferenceDTO.NotificationType
+	preference.InApp = preferenceDTO.InApp
+	preference.Email = preferenceDTO.Email
+	preference.Push = preferenceDTO.Push
+	preference.Digest = model.DigestFrequency(preferenceDTO.Digest)
+
+	return &preference
+}
@@ -16,6 +16,8 @@ func RegistrationRequestDTOToUser(registeredUserDto *dto.RegistrationRequestDTO)
 	user.Email = registeredUserDto.Email
 	user.PhoneNumber = registeredUserDto.PhoneNumber
 	user.Password = registeredUserDto.Password
+	user.PreferredLanguage = registeredUserDto.PreferredLanguage
+	user.Active = true
 	return &user
 }
 
@@ -37,6 +39,7 @@ func UserToDTO(userEntity *model.User) *dto.UserResponseDTO {
 	user.Skills = userEntity.Skills
 	user.Interests = userEntity.Interests
 	user.Public = userEntity.Public
+	user.PreferredLanguage = userEntity.PreferredLanguage
 
 	return &user
 }
@@ -58,6 +61,7 @@ func UserUpdateDTOToUser(userUpdateDTO *dto.UserUpdateDTO) *model.User {
 	user.Skills = userUpdateDTO.Skills
 	user.Interests = userUpdateDTO.Interests
 	user.Public = userUpdateDTO.Public
+	user.PreferredLanguage = userUpdateDTO.PreferredLanguage
 
 	return &user
 }
@@ -81,3 +85,39 @@ func UserToNotificationsDTO(userEntity *model.User) *dto.NotificationsUpdateDTO
 
 	return &notifications
 }
+
+func UserImportDTOToUser(userImportDTO *dto.UserImportDTO, gender model.Gender) *model.User {
+	var user model.User
+
+	user.Username = userImportDTO.Username
+	user.FirstName = userImportDTO.FirstName
+	user.LastName = userImportDTO.LastName
+	user.Email = userImportDTO.Email
+	user.Password = userImportDTO.Password
+	user.PhoneNumber = userImportDTO.PhoneNumber
+	user.Gender = &gender
+	user.DateOfBirth = userImportDTO.DateOfBirth
+	user.Biography = userImportDTO.Biography
+	user.Education = userImportDTO.Education
+	user.WorkExperience = userImportDTO.WorkExperience
+	user.Skills = userImportDTO.Skills
+	user.Interests = userImportDTO.Interests
+	user.Public = userImportDTO.Public
+	user.Active = true
+
+	return &user
+}
+
+func UserToEventDTO(userEntity *model.User) *dto.UserEventDTO {
+	var user dto.UserEventDTO
+
+	user.ID = userEntity.ID
+	user.Auth0ID = userEntity.Auth0ID
+	user.Username = userEntity.Username
+	user.FirstName = userEntity.FirstName
+	user.LastName = userEntity.LastName
+	user.Public = userEntity.Public
+	user.Active = userEntity.Active
+
+	return &user
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of follow requests, follows and blocks.
+const (
+	RequestCreated  = "created"
+	RequestAccepted = "accepted"
+	RequestRejected = "rejected"
+
+	Followed   = "followed"
+	Unfollowed = "unfollowed"
+
+	Blocked   = "blocked"
+	Unblocked = "unblocked"
+
+	RegistrationSucceeded = "success"
+
+	Published     = "published"
+	Unroutable    = "unroutable"
+	PublishFailed = "failed"
+)
+
+// DomainMetrics counts what users do rather than HTTP traffic. Services get
+// it injected; a nil *DomainMetrics records nothing, so services and tests
+// that don't care can leave it out.
+type DomainMetrics struct {
+	registrations         *prometheus.CounterVec
+	followRequests        *prometheus.CounterVec
+	follows               *prometheus.CounterVec
+	blocks                *prometheus.CounterVec
+	followRateLimited     *prometheus.CounterVec
+	notifications         *prometheus.CounterVec
+	auth0Requests         *prometheus.CounterVec
+	auth0RequestDurations *prometheus.HistogramVec
+}
+
+func NewDomainMetrics(registerer prometheus.Registerer) (*DomainMetrics, error) {
+	metrics := &DomainMetrics{
+		registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "users_registrations_total",
+			Help: "User registrations, by outcome (success or the reason they failed).",
+		}, []string{"outcome"}),
+		followRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "users_follow_requests_total",
+			Help: "Follow requests created, accepted and rejected.",
+		}, []string{"outcome"}),
+		follows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "users_follows_total",
+			Help: "Follows and unfollows.",
+		}, []string{"action"}),
+		blocks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "users_blocks_total",
+			Help: "Blocks and unblocks.",
+		}, []string{"action"}),
+		followRateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "follow_rate_limited_total",
+			Help: "Number of follows and following requests rejected by the rate limiter.",
+		}, []string{"action", "reason"}),
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "users_notifications_published_total",
+			Help: "Notifications relayed to the broker, by type and outcome.",
+		}, []string{"type", "outcome"}),
+		auth0Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth0_requests_total",
+			Help: "Calls to the Auth0 management API, by operation and outcome.",
+		}, []string{"operation", "outcome"}),
+		auth0RequestDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "auth0_request_duration_seconds",
+			Help:    "Duration of calls to the Auth0 management API.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"operation"}),
+	}
+
+	collectors := []prometheus.Collector{metrics.registrations, metrics.followRequests, metrics.follows, metrics.blocks, metrics.followRateLimited, metrics.notifications, metrics.auth0Requests, metrics.auth0RequestDurations}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return metrics, nil
+}
+
+func (metrics *DomainMetrics) Registration(outcome string) {
+	if metrics == nil {
+		return
+	}
+	metrics.registrations.WithLabelValues(outcome).Inc()
+}
+
+func (metrics *DomainMetrics) FollowRequest(outcome string) {
+	if metrics == nil {
+		return
+	}
+	metrics.followRequests.WithLabelValues(outcome).Inc()
+}
+
+func (metrics *DomainMetrics) Follow(action string) {
+	if metrics == nil {
+		return
+	}
+	metrics.follows.WithLabelValues(action).Inc()
+}
+
+func (metrics *DomainMetrics) Block(action string) {
+	if metrics == nil {
+		return
+	}
+	metrics.blocks.WithLabelValues(action).Inc()
+}
+
+func (metrics *DomainMetrics) FollowRateLimited(action string, reason string) {
+	if metrics == nil {
+		return
+	}
+	metrics.followRateLimited.WithLabelValues(action, reason).Inc()
+}
+
+func (metrics *DomainMetrics) NotificationPublished(notificationType string, outcome string) {
+	if metrics == nil {
+		return
+	}
+	metrics.notifications.WithLabelValues(notificationType, outcome).Inc()
+}
+
+func (metrics *DomainMetrics) Auth0Call(operation string, duration time.Duration, err error) {
+	if metrics == nil {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.auth0Requests.WithLabelValues(operation, outcome).Inc()
+	metrics.auth0RequestDurations.WithLabelValues(operation).Observe(duration.Seconds())
+}
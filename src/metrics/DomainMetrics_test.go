@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DomainMetricsTestsSuite struct {
+	suite.Suite
+}
+
+func TestDomainMetricsTestsSuite(t *testing.T) {
+	suite.Run(t, new(DomainMetricsTestsSuite))
+}
+
+func (suite *DomainMetricsTestsSuite) TestNilMetrics_RecordNothing() {
+	var metrics *DomainMetrics
+
+	assert.NotPanics(suite.T(), func() {
+		metrics.Registration(RegistrationSucceeded)
+		metrics.FollowRequest(RequestCreated)
+		metrics.Follow(Followed)
+		metrics.Block(Blocked)
+		metrics.FollowRateLimited("follow", "hourly")
+		metrics.NotificationPublished("dislinkt.users.notification.follow", Published)
+		metrics.Auth0Call("register", time.Second, nil)
+	})
+}
+
+func (suite *DomainMetricsTestsSuite) TestRecord() {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewDomainMetrics(registry)
+	assert.Nil(suite.T(), err)
+
+	metrics.Registration(RegistrationSucceeded)
+	metrics.Registration("invalid_password")
+	metrics.Registration(RegistrationSucceeded)
+	metrics.Follow(Followed)
+	metrics.Follow(Unfollowed)
+	metrics.Auth0Call("register", 300*time.Millisecond, nil)
+	metrics.Auth0Call("register", time.Second, errors.New("timeout"))
+
+	assert.Equal(suite.T(), 2.0, testutil.ToFloat64(metrics.registrations.WithLabelValues(RegistrationSucceeded)))
+	assert.Equal(suite.T(), 1.0, testutil.ToFloat64(metrics.registrations.WithLabelValues("invalid_password")))
+	assert.Equal(suite.T(), 1.0, testutil.ToFloat64(metrics.follows.WithLabelValues(Unfollowed)))
+
+	expected := `
+# HELP auth0_requests_total Calls to the Auth0 management API, by operation and outcome.
+# TYPE auth0_requests_total counter
+auth0_requests_total{operation="register",outcome="error"} 1
+auth0_requests_total{operation="register",outcome="success"} 1
+`
+	assert.Nil(suite.T(), testutil.GatherAndCompare(registry, strings.NewReader(expected), "auth0_requests_total"))
+}
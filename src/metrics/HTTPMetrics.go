@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UnmatchedRoute labels requests no route matched, so unknown URLs don't
+// create new series.
+const UnmatchedRoute = "unmatched"
+
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// HTTPMetrics instruments the router. Series are labeled by route template
+// (/users/:id, never the request URI), method and status class, and unique
+// clients are estimated with a HyperLogLog instead of being labels.
+type HTTPMetrics struct {
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	requestSize   *prometheus.HistogramVec
+	responseSize  *prometheus.HistogramVec
+	inFlight      prometheus.Gauge
+	uniqueClients *UniqueClients
+}
+
+func NewHTTPMetrics(registerer prometheus.Registerer, uniqueClients *UniqueClients) (*HTTPMetrics, error) {
+	labels := []string{"method", "route", "status_class"}
+	sizeBuckets := prometheus.ExponentialBuckets(64, 4, 8)
+
+	metrics := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests handled, by route template, method and status class.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time spent handling HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_size_bytes",
+			Help:    "Size of HTTP request bodies.",
+			Buckets: sizeBuckets,
+		}, labels),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies.",
+			Buckets: sizeBuckets,
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being handled.",
+		}),
+		uniqueClients: uniqueClients,
+	}
+
+	collectors := []prometheus.Collector{metrics.requests, metrics.duration, metrics.requestSize, metrics.responseSize, metrics.inFlight}
+	if uniqueClients != nil {
+		collectors = append(collectors, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "http_unique_clients",
+			Help: "Estimated number of distinct clients (IP and user agent) in the current window.",
+		}, uniqueClients.Estimate))
+	}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return metrics, nil
+}
+
+func (metrics *HTTPMetrics) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		metrics.inFlight.Inc()
+		defer metrics.inFlight.Dec()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		method := ctx.Request.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+		labels := prometheus.Labels{"method": method, "route": route, "status_class": StatusClass(ctx.Writer.Status())}
+
+		metrics.requests.With(labels).Inc()
+		metrics.duration.With(labels).Observe(time.Since(start).Seconds())
+		if ctx.Request.ContentLength >= 0 {
+			metrics.requestSize.With(labels).Observe(float64(ctx.Request.ContentLength))
+		}
+		if size := ctx.Writer.Size(); size >= 0 {
+			metrics.responseSize.With(labels).Observe(float64(size))
+		}
+		if metrics.uniqueClients != nil {
+			metrics.uniqueClients.Observe(ctx.ClientIP() + "|" + ctx.Request.UserAgent())
+		}
+	}
+}
+
+// StatusClass maps a status code to its class, e.g. 404 to "4xx".
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPMetricsTestsSuite struct {
+	suite.Suite
+	registry *prometheus.Registry
+	router   *gin.Engine
+}
+
+func TestHTTPMetricsTestsSuite(t *testing.T) {
+	suite.Run(t, new(HTTPMetricsTestsSuite))
+}
+
+func (suite *HTTPMetricsTestsSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.registry = prometheus.NewRegistry()
+	clients, _ := NewUniqueClients(10, time.Hour)
+	metrics, err := NewHTTPMetrics(suite.registry, clients)
+	assert.Nil(suite.T(), err)
+
+	suite.router = gin.New()
+	suite.router.Use(metrics.Middleware())
+	suite.router.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "user")
+	})
+}
+
+func (suite *HTTPMetricsTestsSuite) request(method string, url string, userAgent string) {
+	req := httptest.NewRequest(method, url, nil)
+	req.Header.Set("User-Agent", userAgent)
+	suite.router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func (suite *HTTPMetricsTestsSuite) TestMiddleware_LabelsByRouteTemplate() {
+	suite.request(http.MethodGet, "/users/1?fields=all", "curl")
+	suite.request(http.MethodGet, "/users/2", "curl")
+	suite.request(http.MethodGet, "/users/3", "firefox")
+	suite.request(http.MethodGet, "/no/such/page", "curl")
+	suite.request("PROPFIND", "/users/4", "curl")
+
+	expected := `
+# HELP http_requests_total HTTP requests handled, by route template, method and status class.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/users/:id",status_class="2xx"} 3
+http_requests_total{method="GET",route="unmatched",status_class="4xx"} 1
+http_requests_total{method="OTHER",route="unmatched",status_class="4xx"} 1
+# HELP http_unique_clients Estimated number of distinct clients (IP and user agent) in the current window.
+# TYPE http_unique_clients gauge
+http_unique_clients 2
+`
+	err := testutil.GatherAndCompare(suite.registry, strings.NewReader(expected), "http_requests_total", "http_unique_clients")
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HTTPMetricsTestsSuite) TestNewHTTPMetrics_AlreadyRegistered() {
+	_, err := NewHTTPMetrics(suite.registry, nil)
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *HTTPMetricsTestsSuite) TestStatusClass() {
+	assert.Equal(suite.T(), "2xx", StatusClass(204))
+	assert.Equal(suite.T(), "5xx", StatusClass(503))
+	assert.Equal(suite.T(), "unknown", StatusClass(0))
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+const (
+	MinPrecision     = 4
+	MaxPrecision     = 16
+	DefaultPrecision = 14
+)
+
+// HyperLogLog estimates the number of distinct values it was given in a fixed
+// 2^Precision bytes. The standard error is about 1.04/sqrt(2^Precision), 0.8%
+// with the default precision of 14.
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+func NewHyperLogLog(precision int) (*HyperLogLog, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, fmt.Errorf("HyperLogLog precision must be between %d and %d", MinPrecision, MaxPrecision)
+	}
+	return &HyperLogLog{precision: uint8(precision), registers: make([]uint8, 1<<precision)}, nil
+}
+
+func (hll *HyperLogLog) Add(value string) {
+	hash := hashString(value)
+	index := hash >> (64 - hll.precision)
+	// The sentinel bit caps the rank when the remaining bits are all zero.
+	rest := hash<<hll.precision | 1<<(hll.precision-1)
+	rank := uint8(bits.LeadingZeros64(rest) + 1)
+	if rank > hll.registers[index] {
+		hll.registers[index] = rank
+	}
+}
+
+func (hll *HyperLogLog) Count() uint64 {
+	m := float64(len(hll.registers))
+
+	sum := 0.0
+	zeros := 0
+	for _, register := range hll.registers {
+		sum += 1 / float64(uint64(1)<<register)
+		if register == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(len(hll.registers)) * m * m / sum
+	// Small cardinalities are estimated better by linear counting. 64-bit
+	// hashes make the large range correction unnecessary.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+func (hll *HyperLogLog) Reset() {
+	for i := range hll.registers {
+		hll.registers[i] = 0
+	}
+}
+
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/float64(m))
+	}
+}
+
+// hashString is FNV-1a followed by the murmur3 finalizer, which spreads
+// FNV's weak high bits over the whole word.
+func hashString(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	hash := h.Sum64()
+
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// UniqueClients counts distinct clients per Window. The sketch is cleared
+// when a window ends, so the estimate covers the current window only.
+type UniqueClients struct {
+	Window time.Duration
+
+	mutex       sync.Mutex
+	sketch      *HyperLogLog
+	windowStart time.Time
+	now         func() time.Time
+}
+
+func NewUniqueClients(precision int, window time.Duration) (*UniqueClients, error) {
+	sketch, err := NewHyperLogLog(precision)
+	if err != nil {
+		return nil, err
+	}
+	return &UniqueClients{Window: window, sketch: sketch, windowStart: time.Now(), now: time.Now}, nil
+}
+
+func (clients *UniqueClients) Observe(client string) {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+
+	clients.rotate()
+	clients.sketch.Add(client)
+}
+
+func (clients *UniqueClients) Estimate() float64 {
+	clients.mutex.Lock()
+	defer clients.mutex.Unlock()
+
+	clients.rotate()
+	return float64(clients.sketch.Count())
+}
+
+func (clients *UniqueClients) rotate() {
+	now := clients.now()
+	if clients.Window <= 0 || now.Sub(clients.windowStart) < clients.Window {
+		return
+	}
+	clients.sketch.Reset()
+	clients.windowStart = now
+}
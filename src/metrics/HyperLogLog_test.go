@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type HyperLogLogTestsSuite struct {
+	suite.Suite
+}
+
+func TestHyperLogLogTestsSuite(t *testing.T) {
+	suite.Run(t, new(HyperLogLogTestsSuite))
+}
+
+func (suite *HyperLogLogTestsSuite) TestCount_Accuracy() {
+	hll, err := NewHyperLogLog(14)
+	assert.Nil(suite.T(), err)
+
+	for _, distinct := range []int{10, 1000, 100000} {
+		hll.Reset()
+		for i := 0; i < distinct; i++ {
+			hll.Add(fmt.Sprintf("10.0.%d.%d|Mozilla/5.0", i/256, i%256))
+			hll.Add(fmt.Sprintf("10.0.%d.%d|Mozilla/5.0", i/256, i%256))
+		}
+		assert.InEpsilon(suite.T(), float64(distinct), float64(hll.Count()), 0.03, "%d distinct values", distinct)
+	}
+}
+
+func (suite *HyperLogLogTestsSuite) TestNewHyperLogLog_InvalidPrecision() {
+	_, err := NewHyperLogLog(3)
+	assert.NotNil(suite.T(), err)
+
+	_, err = NewHyperLogLog(17)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *HyperLogLogTestsSuite) TestUniqueClients_Window() {
+	clients, _ := NewUniqueClients(10, time.Hour)
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	clients.windowStart = now
+	clients.now = func() time.Time { return now }
+
+	clients.Observe("a")
+	clients.Observe("b")
+	clients.Observe("a")
+	assert.Equal(suite.T(), 2.0, clients.Estimate())
+
+	now = now.Add(time.Hour)
+	assert.Equal(suite.T(), 0.0, clients.Estimate())
+	clients.Observe("c")
+	assert.Equal(suite.T(), 1.0, clients.Estimate())
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/go-playground/validator"
+)
+
+type Connection struct {
+	ID            int           `json:"id"`
+	InviterId     int           `json:"inviter_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
+	InviteeId     int           `json:"invitee_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
+	RequestStatus RequestStatus `json:"request_status"`
+}
+
+func (c *Connection) Validate() error {
+	validate := validator.New()
+	return validate.Struct(c)
+}
+
+func (c *Connection) FromJSON(r io.Reader) error {
+	e := json.NewDecoder(r)
+	return e.Decode(c)
+}
+
+func (c *Connection) ToJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	return e.Encode(c)
+}
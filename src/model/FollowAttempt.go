@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// FollowAttempt records a follow a user started, for the follow rate limiter.
+// Unlike the followers row it outlives an unfollow, so following and
+// unfollowing in a loop still uses up the quota.
+type FollowAttempt struct {
+	ID        int       `json:"id"`
+	UserId    int       `json:"user_id" gorm:"index"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
@@ -3,14 +3,19 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"time"
 
 	"github.com/go-playground/validator"
 )
 
 type Follower struct {
-	ID          int `json:"id"`
-	FollowerId  int `json:"followers_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
-	FollowingId int `json:"following_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
+	ID          int        `json:"id"`
+	FollowerId  int        `json:"followers_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
+	FollowingId int        `json:"following_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
+	ActorId     int        `json:"actor_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DecidedAt   *time.Time `json:"decided_at"`
 }
 
 func (f *Follower) Validate() error {
@@ -3,6 +3,7 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"time"
 
 	"github.com/go-playground/validator"
 )
@@ -13,13 +14,29 @@ const (
 	PENDING RequestStatus = iota
 	ACCEPTED
 	REJECTED
+	EXPIRED
 )
 
+var requestStatusNames = map[RequestStatus]string{
+	PENDING:  "PENDING",
+	ACCEPTED: "ACCEPTED",
+	REJECTED: "REJECTED",
+	EXPIRED:  "EXPIRED",
+}
+
+func (s RequestStatus) String() string {
+	return requestStatusNames[s]
+}
+
 type FollowingRequest struct {
 	ID            int           `json:"id" `
 	FollowerId    int           `json:"followers_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
 	FollowingId   int           `json:"following_id" gorm:"TYPE:integer REFERENCES users" validate:"required"`
 	RequestStatus RequestStatus `json:"request_status" validate:"required"`
+	ActorId       int           `json:"actor_id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	DecidedAt     *time.Time    `json:"decided_at"`
 }
 
 func (f *FollowingRequest) Validate() error {
@@ -0,0 +1,40 @@
+package model
+
+type NotificationChannel string
+
+const (
+	InAppChannel NotificationChannel = "in_app"
+	EmailChannel NotificationChannel = "email"
+	PushChannel  NotificationChannel = "push"
+)
+
+type DigestFrequency string
+
+const (
+	InstantDigest DigestFrequency = "instant"
+	DailyDigest   DigestFrequency = "daily"
+	WeeklyDigest  DigestFrequency = "weekly"
+)
+
+// NotificationPreference is one row of a user's preference matrix: the
+// channels a notification type is delivered through and how often emails
+// about it are sent. NotificationType holds the lower case type name.
+type NotificationPreference struct {
+	ID               int             `json:"id"`
+	UserId           int             `json:"user_id" gorm:"unique_index:idx_notification_preferences_user_type"`
+	NotificationType string          `json:"notification_type" gorm:"unique_index:idx_notification_preferences_user_type"`
+	InApp            bool            `json:"in_app"`
+	Email            bool            `json:"email"`
+	Push             bool            `json:"push"`
+	Digest           DigestFrequency `json:"digest"`
+}
+
+// NotificationSchedule holds a user's quiet hours, as "15:04" in Timezone.
+// Quiet hours may wrap around midnight; empty ones mean there are none.
+type NotificationSchedule struct {
+	ID              int    `json:"id"`
+	UserId          int    `json:"user_id" gorm:"unique_index"`
+	QuietHoursStart string `json:"quiet_hours_start"`
+	QuietHoursEnd   string `json:"quiet_hours_end"`
+	Timezone        string `json:"timezone"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// OutboxMessage is a message waiting to be published to RabbitMQ. It is
+// written in the same transaction as the change it describes and published
+// later by the outbox relay. Messages that keep failing are given up on and
+// get FailedAt set; they stay in the table for inspection.
+type OutboxMessage struct {
+	ID          int        `json:"id"`
+	MessageId   string     `json:"message_id" gorm:"unique_index"`
+	Exchange    string     `json:"exchange"`
+	RoutingKey  string     `json:"routing_key"`
+	Mandatory   bool       `json:"mandatory"`
+	ContentType string     `json:"content_type"`
+	Type        string     `json:"type"`
+	Headers     string     `json:"headers" gorm:"type:text"`
+	Body        string     `json:"body" gorm:"type:text"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at" gorm:"index"`
+	FailedAt    *time.Time `json:"failed_at" gorm:"index"`
+}
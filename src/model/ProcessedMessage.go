@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// ProcessedMessage records a message consumed from RabbitMQ. It is written in
+// the same transaction as the changes the message caused, so a redelivered
+// message is recognised and skipped.
+type ProcessedMessage struct {
+	ID          int       `json:"id"`
+	MessageId   string    `json:"message_id" gorm:"unique_index"`
+	Type        string    `json:"type"`
+	ProcessedAt time.Time `json:"processed_at" gorm:"index"`
+}
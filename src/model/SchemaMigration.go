@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// SchemaMigration records a data migration that has been applied, so it runs
+// only once per database.
+type SchemaMigration struct {
+	Name      string    `json:"name" gorm:"primary_key"`
+	AppliedAt time.Time `json:"applied_at"`
+}
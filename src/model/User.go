@@ -3,6 +3,7 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"time"
 
 	"github.com/go-playground/validator"
 )
@@ -15,28 +16,33 @@ const (
 )
 
 type User struct {
-	ID                   int     `json:"id"`
-	Auth0ID              string  `json:"auth0_id"`
-	FirstName            string  `json:"first_name" validate:"required"`
-	LastName             string  `json:"last_name" validate:"required"`
-	Email                string  `json:"email" validate:"required,email" gorm:"unique"`
-	Password             string  `json:"password" validate:"required"`
-	PhoneNumber          string  `json:"phone_number"`
-	Gender               *Gender `json:"gender" validate:"required"`
-	Username             string  `json:"user_name" gorm:"unique" validate:"required"`
-	DateOfBirth          float32 `json:"date_od_birth"`
-	Biography            string  `json:"biography"`
-	Education            string  `json:"education"`
-	WorkExperience       string  `json:"work_experience"`
-	Skills               string  `json:"skills"`
-	Interests            string  `json:"interests"`
-	Active               bool    `json:"active"`
-	Public               bool    `json:"public"`
-	Blocked              []User  `json:"blocked" gorm:"many2many:user_blocked;association_jointable_foreignkey:blocked_id;"`
-	MessageNotifications bool    `json:"message_notifications"`
-	FollowNotifications  bool    `json:"follow_notifications"`
-	LikeNotifications    bool    `json:"like_notifications"`
-	CommentNotifications bool    `json:"comment_notifications"`
+	ID                   int        `json:"id"`
+	Auth0ID              string     `json:"auth0_id"`
+	FirstName            string     `json:"first_name" validate:"required"`
+	LastName             string     `json:"last_name" validate:"required"`
+	Email                string     `json:"email" validate:"required,email" gorm:"unique"`
+	Password             string     `json:"password" validate:"required"`
+	PhoneNumber          string     `json:"phone_number"`
+	Gender               *Gender    `json:"gender" validate:"required"`
+	Username             string     `json:"user_name" gorm:"unique" validate:"required"`
+	DateOfBirth          float32    `json:"date_od_birth"`
+	Biography            string     `json:"biography"`
+	Education            string     `json:"education"`
+	WorkExperience       string     `json:"work_experience"`
+	Skills               string     `json:"skills"`
+	Interests            string     `json:"interests"`
+	Active               bool       `json:"active"`
+	Public               bool       `json:"public"`
+	Blocked              []User     `json:"blocked" gorm:"many2many:user_blocked;association_jointable_foreignkey:blocked_id;"`
+	MessageNotifications bool       `json:"message_notifications"`
+	FollowNotifications  bool       `json:"follow_notifications"`
+	LikeNotifications    bool       `json:"like_notifications"`
+	CommentNotifications bool       `json:"comment_notifications"`
+	PreferredLanguage    string     `json:"preferred_language"`
+	Flagged              bool       `json:"flagged"`
+	ReportCount          int        `json:"report_count"`
+	PasswordChangedAt    *time.Time `json:"password_changed_at"`
+	CreatedAt            time.Time  `json:"created_at"`
 }
 
 func (u *User) Validate() error {
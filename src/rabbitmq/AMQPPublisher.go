@@ -0,0 +1,350 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+	// confirmBuffer is how many confirmations the library can hand over
+	// before the confirm tracker reads them.
+	confirmBuffer = 256
+)
+
+var (
+	ErrNoPublisher  = errors.New("rabbitmq publisher is not configured")
+	ErrBufferFull   = errors.New("rabbitmq publisher buffer is full")
+	ErrNotConfirmed = errors.New("rabbitmq broker did not confirm the message")
+	ErrClosed       = errors.New("rabbitmq publisher is closed")
+	ErrNotConnected = errors.New("rabbitmq publisher is not connected")
+	ErrUnroutable   = errors.New("rabbitmq broker could not route the message")
+)
+
+var unroutableMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rabbitmq_unroutable_messages_total",
+	Help: "Messages returned by the broker because no queue was bound for them.",
+}, []string{"exchange", "routing_key"})
+
+// Message is published to Exchange with RoutingKey. A Mandatory message no
+// queue is bound for is returned by the broker and reported as unroutable;
+// others are dropped silently, which is expected for domain events nobody
+// subscribed to yet.
+type Message struct {
+	Exchange   string
+	RoutingKey string
+	Mandatory  bool
+	Publishing amqp.Publishing
+}
+
+type PublisherStatus struct {
+	Connected bool
+	Buffered  int
+	LastError string
+}
+
+// AMQPPublisher publishes messages with publisher confirms. It keeps
+// reconnecting with exponential backoff while RabbitMQ is unreachable and
+// holds up to bufferSize messages in memory until the connection is back.
+// Topology, when set, is declared on every connect. Mandatory messages no
+// queue is bound for are returned and reported.
+// The mutex is only held while a message is handed to the channel; the wait
+// for its confirmation happens outside of it, so publishes overlap.
+type AMQPPublisher struct {
+	ConnectionString string
+	ConfirmTimeout   time.Duration
+	Topology         *Topology
+	Logger           *logrus.Entry
+
+	mutex       sync.Mutex
+	connection  *amqp.Connection
+	channel     *amqp.Channel
+	tracker     *confirmTracker
+	deliveryTag uint64
+	connected   bool
+	lastError   error
+	buffer      chan Message
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func NewAMQPPublisher(connectionString string, bufferSize int, confirmTimeout time.Duration, logger *logrus.Entry) *AMQPPublisher {
+	return &AMQPPublisher{
+		ConnectionString: connectionString,
+		ConfirmTimeout:   confirmTimeout,
+		Logger:           logger,
+		buffer:           make(chan Message, bufferSize),
+		done:             make(chan struct{}),
+	}
+}
+
+// Start connects in the background and keeps the connection alive until
+// Close is called.
+func (p *AMQPPublisher) Start() {
+	go p.run()
+}
+
+func (p *AMQPPublisher) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		if p.connection != nil {
+			p.connection.Close()
+		}
+		p.connected = false
+	})
+}
+
+// Publish sends msg and waits for the broker to confirm it. While the broker
+// is unreachable the message is buffered and nil is returned; it is sent as
+// soon as the connection is restored. ErrBufferFull is returned when the
+// buffer can't take any more messages.
+func (p *AMQPPublisher) Publish(msg Message) error {
+	if p == nil {
+		return ErrNoPublisher
+	}
+
+	return p.publish(msg, true)
+}
+
+// PublishConfirmed is Publish without the buffer: it returns nil only once
+// the broker has confirmed msg, and ErrNotConnected while RabbitMQ is down.
+// Callers that keep their own durable copy, like the outbox relay, use it.
+func (p *AMQPPublisher) PublishConfirmed(msg Message) error {
+	if p == nil {
+		return ErrNoPublisher
+	}
+
+	return p.publish(msg, false)
+}
+
+func (p *AMQPPublisher) Status() PublisherStatus {
+	if p == nil {
+		return PublisherStatus{LastError: ErrNoPublisher.Error()}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	status := PublisherStatus{Connected: p.connected, Buffered: len(p.buffer)}
+	if p.lastError != nil {
+		status.LastError = p.lastError.Error()
+	}
+	return status
+}
+
+func (p *AMQPPublisher) run() {
+	delay := minReconnectDelay
+	for {
+		closed, err := p.connect()
+		if err != nil {
+			p.disconnected(err)
+			p.Logger.Error(fmt.Sprintf("Can't connect to RabbitMQ, retrying in %s: %s", delay, err.Error()))
+
+			select {
+			case <-time.After(delay):
+			case <-p.done:
+				return
+			}
+
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+
+		delay = minReconnectDelay
+		p.Logger.Info("Connected to RabbitMQ")
+		p.flush()
+
+		select {
+		case amqpErr := <-closed:
+			if amqpErr == nil {
+				p.disconnected(errors.New("connection closed"))
+			} else {
+				p.disconnected(amqpErr)
+			}
+			p.Logger.Error("Lost connection to RabbitMQ, reconnecting")
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// connect opens a connection and a channel in confirm mode. The returned
+// channel is notified when either of them is closed.
+func (p *AMQPPublisher) connect() (chan *amqp.Error, error) {
+	connection, err := amqp.Dial(p.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	p.declareTopology(connection)
+
+	channel, err := connection.Channel()
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	closed := make(chan *amqp.Error, 2)
+	connection.NotifyClose(closed)
+	channel.NotifyClose(closed)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.connection = connection
+	p.channel = channel
+	p.tracker = newConfirmTracker(p.unroutable)
+	go p.tracker.run(channel.NotifyPublish(make(chan amqp.Confirmation, confirmBuffer)), channel.NotifyReturn(make(chan amqp.Return, 16)))
+	p.deliveryTag = 0
+	p.connected = true
+	p.lastError = nil
+
+	return closed, nil
+}
+
+// declareTopology logs the declarations that fail instead of giving up on
+// the connection: a queue that exists with other arguments shouldn't stop
+// the service from publishing.
+func (p *AMQPPublisher) declareTopology(connection *amqp.Connection) {
+	if p.Topology == nil {
+		return
+	}
+
+	for _, err := range p.Topology.DeclareAll(connection) {
+		p.Logger.Warn(fmt.Sprintf("Can't declare RabbitMQ topology: %s", err.Error()))
+	}
+}
+
+func (p *AMQPPublisher) disconnected(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.connection != nil {
+		p.connection.Close()
+	}
+	p.connection = nil
+	p.channel = nil
+	p.connected = false
+	p.lastError = err
+}
+
+// flush resends buffered messages after a reconnect.
+func (p *AMQPPublisher) flush() {
+	for p.Status().Connected {
+		select {
+		case msg := <-p.buffer:
+			if err := p.publish(msg, true); err != nil {
+				p.Logger.Error(fmt.Sprintf("Dropping buffered message %s: %s", msg.Publishing.MessageId, err.Error()))
+			}
+		default:
+			return
+		}
+	}
+}
+
+// publish sends msg and waits for its confirmation. When buffer is false,
+// messages that can't be sent are returned as ErrNotConnected instead of
+// buffered.
+func (p *AMQPPublisher) publish(msg Message, buffer bool) error {
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+	}
+
+	p.mutex.Lock()
+	if !p.connected {
+		p.mutex.Unlock()
+		return p.unsent(msg, buffer)
+	}
+
+	// The confirmation can arrive as soon as the message is sent, so the
+	// wait is registered first.
+	tracker := p.tracker
+	tag := p.deliveryTag + 1
+	result, ok := tracker.expect(tag, msg.Publishing.MessageId)
+	if !ok {
+		p.mutex.Unlock()
+		return p.unsent(msg, buffer)
+	}
+	if err := p.channel.Publish(msg.Exchange, msg.RoutingKey, msg.Mandatory, false, msg.Publishing); err != nil {
+		tracker.forget(tag)
+		p.connected = false
+		p.lastError = err
+		p.mutex.Unlock()
+		return p.unsent(msg, buffer)
+	}
+	p.deliveryTag = tag
+	p.mutex.Unlock()
+
+	timeout := time.NewTimer(p.ConfirmTimeout)
+	defer timeout.Stop()
+
+	select {
+	case outcome := <-result:
+		switch {
+		case outcome.closed:
+			p.mutex.Lock()
+			if p.tracker == tracker {
+				p.connected = false
+				p.lastError = errors.New("channel closed before the message was confirmed")
+			}
+			p.mutex.Unlock()
+			return p.unsent(msg, buffer)
+		case !outcome.ack:
+			return ErrNotConfirmed
+		case outcome.returned:
+			return ErrUnroutable
+		}
+		return nil
+	case <-timeout.C:
+		// A late confirmation finds the buffered result channel and is
+		// dropped with it.
+		return ErrNotConfirmed
+	}
+}
+
+func (p *AMQPPublisher) unroutable(ret amqp.Return) {
+	unroutableMessages.WithLabelValues(ret.Exchange, ret.RoutingKey).Inc()
+	p.Logger.Error(fmt.Sprintf("RabbitMQ returned message %s published to %s with routing key %s: %s", ret.MessageId, ret.Exchange, ret.RoutingKey, ret.ReplyText))
+}
+
+func (p *AMQPPublisher) unsent(msg Message, buffer bool) error {
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+	}
+	if !buffer {
+		return ErrNotConnected
+	}
+	return p.enqueue(msg)
+}
+
+func (p *AMQPPublisher) enqueue(msg Message) error {
+	select {
+	case p.buffer <- msg:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
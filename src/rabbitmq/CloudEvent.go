@@ -0,0 +1,113 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/streadway/amqp"
+	"github.com/uber/jaeger-client-go"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	CloudEventsContentType = "application/cloudevents+json"
+)
+
+// EventSource identifies users-ms as the producer of its events.
+var EventSource = "/dislinkt/users-ms"
+
+// CloudEvent is a CloudEvents 1.0 event in structured JSON mode. Every message
+// users-ms publishes is wrapped in one.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema"`
+	TraceParent     string          `json:"traceparent,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// NewCloudEvent validates data against the registered schema of eventType and
+// wraps it in an envelope. The trace context of the span in ctx, if any, is
+// added as the traceparent extension.
+func NewCloudEvent(ctx context.Context, eventType string, subject string, data interface{}) (*CloudEvent, error) {
+	schema, ok := LookupSchema(eventType)
+	if !ok {
+		return nil, fmt.Errorf("no schema registered for event type %s", eventType)
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.Validate(payload); err != nil {
+		return nil, err
+	}
+
+	uuid, _ := uuid.NewV4()
+	return &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Id:              uuid.String(),
+		Source:          EventSource,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		DataSchema:      schema.DataSchema(),
+		TraceParent:     traceParent(ctx),
+		Data:            payload,
+	}, nil
+}
+
+// Message wraps the event into a persistent message for exchange and
+// routingKey.
+func (event *CloudEvent) Message(exchange string, routingKey string) (Message, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Exchange:   exchange,
+		RoutingKey: routingKey,
+		Publishing: amqp.Publishing{
+			Headers:      amqp.Table{"cloudEvents:type": event.Type, "cloudEvents:dataschema": event.DataSchema},
+			ContentType:  CloudEventsContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    event.Id,
+			Timestamp:    event.Time,
+			Type:         event.Type,
+			Body:         body,
+		},
+	}, nil
+}
+
+// traceParent renders the span in ctx as a W3C traceparent value.
+func traceParent(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	spanContext, ok := span.Context().(jaeger.SpanContext)
+	if !ok || !spanContext.IsValid() {
+		return ""
+	}
+
+	flags := 0
+	if spanContext.IsSampled() {
+		flags = 1
+	}
+	traceID := spanContext.TraceID()
+	return fmt.Sprintf("00-%016x%016x-%016x-%02x", traceID.High, traceID.Low, uint64(spanContext.SpanID()), flags)
+}
@@ -0,0 +1,102 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"user-ms/src/dto"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber/jaeger-client-go"
+)
+
+type CloudEventTestsSuite struct {
+	suite.Suite
+}
+
+func TestCloudEventTestsSuite(t *testing.T) {
+	suite.Run(t, new(CloudEventTestsSuite))
+}
+
+func (suite *CloudEventTestsSuite) TestNewDomainEventMessage() {
+	msg, err := NewDomainEventMessage(context.Background(), FollowCreated, &dto.FollowEventDTO{FollowerId: 1, FollowingId: 2, ActorId: 1})
+	assert.Nil(suite.T(), err)
+
+	assert.Equal(suite.T(), DomainEventsExchange, msg.Exchange)
+	assert.Equal(suite.T(), "follow.created.v1", msg.RoutingKey)
+	assert.Equal(suite.T(), CloudEventsContentType, msg.Publishing.ContentType)
+	assert.False(suite.T(), msg.Mandatory)
+
+	var event CloudEvent
+	assert.Nil(suite.T(), json.Unmarshal(msg.Publishing.Body, &event))
+	assert.Equal(suite.T(), "1.0", event.SpecVersion)
+	assert.Equal(suite.T(), "dislinkt.users.follow.created", event.Type)
+	assert.Equal(suite.T(), "urn:dislinkt:schema:dislinkt.users.follow.created:v1", event.DataSchema)
+	assert.Equal(suite.T(), msg.Publishing.MessageId, event.Id)
+	assert.Empty(suite.T(), event.TraceParent)
+}
+
+func (suite *CloudEventTestsSuite) TestNewDomainEventMessage_InvalidPayload() {
+	_, err := NewDomainEventMessage(context.Background(), FollowCreated, map[string]int{"FollowerId": 1})
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *CloudEventTestsSuite) TestNewNotificationMessage() {
+	followType := dto.Follow
+	notification := dto.NotificationDTO{Message: "username started following you.", UserAuth0ID: "auth0|1", NotificationType: &followType}
+
+	msg, err := NewNotificationMessage(context.Background(), &notification)
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), msg.Mandatory)
+
+	row, err := msg.ToOutbox()
+	assert.Nil(suite.T(), err)
+	restored, err := MessageFromOutbox(row)
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), restored.Mandatory)
+
+	var event CloudEvent
+	assert.Nil(suite.T(), json.Unmarshal(msg.Publishing.Body, &event))
+	assert.Equal(suite.T(), "dislinkt.users.notification.follow", event.Type)
+	assert.Equal(suite.T(), "auth0|1", event.Subject)
+
+	_, err = NewNotificationMessage(context.Background(), &dto.NotificationDTO{})
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *CloudEventTestsSuite) TestTraceParent() {
+	tracer, closer := jaeger.NewTracer("users-ms", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+
+	span := tracer.StartSpan("test")
+	defer span.Finish()
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+
+	spanContext := span.Context().(jaeger.SpanContext)
+	expected := fmt.Sprintf("00-%032x-%016x-01", spanContext.TraceID().Low, uint64(spanContext.SpanID()))
+
+	assert.Equal(suite.T(), expected, traceParent(ctx))
+}
+
+func (suite *CloudEventTestsSuite) TestTraceHeaders() {
+	tracer, closer := jaeger.NewTracer("users-ms", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	span := tracer.StartSpan("test")
+	defer span.Finish()
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+
+	msg, err := NewDomainEventMessage(ctx, FollowCreated, &dto.FollowEventDTO{FollowerId: 1, FollowingId: 2, ActorId: 1})
+	assert.Nil(suite.T(), err)
+
+	extracted, err := ExtractTrace(msg.Publishing.Headers)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), span.Context().(jaeger.SpanContext).TraceID(), extracted.(jaeger.SpanContext).TraceID())
+	assert.Equal(suite.T(), span.Context().(jaeger.SpanContext).SpanID(), extracted.(jaeger.SpanContext).SpanID())
+}
@@ -0,0 +1,127 @@
+package rabbitmq
+
+import (
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+type confirmOutcome struct {
+	ack      bool
+	returned bool
+	closed   bool
+}
+
+type pendingConfirm struct {
+	messageId string
+	result    chan confirmOutcome
+}
+
+// confirmTracker hands the confirmations of one channel to the publishes
+// waiting for them, matched by delivery tag. A message returned by the
+// broker is matched by its MessageId.
+type confirmTracker struct {
+	mutex      sync.Mutex
+	pending    map[uint64]pendingConfirm
+	closed     bool
+	returned   map[string]bool
+	unroutable func(amqp.Return)
+}
+
+func newConfirmTracker(unroutable func(amqp.Return)) *confirmTracker {
+	return &confirmTracker{
+		pending:    map[uint64]pendingConfirm{},
+		returned:   map[string]bool{},
+		unroutable: unroutable,
+	}
+}
+
+// expect registers a wait for the confirmation of tag. It returns false once
+// the channel is closed.
+func (t *confirmTracker) expect(tag uint64, messageId string) (<-chan confirmOutcome, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed {
+		return nil, false
+	}
+	result := make(chan confirmOutcome, 1)
+	t.pending[tag] = pendingConfirm{messageId, result}
+	return result, true
+}
+
+// forget drops the wait for a message that couldn't be sent.
+func (t *confirmTracker) forget(tag uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.pending, tag)
+}
+
+// run reads confirmations and returns until the channel is closed, then
+// tells the publishes still waiting.
+func (t *confirmTracker) run(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			t.markReturned(ret)
+		case confirmation, ok := <-confirms:
+			if !ok {
+				t.close()
+				return
+			}
+			// The broker sends basic.return before the confirmation of the
+			// same message, but select may pick the confirmation first.
+			t.drainReturns(returns)
+			t.confirm(confirmation)
+		}
+	}
+}
+
+func (t *confirmTracker) markReturned(ret amqp.Return) {
+	t.unroutable(ret)
+	t.returned[ret.MessageId] = true
+}
+
+func (t *confirmTracker) drainReturns(returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				return
+			}
+			t.markReturned(ret)
+		default:
+			return
+		}
+	}
+}
+
+func (t *confirmTracker) confirm(confirmation amqp.Confirmation) {
+	t.mutex.Lock()
+	pending, ok := t.pending[confirmation.DeliveryTag]
+	delete(t.pending, confirmation.DeliveryTag)
+	t.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	returned := t.returned[pending.messageId]
+	delete(t.returned, pending.messageId)
+	pending.result <- confirmOutcome{ack: confirmation.Ack, returned: returned}
+}
+
+func (t *confirmTracker) close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.closed = true
+	for tag, pending := range t.pending {
+		pending.result <- confirmOutcome{closed: true}
+		delete(t.pending, tag)
+	}
+}
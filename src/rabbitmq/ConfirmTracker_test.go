@@ -0,0 +1,75 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfirmTrackerTestsSuite struct {
+	suite.Suite
+}
+
+func TestConfirmTrackerTestsSuite(t *testing.T) {
+	suite.Run(t, new(ConfirmTrackerTestsSuite))
+}
+
+func (suite *ConfirmTrackerTestsSuite) outcome(result <-chan confirmOutcome) confirmOutcome {
+	select {
+	case outcome := <-result:
+		return outcome
+	case <-time.After(time.Second):
+		suite.T().Fatal("no confirmation")
+		return confirmOutcome{}
+	}
+}
+
+func (suite *ConfirmTrackerTestsSuite) TestRun_MatchesConfirmsByDeliveryTag() {
+	tracker := newConfirmTracker(func(amqp.Return) {})
+	confirms := make(chan amqp.Confirmation, 2)
+	returns := make(chan amqp.Return)
+	go tracker.run(confirms, returns)
+
+	first, _ := tracker.expect(1, "first")
+	second, _ := tracker.expect(2, "second")
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+
+	assert.Equal(suite.T(), confirmOutcome{ack: false}, suite.outcome(second))
+	assert.Equal(suite.T(), confirmOutcome{ack: true}, suite.outcome(first))
+	close(confirms)
+}
+
+func (suite *ConfirmTrackerTestsSuite) TestRun_ReturnReadAfterTheConfirm() {
+	var unroutable []string
+	tracker := newConfirmTracker(func(ret amqp.Return) { unroutable = append(unroutable, ret.MessageId) })
+	confirms := make(chan amqp.Confirmation, 1)
+	returns := make(chan amqp.Return, 2)
+	returns <- amqp.Return{MessageId: "other"}
+	returns <- amqp.Return{MessageId: "published"}
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+
+	result, _ := tracker.expect(1, "published")
+	tracker.drainReturns(returns)
+	tracker.confirm(<-confirms)
+
+	assert.Equal(suite.T(), confirmOutcome{ack: true, returned: true}, suite.outcome(result))
+	assert.Equal(suite.T(), []string{"other", "published"}, unroutable)
+}
+
+func (suite *ConfirmTrackerTestsSuite) TestRun_ChannelClosed() {
+	tracker := newConfirmTracker(func(amqp.Return) {})
+	confirms := make(chan amqp.Confirmation)
+	returns := make(chan amqp.Return)
+	result, _ := tracker.expect(1, "published")
+	go tracker.run(confirms, returns)
+
+	close(confirms)
+
+	assert.True(suite.T(), suite.outcome(result).closed)
+	_, ok := tracker.expect(2, "late")
+	assert.False(suite.T(), ok)
+}
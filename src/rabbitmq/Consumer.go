@@ -0,0 +1,399 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"user-ms/src/requestid"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+const (
+	retryCountHeader         = "x-retry-count"
+	originalExchangeHeader   = "x-original-exchange"
+	originalRoutingKeyHeader = "x-original-routing-key"
+)
+
+// ErrPermanent marks errors retrying won't fix, like a malformed payload.
+// Deliveries failing with it are dead-lettered right away.
+var ErrPermanent = errors.New("message can't be processed")
+
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %s", ErrPermanent, err.Error())
+}
+
+// Delivery is a consumed message. For CloudEvents, Type, MessageId and Data
+// come from the envelope; otherwise from the AMQP properties and the body.
+// Context carries the span the consumer started for it and the request ID
+// the producer sent.
+type Delivery struct {
+	Context    context.Context
+	MessageId  string
+	Type       string
+	Exchange   string
+	RoutingKey string
+	Data       []byte
+	Headers    amqp.Table
+	Attempt    int
+}
+
+type DeliveryHandler func(Delivery) error
+
+func NewDelivery(d amqp.Delivery) Delivery {
+	delivery := Delivery{
+		Context:    requestid.NewContext(context.Background(), headerString(d.Headers, requestid.AMQPHeader)),
+		MessageId:  d.MessageId,
+		Type:       d.Type,
+		Exchange:   d.Exchange,
+		RoutingKey: d.RoutingKey,
+		Data:       d.Body,
+		Headers:    d.Headers,
+		Attempt:    headerInt(d.Headers, retryCountHeader),
+	}
+
+	// Retried messages come back through the retry exchange; the headers
+	// keep where they were first published.
+	if exchange, ok := d.Headers[originalExchangeHeader].(string); ok {
+		delivery.Exchange = exchange
+	}
+	if routingKey, ok := d.Headers[originalRoutingKeyHeader].(string); ok {
+		delivery.RoutingKey = routingKey
+	}
+
+	if d.ContentType == CloudEventsContentType {
+		var event CloudEvent
+		if err := json.Unmarshal(d.Body, &event); err == nil {
+			delivery.MessageId = event.Id
+			delivery.Type = event.Type
+			delivery.Data = event.Data
+		}
+	}
+
+	if delivery.Type == "" {
+		delivery.Type = delivery.RoutingKey
+	}
+	// Producers that don't set a message ID are deduplicated by content.
+	if delivery.MessageId == "" {
+		sum := sha256.Sum256(d.Body)
+		delivery.MessageId = hex.EncodeToString(sum[:])
+	}
+	return delivery
+}
+
+func headerString(headers amqp.Table, key string) string {
+	value, _ := headers[key].(string)
+	return value
+}
+
+func headerInt(headers amqp.Table, key string) int {
+	switch value := headers[key].(type) {
+	case int:
+		return value
+	case int16:
+		return int(value)
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	default:
+		return 0
+	}
+}
+
+// AMQPConsumer consumes Queue with manual acks and passes every delivery to
+// Handler. A failed delivery is parked in "<Queue>.retry" for RetryDelay and
+// comes back through RetryExchange; after MaxRetries retries, or right away
+// for ErrPermanent, it is dead-lettered. Like AMQPPublisher it reconnects
+// with exponential backoff.
+type AMQPConsumer struct {
+	ConnectionString string
+	Queue            string
+	Bindings         []BindingConfig
+	Prefetch         int
+	MaxRetries       int
+	RetryDelay       time.Duration
+	Handler          DeliveryHandler
+	Logger           *logrus.Entry
+
+	mutex     sync.Mutex
+	connected bool
+	lastError error
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewAMQPConsumer(connectionString string, queue string, bindings []BindingConfig, prefetch int, maxRetries int, retryDelay time.Duration, handler DeliveryHandler, logger *logrus.Entry) *AMQPConsumer {
+	return &AMQPConsumer{
+		ConnectionString: connectionString,
+		Queue:            queue,
+		Bindings:         bindings,
+		Prefetch:         prefetch,
+		MaxRetries:       maxRetries,
+		RetryDelay:       retryDelay,
+		Handler:          handler,
+		Logger:           logger,
+		done:             make(chan struct{}),
+	}
+}
+
+func (c *AMQPConsumer) RetryQueue() string {
+	return c.Queue + ".retry"
+}
+
+// Topology declares the consumed queue with its retry queue and binds it to
+// the source exchanges, which are declared as durable topic exchanges.
+func (c *AMQPConsumer) Topology() *Topology {
+	topology := &Topology{
+		Exchanges: []ExchangeConfig{
+			{Name: RetryExchange, Kind: amqp.ExchangeDirect, Durable: true},
+			{Name: DeadLetterExchange, Kind: amqp.ExchangeFanout, Durable: true},
+		},
+		Queues: []QueueConfig{
+			{Name: c.Queue, Durable: true, DeadLetterExchange: DeadLetterExchange},
+			{Name: c.RetryQueue(), Durable: true, DeadLetterExchange: RetryExchange, DeadLetterRoutingKey: c.Queue, MessageTTL: int(c.RetryDelay / time.Millisecond)},
+			{Name: DeadLetterQueue, Durable: true},
+		},
+		Bindings: []BindingConfig{
+			{Queue: c.Queue, Exchange: RetryExchange, RoutingKey: c.Queue},
+			{Queue: DeadLetterQueue, Exchange: DeadLetterExchange},
+		},
+	}
+
+	declared := map[string]bool{}
+	for _, binding := range c.Bindings {
+		if !declared[binding.Exchange] {
+			declared[binding.Exchange] = true
+			topology.Exchanges = append(topology.Exchanges, ExchangeConfig{Name: binding.Exchange, Kind: amqp.ExchangeTopic, Durable: true})
+		}
+		topology.Bindings = append(topology.Bindings, BindingConfig{Queue: c.Queue, Exchange: binding.Exchange, RoutingKey: binding.RoutingKey})
+	}
+	return topology
+}
+
+func (c *AMQPConsumer) Start() {
+	c.stopped = make(chan struct{})
+	go c.run()
+}
+
+// Close stops consuming, waits for the delivery being handled to be acked and
+// closes the connection.
+func (c *AMQPConsumer) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.stopped != nil {
+			<-c.stopped
+		}
+	})
+}
+
+type ConsumerStatus struct {
+	Queue     string
+	Connected bool
+	LastError string
+}
+
+func (c *AMQPConsumer) Status() ConsumerStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	status := ConsumerStatus{Queue: c.Queue, Connected: c.connected}
+	if c.lastError != nil {
+		status.LastError = c.lastError.Error()
+	}
+	return status
+}
+
+func (c *AMQPConsumer) run() {
+	defer close(c.stopped)
+
+	delay := minReconnectDelay
+	for {
+		err := c.consume()
+		if c.Status().Connected {
+			delay = minReconnectDelay
+		}
+		c.setStatus(false, err)
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		if err != nil {
+			c.Logger.Error(fmt.Sprintf("Consumer of %s lost RabbitMQ, retrying in %s: %s", c.Queue, delay, err.Error()))
+		}
+		select {
+		case <-time.After(delay):
+		case <-c.done:
+			return
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// consume runs until the connection drops or Close is called.
+func (c *AMQPConsumer) consume() error {
+	connection, err := amqp.Dial(c.ConnectionString)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+
+	channel, err := connection.Channel()
+	if err != nil {
+		return err
+	}
+
+	if err := c.Topology().Declare(channel); err != nil {
+		return fmt.Errorf("can't declare consumer topology: %s", err.Error())
+	}
+	if err := channel.Qos(c.Prefetch, 0, false); err != nil {
+		return err
+	}
+
+	tag := "users-ms-" + c.Queue
+	deliveries, err := channel.Consume(c.Queue, tag, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	c.setStatus(true, nil)
+	c.Logger.Info(fmt.Sprintf("Consuming %s", c.Queue))
+
+	for {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return errors.New("delivery channel closed")
+			}
+			c.handle(channel, d)
+		case <-c.done:
+			channel.Cancel(tag, false)
+			return nil
+		}
+	}
+}
+
+func (c *AMQPConsumer) handle(channel *amqp.Channel, d amqp.Delivery) {
+	delivery := NewDelivery(d)
+
+	span := c.startSpan(d, delivery)
+	defer span.Finish()
+	delivery.Context = opentracing.ContextWithSpan(delivery.Context, span)
+
+	err := c.Handler(delivery)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+	}
+	if err == nil {
+		d.Ack(false)
+		return
+	}
+
+	if errors.Is(err, ErrPermanent) || delivery.Attempt >= c.MaxRetries {
+		c.Logger.Error(fmt.Sprintf("Dead-lettering message %s of type %s after %d retries: %s", delivery.MessageId, delivery.Type, delivery.Attempt, err.Error()))
+		d.Nack(false, false)
+		return
+	}
+
+	c.Logger.Warn(fmt.Sprintf("Retrying message %s of type %s in %s: %s", delivery.MessageId, delivery.Type, c.RetryDelay, err.Error()))
+	if err := channel.Publish("", c.RetryQueue(), false, false, retryPublishing(d, delivery)); err != nil {
+		c.Logger.Error(fmt.Sprintf("Can't schedule a retry of message %s, requeueing it: %s", delivery.MessageId, err.Error()))
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
+
+// startSpan starts the span of handling a delivery. It follows from the
+// publisher's span when the headers carry one.
+func (c *AMQPConsumer) startSpan(d amqp.Delivery, delivery Delivery) opentracing.Span {
+	options := []opentracing.StartSpanOption{ext.SpanKindConsumer}
+	if parent, err := ExtractTrace(d.Headers); err == nil {
+		options = append(options, opentracing.FollowsFrom(parent))
+	}
+	span := opentracing.StartSpan("AMQP consume "+delivery.Type, options...)
+	span.SetTag("message_bus.destination", c.Queue)
+	span.SetTag("message_id", delivery.MessageId)
+	return span
+}
+
+func retryPublishing(d amqp.Delivery, delivery Delivery) amqp.Publishing {
+	headers := amqp.Table{}
+	for key, value := range d.Headers {
+		headers[key] = value
+	}
+	headers[retryCountHeader] = int32(delivery.Attempt + 1)
+	headers[originalExchangeHeader] = delivery.Exchange
+	headers[originalRoutingKeyHeader] = delivery.RoutingKey
+
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		CorrelationId:   d.CorrelationId,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		Body:            d.Body,
+	}
+}
+
+func (c *AMQPConsumer) setStatus(connected bool, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.connected = connected
+	c.lastError = err
+}
+
+// Router dispatches deliveries to the handler whose pattern matches their
+// type, using topic exchange rules (see MatchRoutingKey). Deliveries of types
+// no one handles are acked and logged.
+type Router struct {
+	Logger *logrus.Entry
+
+	routes []route
+}
+
+type route struct {
+	pattern string
+	handler DeliveryHandler
+}
+
+func NewRouter(logger *logrus.Entry) *Router {
+	return &Router{Logger: logger}
+}
+
+func (router *Router) Handle(pattern string, handler DeliveryHandler) {
+	router.routes = append(router.routes, route{pattern, handler})
+}
+
+func (router *Router) Dispatch(delivery Delivery) error {
+	for _, route := range router.routes {
+		if MatchRoutingKey(route.pattern, delivery.Type) {
+			return route.handler(delivery)
+		}
+	}
+
+	router.Logger.Warn(fmt.Sprintf("No handler for message %s of type %s, ignoring it", delivery.MessageId, delivery.Type))
+	return nil
+}
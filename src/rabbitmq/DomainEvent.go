@@ -0,0 +1,51 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+)
+
+// DomainEventsExchange is the topic exchange users-ms publishes its domain
+// events to. Events are routed by "<type>.v<version>", so consumers can bind
+// to e.g. "user.#" or "follow.created.*".
+var DomainEventsExchange = "users-ms-events"
+
+type EventType string
+
+const (
+	UserRegistered  EventType = "user.registered"
+	UserUpdated     EventType = "user.updated"
+	UserDeactivated EventType = "user.deactivated"
+	UserBlocked     EventType = "user.blocked"
+	UserUnblocked   EventType = "user.unblocked"
+	FollowCreated   EventType = "follow.created"
+	FollowRemoved   EventType = "follow.removed"
+	RequestCreated  EventType = "follow_request.created"
+	RequestDecided  EventType = "follow_request.decided"
+)
+
+// CloudEventType is the stable CloudEvents type of the event, e.g.
+// "dislinkt.users.user.registered".
+func (eventType EventType) CloudEventType() string {
+	return "dislinkt.users." + string(eventType)
+}
+
+// Version is the payload version from the schema registry.
+func (eventType EventType) Version() int {
+	schema, _ := LookupSchema(eventType.CloudEventType())
+	return schema.Version
+}
+
+func (eventType EventType) RoutingKey() string {
+	return fmt.Sprintf("%s.v%d", eventType, eventType.Version())
+}
+
+func NewDomainEventMessage(ctx context.Context, eventType EventType, data interface{}) (Message, error) {
+	event, err := NewCloudEvent(ctx, eventType.CloudEventType(), "", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg, err := event.Message(DomainEventsExchange, eventType.RoutingKey())
+	return traceMessage(ctx, msg, err)
+}
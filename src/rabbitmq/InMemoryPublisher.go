@@ -0,0 +1,176 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// Subscriber is called with every message InMemoryPublisher accepts whose
+// exchange and routing key match the subscription.
+type Subscriber func(Message)
+
+type subscription struct {
+	exchange   string
+	routingKey string
+	subscriber Subscriber
+}
+
+// DefaultInMemoryCapacity is how many messages InMemoryPublisher keeps.
+const DefaultInMemoryCapacity = 1000
+
+// InMemoryPublisher records published messages instead of sending them to a
+// broker and hands them to subscribers synchronously. Every message is
+// confirmed, so the outbox relay behaves as it does against RabbitMQ. Only
+// the last Capacity messages are kept, older ones are forgotten.
+type InMemoryPublisher struct {
+	Capacity int
+	Logger   *logrus.Entry
+
+	mutex         sync.Mutex
+	messages      []Message
+	subscriptions []subscription
+	closed        bool
+}
+
+func NewInMemoryPublisher(logger *logrus.Entry) *InMemoryPublisher {
+	return &InMemoryPublisher{Capacity: DefaultInMemoryCapacity, Logger: logger}
+}
+
+func (p *InMemoryPublisher) Start() {
+	if p.Logger != nil {
+		p.Logger.Info("Using the in-memory message broker, messages won't leave the process")
+	}
+}
+
+func (p *InMemoryPublisher) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+}
+
+func (p *InMemoryPublisher) Publish(msg Message) error {
+	if p == nil {
+		return ErrNoPublisher
+	}
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return ErrClosed
+	}
+	p.messages = append(p.messages, msg)
+	if p.Capacity > 0 && len(p.messages) > p.Capacity {
+		p.messages = p.messages[len(p.messages)-p.Capacity:]
+	}
+	var subscribers []Subscriber
+	for _, s := range p.subscriptions {
+		if s.exchange == msg.Exchange && MatchRoutingKey(s.routingKey, msg.RoutingKey) {
+			subscribers = append(subscribers, s.subscriber)
+		}
+	}
+	p.mutex.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(msg)
+	}
+	return nil
+}
+
+func (p *InMemoryPublisher) PublishConfirmed(msg Message) error {
+	return p.Publish(msg)
+}
+
+func (p *InMemoryPublisher) Status() PublisherStatus {
+	if p == nil {
+		return PublisherStatus{LastError: ErrNoPublisher.Error()}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	status := PublisherStatus{Connected: !p.closed}
+	if p.closed {
+		status.LastError = ErrClosed.Error()
+	}
+	return status
+}
+
+// Subscribe registers subscriber for messages published to exchange with a
+// routing key matching routingKey, which follows topic exchange rules: "*"
+// matches one word and "#" zero or more.
+func (p *InMemoryPublisher) Subscribe(exchange string, routingKey string, subscriber Subscriber) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.subscriptions = append(p.subscriptions, subscription{exchange, routingKey, subscriber})
+}
+
+// Consume hands messages matching bindings to handler, the way AMQPConsumer
+// would but without retries: failures are only logged.
+func (p *InMemoryPublisher) Consume(bindings []BindingConfig, handler DeliveryHandler) {
+	for _, binding := range bindings {
+		p.Subscribe(binding.Exchange, binding.RoutingKey, func(msg Message) {
+			delivery := NewDelivery(amqp.Delivery{
+				Headers:     msg.Publishing.Headers,
+				ContentType: msg.Publishing.ContentType,
+				MessageId:   msg.Publishing.MessageId,
+				Type:        msg.Publishing.Type,
+				Exchange:    msg.Exchange,
+				RoutingKey:  msg.RoutingKey,
+				Body:        msg.Publishing.Body,
+			})
+			if err := handler(delivery); err != nil && p.Logger != nil {
+				p.Logger.Error(fmt.Sprintf("Can't process message %s of type %s: %s", delivery.MessageId, delivery.Type, err.Error()))
+			}
+		})
+	}
+}
+
+// Messages returns a copy of the messages kept, oldest first.
+func (p *InMemoryPublisher) Messages() []Message {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	messages := make([]Message, len(p.messages))
+	copy(messages, p.messages)
+	return messages
+}
+
+// Reset forgets the recorded messages but keeps the subscriptions.
+func (p *InMemoryPublisher) Reset() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.messages = nil
+}
+
+// MatchRoutingKey reports whether routingKey matches pattern the way a topic
+// exchange binding would.
+func MatchRoutingKey(pattern string, routingKey string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+func matchWords(pattern []string, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
@@ -0,0 +1,67 @@
+package rabbitmq
+
+import (
+	"testing"
+	"user-ms/src/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type InMemoryPublisherTestsSuite struct {
+	suite.Suite
+}
+
+func TestInMemoryPublisherTestsSuite(t *testing.T) {
+	suite.Run(t, new(InMemoryPublisherTestsSuite))
+}
+
+func (suite *InMemoryPublisherTestsSuite) TestPublish_RecordsAndDelivers() {
+	publisher := NewInMemoryPublisher(logging.Logger())
+	var received []string
+	publisher.Subscribe(DomainEventsExchange, "follow.#", func(msg Message) {
+		received = append(received, msg.RoutingKey)
+	})
+
+	assert.Nil(suite.T(), publisher.Publish(Message{Exchange: DomainEventsExchange, RoutingKey: "follow.created.v1"}))
+	assert.Nil(suite.T(), publisher.PublishConfirmed(Message{Exchange: DomainEventsExchange, RoutingKey: "user.updated.v1"}))
+	assert.Nil(suite.T(), publisher.Publish(Message{Exchange: NotificationExchange, RoutingKey: "follow.created.v1"}))
+
+	assert.Equal(suite.T(), []string{"follow.created.v1"}, received)
+	assert.Len(suite.T(), publisher.Messages(), 3)
+	assert.True(suite.T(), publisher.Status().Connected)
+
+	publisher.Reset()
+	assert.Empty(suite.T(), publisher.Messages())
+}
+
+func (suite *InMemoryPublisherTestsSuite) TestPublish_KeepsTheLatestMessages() {
+	publisher := NewInMemoryPublisher(logging.Logger())
+	publisher.Capacity = 2
+
+	for _, routingKey := range []string{"first", "second", "third"} {
+		assert.Nil(suite.T(), publisher.Publish(Message{RoutingKey: routingKey}))
+	}
+
+	messages := publisher.Messages()
+	assert.Len(suite.T(), messages, 2)
+	assert.Equal(suite.T(), "second", messages[0].RoutingKey)
+	assert.Equal(suite.T(), "third", messages[1].RoutingKey)
+}
+
+func (suite *InMemoryPublisherTestsSuite) TestPublish_Closed() {
+	publisher := NewInMemoryPublisher(logging.Logger())
+	publisher.Close()
+
+	assert.Equal(suite.T(), ErrClosed, publisher.Publish(Message{}))
+	assert.False(suite.T(), publisher.Status().Connected)
+}
+
+func (suite *InMemoryPublisherTestsSuite) TestMatchRoutingKey() {
+	assert.True(suite.T(), MatchRoutingKey("user.updated.v1", "user.updated.v1"))
+	assert.True(suite.T(), MatchRoutingKey("user.*.v1", "user.blocked.v1"))
+	assert.False(suite.T(), MatchRoutingKey("user.*", "user.blocked.v1"))
+	assert.True(suite.T(), MatchRoutingKey("#", "follow_request.decided.v1"))
+	assert.True(suite.T(), MatchRoutingKey("follow.#", "follow"))
+	assert.False(suite.T(), MatchRoutingKey("follow.#", "follow_request.created.v1"))
+}
@@ -0,0 +1,44 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"user-ms/src/dto"
+)
+
+// notificationEventTypes are the CloudEvents types of notifications, one per
+// notification type.
+var notificationEventTypes = map[dto.NotificationType]string{
+	dto.Message:              "dislinkt.users.notification.message",
+	dto.Follow:               "dislinkt.users.notification.follow",
+	dto.Like:                 "dislinkt.users.notification.like",
+	dto.Comment:              "dislinkt.users.notification.comment",
+	dto.Connection:           "dislinkt.users.notification.connection",
+	dto.FollowRequestOutcome: "dislinkt.users.notification.follow_request",
+}
+
+func NewNotificationMessage(ctx context.Context, notification *dto.NotificationDTO) (Message, error) {
+	if notification == nil || notification.NotificationType == nil {
+		return Message{}, errors.New("notification type is required")
+	}
+
+	event, err := NewCloudEvent(ctx, notificationEventTypes[*notification.NotificationType], notification.UserAuth0ID, notification)
+	if err != nil {
+		return Message{}, err
+	}
+
+	// A notification nobody receives is lost for the user, unlike a domain
+	// event, so it is reported when it can't be routed.
+	msg, err := event.Message(NotificationExchange, NotificationRoutingKey)
+	msg.Mandatory = true
+	return traceMessage(ctx, msg, err)
+}
+
+func AddNotification(ctx context.Context, notification *dto.NotificationDTO, publisher Publisher) error {
+	msg, err := NewNotificationMessage(ctx, notification)
+	if err != nil {
+		return err
+	}
+
+	return publisher.Publish(msg)
+}
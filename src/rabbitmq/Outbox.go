@@ -0,0 +1,59 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"user-ms/src/model"
+
+	"github.com/streadway/amqp"
+)
+
+// ToOutbox converts msg into a row for the outbox table.
+func (msg Message) ToOutbox() (*model.OutboxMessage, error) {
+	headers := ""
+	if len(msg.Publishing.Headers) > 0 {
+		encoded, err := json.Marshal(msg.Publishing.Headers)
+		if err != nil {
+			return nil, err
+		}
+		headers = string(encoded)
+	}
+
+	return &model.OutboxMessage{
+		MessageId:   msg.Publishing.MessageId,
+		Exchange:    msg.Exchange,
+		RoutingKey:  msg.RoutingKey,
+		Mandatory:   msg.Mandatory,
+		ContentType: msg.Publishing.ContentType,
+		Type:        msg.Publishing.Type,
+		Headers:     headers,
+		Body:        string(msg.Publishing.Body),
+		CreatedAt:   msg.Publishing.Timestamp,
+	}, nil
+}
+
+// MessageFromOutbox rebuilds the message stored in an outbox row. The message
+// ID is kept so consumers can drop the duplicates at-least-once delivery
+// produces.
+func MessageFromOutbox(row *model.OutboxMessage) (Message, error) {
+	var headers amqp.Table
+	if row.Headers != "" {
+		if err := json.Unmarshal([]byte(row.Headers), &headers); err != nil {
+			return Message{}, err
+		}
+	}
+
+	return Message{
+		Exchange:   row.Exchange,
+		RoutingKey: row.RoutingKey,
+		Mandatory:  row.Mandatory,
+		Publishing: amqp.Publishing{
+			Headers:      headers,
+			ContentType:  row.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    row.MessageId,
+			Type:         row.Type,
+			Timestamp:    row.CreatedAt,
+			Body:         []byte(row.Body),
+		},
+	}, nil
+}
@@ -0,0 +1,17 @@
+package rabbitmq
+
+// Publisher sends messages to the message broker. AMQPPublisher talks to
+// RabbitMQ; InMemoryPublisher keeps messages in the process for tests and
+// local runs without a broker.
+type Publisher interface {
+	Start()
+	Close()
+	Publish(Message) error
+	PublishConfirmed(Message) error
+	Status() PublisherStatus
+}
+
+var (
+	_ Publisher = (*AMQPPublisher)(nil)
+	_ Publisher = (*InMemoryPublisher)(nil)
+)
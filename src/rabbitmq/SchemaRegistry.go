@@ -0,0 +1,104 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type FieldKind int
+
+const (
+	StringField FieldKind = iota
+	NumberField
+	BoolField
+	ArrayField
+	ObjectField
+)
+
+func (kind FieldKind) matches(value interface{}) bool {
+	switch value.(type) {
+	case string:
+		return kind == StringField
+	case float64:
+		return kind == NumberField
+	case bool:
+		return kind == BoolField
+	case []interface{}:
+		return kind == ArrayField
+	case map[string]interface{}:
+		return kind == ObjectField
+	}
+	return false
+}
+
+// Schema describes the data of one event type: the fields every payload must
+// have and their JSON kinds. Version is bumped on incompatible changes and
+// published in the dataschema attribute.
+type Schema struct {
+	Type    string
+	Version int
+	Fields  map[string]FieldKind
+}
+
+func (schema Schema) DataSchema() string {
+	return fmt.Sprintf("urn:dislinkt:schema:%s:v%d", schema.Type, schema.Version)
+}
+
+func (schema Schema) Validate(payload []byte) error {
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return fmt.Errorf("%s payload must be a JSON object: %s", schema.Type, err.Error())
+	}
+
+	for name, kind := range schema.Fields {
+		value, ok := data[name]
+		if !ok {
+			return fmt.Errorf("%s payload is missing field %s", schema.Type, name)
+		}
+		if !kind.matches(value) {
+			return fmt.Errorf("%s payload field %s has the wrong type", schema.Type, name)
+		}
+	}
+	return nil
+}
+
+var (
+	schemasMutex sync.RWMutex
+	schemas      = map[string]Schema{}
+)
+
+func RegisterSchema(schema Schema) {
+	schemasMutex.Lock()
+	defer schemasMutex.Unlock()
+	schemas[schema.Type] = schema
+}
+
+func LookupSchema(eventType string) (Schema, bool) {
+	schemasMutex.RLock()
+	defer schemasMutex.RUnlock()
+	schema, ok := schemas[eventType]
+	return schema, ok
+}
+
+func init() {
+	notification := map[string]FieldKind{"Message": StringField, "UserAuth0ID": StringField, "NotificationType": NumberField}
+	for _, eventType := range notificationEventTypes {
+		RegisterSchema(Schema{Type: eventType, Version: 1, Fields: notification})
+	}
+
+	user := map[string]FieldKind{"ID": NumberField, "Auth0ID": StringField, "Username": StringField, "Public": BoolField, "Active": BoolField}
+	block := map[string]FieldKind{"UserId": NumberField, "BlockedUserId": NumberField}
+	follow := map[string]FieldKind{"FollowerId": NumberField, "FollowingId": NumberField, "ActorId": NumberField}
+	request := map[string]FieldKind{"RequestId": NumberField, "FollowerId": NumberField, "FollowingId": NumberField, "ActorId": NumberField, "RequestStatus": StringField}
+
+	RegisterSchema(Schema{Type: UserRegistered.CloudEventType(), Version: 1, Fields: user})
+	RegisterSchema(Schema{Type: UserUpdated.CloudEventType(), Version: 1, Fields: map[string]FieldKind{"User": ObjectField, "ChangedFields": ArrayField}})
+	RegisterSchema(Schema{Type: UserDeactivated.CloudEventType(), Version: 1, Fields: user})
+	RegisterSchema(Schema{Type: UserBlocked.CloudEventType(), Version: 1, Fields: block})
+	RegisterSchema(Schema{Type: UserUnblocked.CloudEventType(), Version: 1, Fields: block})
+	RegisterSchema(Schema{Type: FollowCreated.CloudEventType(), Version: 1, Fields: follow})
+	RegisterSchema(Schema{Type: FollowRemoved.CloudEventType(), Version: 1, Fields: follow})
+	RegisterSchema(Schema{Type: RequestCreated.CloudEventType(), Version: 1, Fields: request})
+	RegisterSchema(Schema{Type: RequestDecided.CloudEventType(), Version: 1, Fields: request})
+}
@@ -0,0 +1,177 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	NotificationExchange   = "AddNotification-MS-exchange"
+	NotificationRoutingKey = "AddNotification-MS-routing-key"
+	NotificationQueue      = "AddNotification-MS-queue"
+	DeadLetterExchange     = "users-ms-dead-letter"
+	DeadLetterQueue        = "users-ms-dead-letter"
+	RetryExchange          = "users-ms-retry"
+)
+
+type ExchangeConfig struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Durable bool   `json:"durable"`
+}
+
+// QueueConfig describes a queue. Passive queues belong to another service:
+// they are only checked for, never created, so their arguments don't have to
+// match the ones they were created with.
+type QueueConfig struct {
+	Name                 string `json:"name"`
+	Durable              bool   `json:"durable"`
+	Passive              bool   `json:"passive"`
+	DeadLetterExchange   string `json:"dead_letter_exchange"`
+	DeadLetterRoutingKey string `json:"dead_letter_routing_key"`
+	MessageTTL           int    `json:"message_ttl"`
+}
+
+type BindingConfig struct {
+	Queue      string `json:"queue"`
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+// Topology lists the exchanges, queues and bindings users-ms relies on. It is
+// declared every time the publisher connects; declarations are idempotent as
+// long as the arguments don't change.
+type Topology struct {
+	Exchanges []ExchangeConfig `json:"exchanges"`
+	Queues    []QueueConfig    `json:"queues"`
+	Bindings  []BindingConfig  `json:"bindings"`
+}
+
+// DefaultTopology declares the notification exchange, the domain events
+// exchange and a dead-letter exchange with a queue that keeps everything
+// rejected by consumers. The notification queue is owned by notification-ms,
+// so it is only bound, not redeclared.
+func DefaultTopology() *Topology {
+	return &Topology{
+		Exchanges: []ExchangeConfig{
+			{Name: NotificationExchange, Kind: amqp.ExchangeDirect, Durable: true},
+			{Name: DomainEventsExchange, Kind: amqp.ExchangeTopic, Durable: true},
+			{Name: DeadLetterExchange, Kind: amqp.ExchangeFanout, Durable: true},
+		},
+		Queues: []QueueConfig{
+			{Name: NotificationQueue, Durable: true, Passive: true},
+			{Name: DeadLetterQueue, Durable: true},
+		},
+		Bindings: []BindingConfig{
+			{Queue: NotificationQueue, Exchange: NotificationExchange, RoutingKey: NotificationRoutingKey},
+			{Queue: DeadLetterQueue, Exchange: DeadLetterExchange},
+		},
+	}
+}
+
+// LoadTopology reads a topology from a JSON file.
+func LoadTopology(path string) (*Topology, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var topology Topology
+	if err := json.NewDecoder(file).Decode(&topology); err != nil {
+		return nil, err
+	}
+	return &topology, nil
+}
+
+// Declare declares the topology on channel and stops at the first error,
+// which also closes the channel.
+func (topology *Topology) Declare(channel *amqp.Channel) error {
+	for _, exchange := range topology.Exchanges {
+		if err := declareExchange(channel, exchange); err != nil {
+			return err
+		}
+	}
+	for _, queue := range topology.Queues {
+		if err := declareQueue(channel, queue); err != nil {
+			return err
+		}
+	}
+	for _, binding := range topology.Bindings {
+		if err := bindQueue(channel, binding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeclareAll declares as much of the topology as it can and returns the
+// errors. A failed declaration closes its channel, so a new one is opened
+// for the declarations after it.
+func (topology *Topology) DeclareAll(connection *amqp.Connection) []error {
+	var errs []error
+	var channel *amqp.Channel
+	declare := func(what string, fn func(*amqp.Channel) error) {
+		if channel == nil {
+			var err error
+			if channel, err = connection.Channel(); err != nil {
+				channel = nil
+				errs = append(errs, err)
+				return
+			}
+		}
+		if err := fn(channel); err != nil {
+			channel = nil
+			errs = append(errs, fmt.Errorf("%s: %w", what, err))
+		}
+	}
+
+	for _, exchange := range topology.Exchanges {
+		exchange := exchange
+		declare("exchange "+exchange.Name, func(channel *amqp.Channel) error { return declareExchange(channel, exchange) })
+	}
+	for _, queue := range topology.Queues {
+		queue := queue
+		declare("queue "+queue.Name, func(channel *amqp.Channel) error { return declareQueue(channel, queue) })
+	}
+	for _, binding := range topology.Bindings {
+		binding := binding
+		declare(fmt.Sprintf("binding of %s to %s", binding.Queue, binding.Exchange), func(channel *amqp.Channel) error { return bindQueue(channel, binding) })
+	}
+
+	if channel != nil {
+		channel.Close()
+	}
+	return errs
+}
+
+func declareExchange(channel *amqp.Channel, exchange ExchangeConfig) error {
+	return channel.ExchangeDeclare(exchange.Name, exchange.Kind, exchange.Durable, false, false, false, nil)
+}
+
+func declareQueue(channel *amqp.Channel, queue QueueConfig) error {
+	if queue.Passive {
+		_, err := channel.QueueDeclarePassive(queue.Name, queue.Durable, false, false, false, nil)
+		return err
+	}
+
+	args := amqp.Table{}
+	if queue.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = queue.DeadLetterExchange
+	}
+	if queue.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = queue.DeadLetterRoutingKey
+	}
+	if queue.MessageTTL > 0 {
+		args["x-message-ttl"] = int32(queue.MessageTTL)
+	}
+	_, err := channel.QueueDeclare(queue.Name, queue.Durable, false, false, false, args)
+	return err
+}
+
+func bindQueue(channel *amqp.Channel, binding BindingConfig) error {
+	return channel.QueueBind(binding.Queue, binding.RoutingKey, binding.Exchange, false, nil)
+}
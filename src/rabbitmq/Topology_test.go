@@ -0,0 +1,49 @@
+package rabbitmq
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TopologyTestsSuite struct {
+	suite.Suite
+}
+
+func TestTopologyTestsSuite(t *testing.T) {
+	suite.Run(t, new(TopologyTestsSuite))
+}
+
+func (suite *TopologyTestsSuite) TestDefaultTopology_NotificationQueueIsPassive() {
+	topology := DefaultTopology()
+
+	for _, queue := range topology.Queues {
+		if queue.Name == NotificationQueue {
+			assert.True(suite.T(), queue.Passive)
+			return
+		}
+	}
+	suite.T().Fatal("notification queue is not declared")
+}
+
+func (suite *TopologyTestsSuite) TestLoadTopology() {
+	path := filepath.Join(suite.T().TempDir(), "topology.json")
+	content := `{"exchanges":[{"name":"events","kind":"topic","durable":true}],"queues":[{"name":"audit","passive":true}],"bindings":[{"queue":"audit","exchange":"events","routing_key":"#"}]}`
+	assert.Nil(suite.T(), os.WriteFile(path, []byte(content), 0644))
+
+	topology, err := LoadTopology(path)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "topic", topology.Exchanges[0].Kind)
+	assert.True(suite.T(), topology.Queues[0].Passive)
+	assert.Equal(suite.T(), "#", topology.Bindings[0].RoutingKey)
+}
+
+func (suite *TopologyTestsSuite) TestLoadTopology_MissingFile() {
+	_, err := LoadTopology(filepath.Join(suite.T().TempDir(), "missing.json"))
+
+	assert.NotNil(suite.T(), err)
+}
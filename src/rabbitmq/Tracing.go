@@ -0,0 +1,54 @@
+package rabbitmq
+
+import (
+	"context"
+	"user-ms/src/requestid"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/streadway/amqp"
+)
+
+// InjectTrace writes the span in ctx into the message headers, in the
+// tracer's own format (uber-trace-id for Jaeger). The CloudEvents traceparent
+// attribute carries the same trace for consumers that don't use Jaeger.
+func InjectTrace(ctx context.Context, headers amqp.Table) {
+	if ctx == nil || headers == nil {
+		return
+	}
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	carrier := opentracing.TextMapCarrier{}
+	if err := span.Tracer().Inject(span.Context(), opentracing.TextMap, carrier); err != nil {
+		return
+	}
+	for key, value := range carrier {
+		headers[key] = value
+	}
+}
+
+// ExtractTrace reads a span context written by InjectTrace.
+func ExtractTrace(headers amqp.Table) (opentracing.SpanContext, error) {
+	carrier := opentracing.TextMapCarrier{}
+	for key, value := range headers {
+		if text, ok := value.(string); ok {
+			carrier[key] = text
+		}
+	}
+	return opentracing.GlobalTracer().Extract(opentracing.TextMap, carrier)
+}
+
+// traceMessage adds the trace and the request ID in ctx to the headers of
+// msg, so consumers can correlate it with the request that caused it.
+func traceMessage(ctx context.Context, msg Message, err error) (Message, error) {
+	if err != nil {
+		return msg, err
+	}
+	InjectTrace(ctx, msg.Publishing.Headers)
+	if id := requestid.FromContext(ctx); id != "" && msg.Publishing.Headers != nil {
+		msg.Publishing.Headers[requestid.AMQPHeader] = id
+	}
+	return msg, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"user-ms/src/model"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// ErrConnectionExists is returned when two users who are already connected,
+// or invited one another, are connected again.
+var ErrConnectionExists = errors.New("the connection already exists")
+
+type IConnectionRepository interface {
+	AddConnection(*model.Connection) (int, error)
+	UpdateConnection(*model.Connection) (*model.Connection, error)
+	DeleteConnection(int) error
+	GetByID(int) (*model.Connection, error)
+	GetBetween(int, int) (*model.Connection, error)
+	GetConnections(int) []model.Connection
+	GetInvitations(int) []model.Connection
+}
+
+func NewConnectionRepository(database *gorm.DB) IConnectionRepository {
+	return &ConnectionRepository{
+		database,
+	}
+}
+
+type ConnectionRepository struct {
+	Database *gorm.DB
+}
+
+// AddConnection stores a new invitation. The unique index on the user pair
+// catches a concurrent invitation the GetBetween check missed; the insert
+// then does nothing and ErrConnectionExists is returned.
+func (repo *ConnectionRepository) AddConnection(connection *model.Connection) (int, error) {
+	if _, err := repo.GetBetween(connection.InviterId, connection.InviteeId); err == nil {
+		return -1, ErrConnectionExists
+	}
+
+	var inserted []model.Connection
+	result := repo.Database.Raw("INSERT INTO connections (inviter_id, invitee_id, request_status) VALUES (?, ?, ?) ON CONFLICT DO NOTHING RETURNING *",
+		connection.InviterId, connection.InviteeId, connection.RequestStatus).Scan(&inserted)
+	if result.Error != nil {
+		return -1, result.Error
+	}
+	if len(inserted) == 0 {
+		return -1, ErrConnectionExists
+	}
+
+	connection.ID = inserted[0].ID
+	return connection.ID, nil
+}
+
+func (repo *ConnectionRepository) UpdateConnection(connection *model.Connection) (*model.Connection, error) {
+	result := repo.Database.Save(connection)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return connection, nil
+}
+
+func (repo *ConnectionRepository) DeleteConnection(id int) error {
+	result := repo.Database.Delete(&model.Connection{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return nil
+}
+
+func (repo *ConnectionRepository) GetByID(id int) (*model.Connection, error) {
+	var connection model.Connection
+	if err := repo.Database.Where("id = ?", id).First(&connection).Error; err != nil {
+		return nil, errors.New(fmt.Sprintf("Connection with ID %d not found", id))
+	}
+
+	return &connection, nil
+}
+
+// GetBetween returns the pending or accepted connection between two users,
+// regardless of which of them sent the invitation.
+func (repo *ConnectionRepository) GetBetween(userId int, otherUserId int) (*model.Connection, error) {
+	var connection model.Connection
+	if err := repo.Database.Where("((inviter_id = ? and invitee_id = ?) or (inviter_id = ? and invitee_id = ?)) and request_status <> ?",
+		userId, otherUserId, otherUserId, userId, model.REJECTED).First(&connection).Error; err != nil {
+		return nil, errors.New(fmt.Sprintf("Connection between users %d and %d not found", userId, otherUserId))
+	}
+
+	return &connection, nil
+}
+
+func (repo *ConnectionRepository) GetConnections(userId int) []model.Connection {
+	var connections []model.Connection
+	repo.Database.Where("(inviter_id = ? or invitee_id = ?) and request_status = ?", userId, userId, model.ACCEPTED).Find(&connections)
+	return connections
+}
+
+func (repo *ConnectionRepository) GetInvitations(userId int) []model.Connection {
+	var connections []model.Connection
+	repo.Database.Where("invitee_id = ? and request_status = ?", userId, model.PENDING).Find(&connections)
+	return connections
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"user-ms/src/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type ConnectionRepositoryMock struct {
+	mock.Mock
+}
+
+func (c *ConnectionRepositoryMock) AddConnection(connection *model.Connection) (int, error) {
+	args := c.Called(connection)
+	if args.Get(1) == nil {
+		return args.Get(0).(int), nil
+	}
+	return -1, args.Get(1).(error)
+}
+
+func (c *ConnectionRepositoryMock) UpdateConnection(connection *model.Connection) (*model.Connection, error) {
+	args := c.Called(connection)
+	if args.Get(1) == nil {
+		return args.Get(0).(*model.Connection), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (c *ConnectionRepositoryMock) DeleteConnection(id int) error {
+	args := c.Called(id)
+	return args.Error(0)
+}
+
+func (c *ConnectionRepositoryMock) GetByID(id int) (*model.Connection, error) {
+	args := c.Called(id)
+	if args.Get(1) == nil {
+		return args.Get(0).(*model.Connection), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (c *ConnectionRepositoryMock) GetBetween(userId int, otherUserId int) (*model.Connection, error) {
+	args := c.Called(userId, otherUserId)
+	if args.Get(1) == nil {
+		return args.Get(0).(*model.Connection), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (c *ConnectionRepositoryMock) GetConnections(userId int) []model.Connection {
+	args := c.Called(userId)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).([]model.Connection)
+}
+
+func (c *ConnectionRepositoryMock) GetInvitations(userId int) []model.Connection {
+	args := c.Called(userId)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Get(0).([]model.Connection)
+}
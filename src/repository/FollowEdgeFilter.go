@@ -0,0 +1,11 @@
+package repository
+
+import "time"
+
+// FollowEdgeFilter narrows the follow graph streamed for exports. Nil bounds
+// are open.
+type FollowEdgeFilter struct {
+	From       *time.Time
+	To         *time.Time
+	ActiveOnly bool
+}
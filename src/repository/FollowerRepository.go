@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"time"
+	"user-ms/src/dto"
 	"user-ms/src/model"
+	"user-ms/src/tracing"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
@@ -11,9 +15,15 @@ import (
 type IFollowerRepository interface {
 	AddFollower(*model.Follower) (int, error)
 	DeleteFollower(int) error
-	GetFollowing(int) []model.Follower
-	GetFollowers(int) []model.Follower
-	RemoveFollowing(int, int) error
+	GetFollowing(int, string) []model.Follower
+	GetFollowers(int, string) []model.Follower
+	RemoveFollowing(int, int) (bool, error)
+	RemoveFollower(int, int) error
+	AddFollowAttempt(*model.FollowAttempt) error
+	CountFollowAttemptsSince(int, time.Time) (int, *time.Time)
+	DeleteFollowAttemptsBefore(time.Time) error
+	StreamEdges(FollowEdgeFilter, func(*dto.FollowEdgeDTO) error) error
+	WithContext(context.Context) IFollowerRepository
 }
 
 func NewFollowerRepository(database *gorm.DB) IFollowerRepository {
@@ -26,6 +36,11 @@ type FollowerRepository struct {
 	Database *gorm.DB
 }
 
+// WithContext returns the repository with its queries traced under ctx.
+func (repo *FollowerRepository) WithContext(ctx context.Context) IFollowerRepository {
+	return &FollowerRepository{tracing.WithContext(repo.Database, ctx)}
+}
+
 func (repo *FollowerRepository) AddFollower(follower *model.Follower) (int, error) {
 	var check []model.Follower
 	repo.Database.Where("follower_id = ? and following_id = ? ",
@@ -49,23 +64,89 @@ func (repo *FollowerRepository) DeleteFollower(id int) error {
 	return nil
 }
 
-func (repo *FollowerRepository) GetFollowing(id int) []model.Follower {
+func (repo *FollowerRepository) GetFollowing(id int, orderBy string) []model.Follower {
 	var req []model.Follower
-	repo.Database.Where("follower_id = ?", id).Find(&req)
+	repo.Database.Where("follower_id = ?", id).Order(orderBy).Find(&req)
 	return req
 }
 
-func (repo *FollowerRepository) GetFollowers(id int) []model.Follower {
+func (repo *FollowerRepository) GetFollowers(id int, orderBy string) []model.Follower {
 	var req []model.Follower
-	repo.Database.Where("following_id = ?", id).Find(&req)
+	repo.Database.Where("following_id = ?", id).Order(orderBy).Find(&req)
 	return req
 }
 
-func (repo *FollowerRepository) RemoveFollowing(id int, followingId int) error {
+// RemoveFollowing reports whether the user was following followingId.
+func (repo *FollowerRepository) RemoveFollowing(id int, followingId int) (bool, error) {
 	var follower model.Follower
 	result := repo.Database.Where("follower_id = ? and following_id = ?", id, followingId).Delete(&follower)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (repo *FollowerRepository) RemoveFollower(id int, followerId int) error {
+	var follower model.Follower
+	result := repo.Database.Where("follower_id = ? and following_id = ?", followerId, id).Delete(&follower)
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return errors.New("the couple doesn't exist")
+	}
 	return nil
 }
+
+func (repo *FollowerRepository) AddFollowAttempt(attempt *model.FollowAttempt) error {
+	return repo.Database.Create(attempt).Error
+}
+
+// CountFollowAttemptsSince counts the follows the user started since the
+// given time, together with the time of the oldest one of them.
+func (repo *FollowerRepository) CountFollowAttemptsSince(userId int, since time.Time) (int, *time.Time) {
+	var stats windowStats
+	repo.Database.Model(&model.FollowAttempt{}).Select("count(*) as count, min(created_at) as oldest").
+		Where("user_id = ? and created_at >= ?", userId, since).Scan(&stats)
+	return stats.Count, stats.Oldest
+}
+
+func (repo *FollowerRepository) DeleteFollowAttemptsBefore(cutoff time.Time) error {
+	return repo.Database.Where("created_at < ?", cutoff).Delete(&model.FollowAttempt{}).Error
+}
+
+// StreamEdges walks the follow graph row by row, calling fn for every edge
+// that matches the filter. Rows are never loaded into memory all at once.
+func (repo *FollowerRepository) StreamEdges(filter FollowEdgeFilter, fn func(*dto.FollowEdgeDTO) error) error {
+	query := repo.Database.Table("followers").
+		Select("followers.follower_id, followers.following_id, followers.created_at, follower.username, following.username").
+		Joins("join users follower on follower.id = followers.follower_id").
+		Joins("join users following on following.id = followers.following_id")
+	if filter.From != nil {
+		query = query.Where("followers.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("followers.created_at < ?", *filter.To)
+	}
+	if filter.ActiveOnly {
+		query = query.Where("follower.active = ? and following.active = ?", true, true)
+	}
+
+	rows, err := query.Order("followers.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var edge dto.FollowEdgeDTO
+		if err := rows.Scan(&edge.FollowerId, &edge.FollowingId, &edge.CreatedAt, &edge.FollowerUsername, &edge.FollowingUsername); err != nil {
+			return err
+		}
+		if err := fn(&edge); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -1,8 +1,12 @@
 package repository
 
 import (
-	"github.com/stretchr/testify/mock"
+	"context"
+	"time"
+	"user-ms/src/dto"
 	"user-ms/src/model"
+
+	"github.com/stretchr/testify/mock"
 )
 
 type FollowerRepositoryMock struct {
@@ -22,15 +26,54 @@ func (f FollowerRepositoryMock) DeleteFollower(i int) error {
 	return args.Error(0)
 }
 
-func (f FollowerRepositoryMock) GetFollowing(i int) []model.Follower {
+func (f FollowerRepositoryMock) GetFollowing(i int, s string) []model.Follower {
 	panic("implement me")
 }
 
-func (f FollowerRepositoryMock) GetFollowers(i int) []model.Follower {
+func (f FollowerRepositoryMock) GetFollowers(i int, s string) []model.Follower {
 	panic("implement me")
 }
 
-func (f FollowerRepositoryMock) RemoveFollowing(i int, i2 int) error {
+func (f FollowerRepositoryMock) RemoveFollowing(i int, i2 int) (bool, error) {
+	args := f.Called(i, i2)
+	return args.Bool(0), args.Error(1)
+}
+
+func (f FollowerRepositoryMock) RemoveFollower(i int, i2 int) error {
 	args := f.Called(i, i2)
 	return args.Error(0)
 }
+
+func (f FollowerRepositoryMock) AddFollowAttempt(attempt *model.FollowAttempt) error {
+	args := f.Called(attempt)
+	return args.Error(0)
+}
+
+func (f FollowerRepositoryMock) CountFollowAttemptsSince(i int, since time.Time) (int, *time.Time) {
+	args := f.Called(i, since)
+	if args.Get(1) == nil {
+		return args.Int(0), nil
+	}
+	return args.Int(0), args.Get(1).(*time.Time)
+}
+
+func (f FollowerRepositoryMock) DeleteFollowAttemptsBefore(cutoff time.Time) error {
+	args := f.Called(cutoff)
+	return args.Error(0)
+}
+
+func (f FollowerRepositoryMock) StreamEdges(filter FollowEdgeFilter, fn func(*dto.FollowEdgeDTO) error) error {
+	args := f.Called(filter)
+	if edges, ok := args.Get(0).([]dto.FollowEdgeDTO); ok {
+		for i := range edges {
+			if err := fn(&edges[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (f *FollowerRepositoryMock) WithContext(ctx context.Context) IFollowerRepository {
+	return f
+}
@@ -1,18 +1,30 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
 	"user-ms/src/model"
+	"user-ms/src/tracing"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
+// ErrRequestNotPending is returned when a request that doesn't exist, or was
+// already decided or expired, is decided.
+var ErrRequestNotPending = errors.New("following request not found or no longer pending")
+
 type IFollowingRequestRepository interface {
 	AddFollowingRequest(*model.FollowingRequest) (int, error)
 	UpdateFollowingRequest(int, *model.FollowingRequest) (*model.FollowingRequest, error)
 	DeleteFollowingRequest(int) error
-	GetRequests() []model.FollowingRequest
-	GetRequestsByFollowingID(int) []model.FollowingRequest
+	GetRequests(string) []model.FollowingRequest
+	GetRequestsByFollowingID(int, string) []model.FollowingRequest
+	ExpireRequests(time.Time) ([]model.FollowingRequest, error)
+	CountRequestsSince(int, time.Time) (int, *time.Time)
+	GetLastRejected(int, int) (*model.FollowingRequest, error)
+	WithContext(context.Context) IFollowingRequestRepository
 }
 
 func NewFollowingRequestRepository(database *gorm.DB) IFollowingRequestRepository {
@@ -25,6 +37,11 @@ type FollowingRequestRepository struct {
 	Database *gorm.DB
 }
 
+// WithContext returns the repository with its queries traced under ctx.
+func (repo *FollowingRequestRepository) WithContext(ctx context.Context) IFollowingRequestRepository {
+	return &FollowingRequestRepository{tracing.WithContext(repo.Database, ctx)}
+}
+
 func (repo *FollowingRequestRepository) AddFollowingRequest(followingRequest *model.FollowingRequest) (int, error) {
 	result := repo.Database.Create(followingRequest)
 
@@ -35,15 +52,23 @@ func (repo *FollowingRequestRepository) AddFollowingRequest(followingRequest *mo
 	return followingRequest.ID, nil
 }
 
+// UpdateFollowingRequest records a decision on the request reqId. Only a
+// pending request can be decided; any other yields ErrRequestNotPending. Who
+// follows whom is taken from the stored request, and the followed user, the
+// only one who can decide, is recorded as the actor. The update is a single
+// statement, so two concurrent decisions can't both succeed.
 func (repo *FollowingRequestRepository) UpdateFollowingRequest(reqId int, followingRequest *model.FollowingRequest) (*model.FollowingRequest, error) {
-	followingRequest.ID = reqId
-	result := repo.Database.Save(followingRequest)
-
+	var updated []model.FollowingRequest
+	result := repo.Database.Raw("update following_requests set request_status = ?, decided_at = ?, updated_at = ?, actor_id = following_id where id = ? and request_status = ? returning *",
+		followingRequest.RequestStatus, followingRequest.DecidedAt, time.Now(), reqId, model.PENDING).Scan(&updated)
 	if result.Error != nil {
 		return nil, result.Error
 	}
+	if len(updated) == 0 {
+		return nil, ErrRequestNotPending
+	}
 
-	return followingRequest, nil
+	return &updated[0], nil
 }
 
 func (repo *FollowingRequestRepository) DeleteFollowingRequest(id int) error {
@@ -56,14 +81,48 @@ func (repo *FollowingRequestRepository) DeleteFollowingRequest(id int) error {
 	return nil
 }
 
-func (repo *FollowingRequestRepository) GetRequests() []model.FollowingRequest {
+func (repo *FollowingRequestRepository) GetRequests(orderBy string) []model.FollowingRequest {
 	var req []model.FollowingRequest
-	repo.Database.Find(&req)
+	repo.Database.Order(orderBy).Find(&req)
 	return req
 }
 
-func (repo *FollowingRequestRepository) GetRequestsByFollowingID(id int) []model.FollowingRequest {
+func (repo *FollowingRequestRepository) GetRequestsByFollowingID(id int, orderBy string) []model.FollowingRequest {
 	var req []model.FollowingRequest
-	repo.Database.Where("following_id = ? and request_status = 0", id).Find(&req)
+	repo.Database.Where("following_id = ? and request_status = ?", id, model.PENDING).Order(orderBy).Find(&req)
 	return req
 }
+
+// ExpireRequests moves every pending request created before cutoff into the
+// EXPIRED status and returns the rows it changed. The update is a single
+// statement, so when several replicas run it concurrently each request is
+// expired (and reported) by exactly one of them.
+func (repo *FollowingRequestRepository) ExpireRequests(cutoff time.Time) ([]model.FollowingRequest, error) {
+	var expired []model.FollowingRequest
+	now := time.Now()
+	result := repo.Database.Raw("update following_requests set request_status = ?, decided_at = ?, updated_at = ? where request_status = ? and created_at < ? returning *",
+		model.EXPIRED, now, now, model.PENDING, cutoff).Scan(&expired)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return expired, nil
+}
+
+// CountRequestsSince returns how many requests the follower sent since the
+// given time, together with the creation time of the oldest one of them.
+func (repo *FollowingRequestRepository) CountRequestsSince(followerId int, since time.Time) (int, *time.Time) {
+	var stats windowStats
+	repo.Database.Model(&model.FollowingRequest{}).Select("count(*) as count, min(created_at) as oldest").
+		Where("follower_id = ? and created_at >= ?", followerId, since).Scan(&stats)
+	return stats.Count, stats.Oldest
+}
+
+func (repo *FollowingRequestRepository) GetLastRejected(followerId int, followingId int) (*model.FollowingRequest, error) {
+	var req model.FollowingRequest
+	if err := repo.Database.Where("follower_id = ? and following_id = ? and request_status = ?", followerId, followingId, model.REJECTED).
+		Order("decided_at desc").First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
@@ -1,8 +1,11 @@
 package repository
 
 import (
-	"github.com/stretchr/testify/mock"
+	"context"
+	"time"
 	"user-ms/src/model"
+
+	"github.com/stretchr/testify/mock"
 )
 
 type FollowingRequestRepositoryMock struct {
@@ -30,10 +33,38 @@ func (f FollowingRequestRepositoryMock) DeleteFollowingRequest(i int) error {
 	return args.Error(0)
 }
 
-func (f FollowingRequestRepositoryMock) GetRequests() []model.FollowingRequest {
+func (f FollowingRequestRepositoryMock) GetRequests(s string) []model.FollowingRequest {
 	panic("implement me")
 }
 
-func (f FollowingRequestRepositoryMock) GetRequestsByFollowingID(i int) []model.FollowingRequest {
+func (f FollowingRequestRepositoryMock) GetRequestsByFollowingID(i int, s string) []model.FollowingRequest {
 	panic("implement me")
 }
+
+func (f FollowingRequestRepositoryMock) ExpireRequests(cutoff time.Time) ([]model.FollowingRequest, error) {
+	args := f.Called(cutoff)
+	if args.Get(1) == nil {
+		return args.Get(0).([]model.FollowingRequest), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (f FollowingRequestRepositoryMock) CountRequestsSince(i int, since time.Time) (int, *time.Time) {
+	args := f.Called(i, since)
+	if args.Get(1) == nil {
+		return args.Int(0), nil
+	}
+	return args.Int(0), args.Get(1).(*time.Time)
+}
+
+func (f FollowingRequestRepositoryMock) GetLastRejected(i int, i2 int) (*model.FollowingRequest, error) {
+	args := f.Called(i, i2)
+	if args.Get(1) == nil {
+		return args.Get(0).(*model.FollowingRequest), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (f *FollowingRequestRepositoryMock) WithContext(ctx context.Context) IFollowingRequestRepository {
+	return f
+}
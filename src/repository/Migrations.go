@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"time"
+	"user-ms/src/model"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// Migration is a one-off data change that AutoMigrate can't express, such as
+// a backfill.
+type Migration struct {
+	Name string
+	Up   func(*gorm.DB) error
+}
+
+// RunMigrations applies the migrations that haven't been applied yet, each in
+// its own transaction together with its schema_migrations row. A replica
+// starting at the same time blocks on the row and then skips the migration.
+func RunMigrations(database *gorm.DB, migrations []Migration) error {
+	if err := database.AutoMigrate(model.SchemaMigration{}).Error; err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		err := database.Transaction(func(tx *gorm.DB) error {
+			result := tx.Exec("INSERT INTO schema_migrations (name, applied_at) VALUES (?, ?) ON CONFLICT (name) DO NOTHING", migration.Name, time.Now())
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return migration.Up(tx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"user-ms/src/model"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+type INotificationPreferenceRepository interface {
+	GetPreferences(int) ([]model.NotificationPreference, error)
+	GetSchedule(int) (*model.NotificationSchedule, error)
+	SavePreferences(int, []model.NotificationPreference) error
+	SaveSchedule(*model.NotificationSchedule) error
+}
+
+func NewNotificationPreferenceRepository(database *gorm.DB) INotificationPreferenceRepository {
+	return &NotificationPreferenceRepository{
+		database,
+	}
+}
+
+type NotificationPreferenceRepository struct {
+	Database *gorm.DB
+}
+
+// GetPreferences returns the stored rows of the user's matrix. Types without
+// a row haven't been changed since the matrix was introduced.
+func (repo *NotificationPreferenceRepository) GetPreferences(userId int) ([]model.NotificationPreference, error) {
+	var preferences []model.NotificationPreference
+	if err := repo.Database.Where("user_id = ?", userId).Order("id").Find(&preferences).Error; err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// GetSchedule returns nil without an error when the user has no schedule.
+func (repo *NotificationPreferenceRepository) GetSchedule(userId int) (*model.NotificationSchedule, error) {
+	var schedule model.NotificationSchedule
+	result := repo.Database.Where("user_id = ?", userId).First(&schedule)
+	if result.RecordNotFound() {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &schedule, nil
+}
+
+// SavePreferences replaces the user's whole matrix, so it should run in a
+// transaction.
+func (repo *NotificationPreferenceRepository) SavePreferences(userId int, preferences []model.NotificationPreference) error {
+	if err := repo.Database.Where("user_id = ?", userId).Delete(&model.NotificationPreference{}).Error; err != nil {
+		return err
+	}
+
+	for i := range preferences {
+		preferences[i].ID = 0
+		preferences[i].UserId = userId
+		if err := repo.Database.Create(&preferences[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *NotificationPreferenceRepository) SaveSchedule(schedule *model.NotificationSchedule) error {
+	if err := repo.Database.Where("user_id = ?", schedule.UserId).Delete(&model.NotificationSchedule{}).Error; err != nil {
+		return err
+	}
+
+	schedule.ID = 0
+	return repo.Database.Create(schedule).Error
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"user-ms/src/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type NotificationPreferenceRepositoryMock struct {
+	mock.Mock
+}
+
+func (n *NotificationPreferenceRepositoryMock) GetPreferences(userId int) ([]model.NotificationPreference, error) {
+	args := n.Called(userId)
+	if args.Get(1) == nil {
+		return args.Get(0).([]model.NotificationPreference), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (n *NotificationPreferenceRepositoryMock) GetSchedule(userId int) (*model.NotificationSchedule, error) {
+	args := n.Called(userId)
+	if args.Get(1) == nil {
+		schedule, _ := args.Get(0).(*model.NotificationSchedule)
+		return schedule, nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (n *NotificationPreferenceRepositoryMock) SavePreferences(userId int, preferences []model.NotificationPreference) error {
+	args := n.Called(userId, preferences)
+	return args.Error(0)
+}
+
+func (n *NotificationPreferenceRepositoryMock) SaveSchedule(schedule *model.NotificationSchedule) error {
+	args := n.Called(schedule)
+	return args.Error(0)
+}
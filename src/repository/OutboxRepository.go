@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+	"user-ms/src/model"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+type IOutboxRepository interface {
+	Add(*model.OutboxMessage) error
+	ClaimPending(int) ([]model.OutboxMessage, error)
+	MarkDelivered(int, string) error
+	MarkFailed(int, string) error
+	RecordError(int, string) error
+	MarkDead(int, string) error
+	DeleteDeliveredBefore(time.Time) error
+}
+
+func NewOutboxRepository(database *gorm.DB) IOutboxRepository {
+	return &OutboxRepository{
+		database,
+	}
+}
+
+type OutboxRepository struct {
+	Database *gorm.DB
+}
+
+func (repo *OutboxRepository) Add(message *model.OutboxMessage) error {
+	return repo.Database.Create(message).Error
+}
+
+// ClaimPending locks up to limit undelivered messages, oldest first. Rows
+// locked by another replica are skipped, so it must run inside a transaction
+// that stays open until the claimed messages are marked.
+func (repo *OutboxRepository) ClaimPending(limit int) ([]model.OutboxMessage, error) {
+	var messages []model.OutboxMessage
+	result := repo.Database.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("delivered_at is null and failed_at is null").Order("id").Limit(limit).Find(&messages)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return messages, nil
+}
+
+// MarkDelivered records that the broker accepted the message. lastError keeps
+// a note for messages the broker accepted but couldn't route.
+func (repo *OutboxRepository) MarkDelivered(id int, lastError string) error {
+	return repo.Database.Model(&model.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"delivered_at": time.Now(), "attempts": gorm.Expr("attempts + 1"), "last_error": lastError}).Error
+}
+
+func (repo *OutboxRepository) MarkFailed(id int, lastError string) error {
+	return repo.Database.Model(&model.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "last_error": lastError}).Error
+}
+
+// RecordError notes why a message couldn't be sent without counting it as an
+// attempt, for failures that aren't the message's fault.
+func (repo *OutboxRepository) RecordError(id int, lastError string) error {
+	return repo.Database.Model(&model.OutboxMessage{}).Where("id = ?", id).
+		Update("last_error", lastError).Error
+}
+
+// MarkDead gives up on a message; it isn't claimed again.
+func (repo *OutboxRepository) MarkDead(id int, lastError string) error {
+	return repo.Database.Model(&model.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"failed_at": time.Now(), "attempts": gorm.Expr("attempts + 1"), "last_error": lastError}).Error
+}
+
+func (repo *OutboxRepository) DeleteDeliveredBefore(cutoff time.Time) error {
+	return repo.Database.Where("delivered_at < ?", cutoff).Delete(&model.OutboxMessage{}).Error
+}
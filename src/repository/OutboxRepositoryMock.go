@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"time"
+	"user-ms/src/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type OutboxRepositoryMock struct {
+	mock.Mock
+}
+
+func (o *OutboxRepositoryMock) Add(message *model.OutboxMessage) error {
+	args := o.Called(message)
+	return args.Error(0)
+}
+
+func (o *OutboxRepositoryMock) ClaimPending(limit int) ([]model.OutboxMessage, error) {
+	args := o.Called(limit)
+	if args.Get(1) == nil {
+		return args.Get(0).([]model.OutboxMessage), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (o *OutboxRepositoryMock) MarkDelivered(id int, lastError string) error {
+	args := o.Called(id, lastError)
+	return args.Error(0)
+}
+
+func (o *OutboxRepositoryMock) MarkFailed(id int, lastError string) error {
+	args := o.Called(id, lastError)
+	return args.Error(0)
+}
+
+func (o *OutboxRepositoryMock) RecordError(id int, lastError string) error {
+	args := o.Called(id, lastError)
+	return args.Error(0)
+}
+
+func (o *OutboxRepositoryMock) MarkDead(id int, lastError string) error {
+	args := o.Called(id, lastError)
+	return args.Error(0)
+}
+
+func (o *OutboxRepositoryMock) DeleteDeliveredBefore(cutoff time.Time) error {
+	args := o.Called(cutoff)
+	return args.Error(0)
+}
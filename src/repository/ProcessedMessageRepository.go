@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"time"
+	"user-ms/src/model"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+type IProcessedMessageRepository interface {
+	MarkProcessed(*model.ProcessedMessage) (bool, error)
+	DeleteProcessedBefore(time.Time) error
+}
+
+func NewProcessedMessageRepository(database *gorm.DB) IProcessedMessageRepository {
+	return &ProcessedMessageRepository{
+		database,
+	}
+}
+
+type ProcessedMessageRepository struct {
+	Database *gorm.DB
+}
+
+// MarkProcessed records the message and reports whether this is the first
+// time it was seen. A message already recorded, possibly by another replica,
+// yields false.
+func (repo *ProcessedMessageRepository) MarkProcessed(message *model.ProcessedMessage) (bool, error) {
+	result := repo.Database.Exec("INSERT INTO processed_messages (message_id, type, processed_at) VALUES (?, ?, ?) ON CONFLICT (message_id) DO NOTHING",
+		message.MessageId, message.Type, message.ProcessedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (repo *ProcessedMessageRepository) DeleteProcessedBefore(cutoff time.Time) error {
+	return repo.Database.Where("processed_at < ?", cutoff).Delete(&model.ProcessedMessage{}).Error
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"time"
+	"user-ms/src/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type ProcessedMessageRepositoryMock struct {
+	mock.Mock
+}
+
+func (p *ProcessedMessageRepositoryMock) MarkProcessed(message *model.ProcessedMessage) (bool, error) {
+	args := p.Called(message)
+	return args.Bool(0), args.Error(1)
+}
+
+func (p *ProcessedMessageRepositoryMock) DeleteProcessedBefore(cutoff time.Time) error {
+	args := p.Called(cutoff)
+	return args.Error(0)
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var followSortColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"decided_at": true,
+}
+
+// FollowOrderBy builds an ORDER BY clause for follower and following request
+// listings. Only known timestamp columns are accepted so the result is safe to
+// pass to gorm. An empty key keeps the default ordering by id.
+func FollowOrderBy(key string, direction string) (string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" {
+		key = "id"
+	}
+	if !followSortColumns[key] {
+		return "", errors.New(fmt.Sprintf("Can't sort by %s", key))
+	}
+
+	switch strings.ToLower(strings.TrimSpace(direction)) {
+	case "", "asc":
+		return key + " asc", nil
+	case "desc":
+		return key + " desc", nil
+	default:
+		return "", errors.New(fmt.Sprintf("Unknown sort order %s", direction))
+	}
+}
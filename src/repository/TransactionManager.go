@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"user-ms/src/tracing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// Repositories groups the repositories bound to one transaction.
+type Repositories struct {
+	Users                   IUserRepository
+	Followers               IFollowerRepository
+	FollowingRequests       IFollowingRequestRepository
+	Connections             IConnectionRepository
+	Outbox                  IOutboxRepository
+	ProcessedMessages       IProcessedMessageRepository
+	NotificationPreferences INotificationPreferenceRepository
+}
+
+type ITransactionManager interface {
+	Transaction(func(*Repositories) error) error
+	WithContext(context.Context) ITransactionManager
+}
+
+func NewTransactionManager(database *gorm.DB) ITransactionManager {
+	return &TransactionManager{
+		database,
+	}
+}
+
+type TransactionManager struct {
+	Database *gorm.DB
+}
+
+// WithContext returns a manager whose transactions are traced under ctx.
+func (manager *TransactionManager) WithContext(ctx context.Context) ITransactionManager {
+	return &TransactionManager{tracing.WithContext(manager.Database, ctx)}
+}
+
+// Transaction runs fn with repositories that share a single database
+// transaction. The transaction is committed when fn returns nil and rolled
+// back otherwise.
+func (manager *TransactionManager) Transaction(fn func(*Repositories) error) error {
+	tx := manager.Database.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	repositories := &Repositories{
+		Users:                   &UserRepository{Database: tx},
+		Followers:               &FollowerRepository{Database: tx},
+		FollowingRequests:       &FollowingRequestRepository{Database: tx},
+		Connections:             &ConnectionRepository{Database: tx},
+		Outbox:                  &OutboxRepository{Database: tx},
+		ProcessedMessages:       &ProcessedMessageRepository{Database: tx},
+		NotificationPreferences: &NotificationPreferenceRepository{Database: tx},
+	}
+
+	if err := fn(repositories); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
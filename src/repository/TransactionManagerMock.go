@@ -0,0 +1,17 @@
+package repository
+
+import "context"
+
+// TransactionManagerMock runs every transaction against the given (mocked)
+// repositories.
+type TransactionManagerMock struct {
+	Repositories *Repositories
+}
+
+func (m *TransactionManagerMock) Transaction(fn func(*Repositories) error) error {
+	return fn(m.Repositories)
+}
+
+func (m *TransactionManagerMock) WithContext(ctx context.Context) ITransactionManager {
+	return m
+}
@@ -1,17 +1,22 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"user-ms/src/dto"
 	"user-ms/src/mapper"
 	"user-ms/src/model"
+	"user-ms/src/tracing"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
+// ErrUserNotFound is returned by GetByAuth0ID when no user has the id.
+var ErrUserNotFound = errors.New("user not found")
+
 type IUserRepository interface {
 	AddUser(*model.User) (int, error)
 	DeleteUser(int) error
@@ -20,11 +25,14 @@ type IUserRepository interface {
 	GetByAuth0ID(string) (*model.User, error)
 	GetByEmail(string) (*dto.UserResponseDTO, error)
 	GetByUsername(string) []model.User
+	GetByExactUsername(string) (*model.User, error)
 	UnblockUser(int, int) error
 	GetBlockedUsers(int) []model.User
 	CreateAdmin([]model.User)
 	GetBySearchParam(param string) ([]*dto.UserResponseDTO, error)
 	GetAll() ([]*dto.UserResponseDTO, error)
+	StreamUsers(bool, func(*model.User) error) error
+	WithContext(context.Context) IUserRepository
 }
 
 func NewUserRepository(database *gorm.DB) IUserRepository {
@@ -37,6 +45,11 @@ type UserRepository struct {
 	Database *gorm.DB
 }
 
+// WithContext returns the repository with its queries traced under ctx.
+func (repo *UserRepository) WithContext(ctx context.Context) IUserRepository {
+	return &UserRepository{tracing.WithContext(repo.Database, ctx)}
+}
+
 func (repo *UserRepository) AddUser(user *model.User) (int, error) {
 	result := repo.Database.Create(user)
 
@@ -83,7 +96,10 @@ func (repo *UserRepository) GetByAuth0ID(id string) (*model.User, error) {
 		Auth0ID: id,
 	}
 	if err := repo.Database.Where("auth0_id = ?", id).First(&userEntity).Error; err != nil {
-		return nil, errors.New(fmt.Sprintf("User with auth0_ID %s not found", id))
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, fmt.Errorf("%w: auth0_ID %s", ErrUserNotFound, id)
+		}
+		return nil, err
 	}
 
 	return &userEntity, nil
@@ -131,6 +147,15 @@ func (repo *UserRepository) GetByUsername(username string) []model.User {
 	return req
 }
 
+func (repo *UserRepository) GetByExactUsername(username string) (*model.User, error) {
+	var userEntity model.User
+	if err := repo.Database.Where("username = ?", username).First(&userEntity).Error; err != nil {
+		return nil, errors.New(fmt.Sprintf("User with username %s not found", username))
+	}
+
+	return &userEntity, nil
+}
+
 func (repo *UserRepository) UnblockUser(blockingID int, userID int) error {
 	result := repo.Database.Exec(fmt.Sprintf("delete from user_blocked where user_id = %d and blocked_id = %d", userID, blockingID))
 	return result.Error
@@ -153,3 +178,30 @@ func (repo *UserRepository) CreateAdmin(admins []model.User) {
 		}
 	}
 }
+
+// StreamUsers calls fn for every user (only active ones when activeOnly is
+// set) without loading the whole table into memory.
+func (repo *UserRepository) StreamUsers(activeOnly bool, fn func(*model.User) error) error {
+	query := repo.Database.Model(&model.User{})
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+
+	rows, err := query.Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user model.User
+		if err := repo.Database.ScanRows(rows, &user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
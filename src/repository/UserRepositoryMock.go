@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"user-ms/src/dto"
 	"user-ms/src/model"
 
@@ -36,7 +37,11 @@ func (u *UserRepositoryMock) AddUser(user *model.User) (int, error) {
 }
 
 func (u *UserRepositoryMock) DeleteUser(id int) error {
-	panic("implement me")
+	args := u.Called(id)
+	if args.Get(0) != nil {
+		return args.Get(0).(error)
+	}
+	return nil
 }
 
 func (u *UserRepositoryMock) Update(user *model.User) (*dto.UserResponseDTO, error) {
@@ -93,3 +98,27 @@ func (u *UserRepositoryMock) CreateAdmin([]model.User) {
 func (u *UserRepositoryMock) GetByUsername(username string) []model.User {
 	panic("")
 }
+
+func (u *UserRepositoryMock) StreamUsers(activeOnly bool, fn func(*model.User) error) error {
+	args := u.Called(activeOnly)
+	if users, ok := args.Get(0).([]model.User); ok {
+		for i := range users {
+			if err := fn(&users[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (u *UserRepositoryMock) GetByExactUsername(username string) (*model.User, error) {
+	args := u.Called(username)
+	if args.Get(1) == nil {
+		return args.Get(0).(*model.User), nil
+	}
+	return nil, args.Get(1).(error)
+}
+
+func (u *UserRepositoryMock) WithContext(ctx context.Context) IUserRepository {
+	return u
+}
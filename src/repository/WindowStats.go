@@ -0,0 +1,10 @@
+package repository
+
+import "time"
+
+// windowStats is the row shape of the sliding-window counting queries used
+// for follow rate limiting.
+type windowStats struct {
+	Count  int
+	Oldest *time.Time
+}
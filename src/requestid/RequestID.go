@@ -0,0 +1,83 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// Header carries the request ID on HTTP requests and responses.
+	Header = "X-Request-ID"
+	// AMQPHeader carries the request ID on RabbitMQ messages.
+	AMQPHeader = "x-request-id"
+
+	maxLength = 128
+)
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID in ctx, or "" when there is none.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// SetHeader adds the request ID in ctx to the headers of an outgoing request.
+func SetHeader(ctx context.Context, header http.Header) {
+	if id := FromContext(ctx); id != "" {
+		header.Set(Header, id)
+	}
+}
+
+// Generate returns a new random request ID.
+func Generate() string {
+	id, _ := uuid.NewV4()
+	return id.String()
+}
+
+// Valid reports whether id is safe to accept from a caller and pass on: at
+// most 128 characters of letters, digits and -_.:/
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware accepts the caller's X-Request-ID, or generates one when it is
+// missing or invalid, puts it in the request context and returns it in the
+// response.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(Header)
+		if !Valid(id) {
+			id = Generate()
+		}
+
+		ctx.Request.Header.Set(Header, id)
+		ctx.Request = ctx.Request.WithContext(NewContext(ctx.Request.Context(), id))
+		ctx.Header(Header, id)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestIDTestsSuite struct {
+	suite.Suite
+	router *gin.Engine
+	seen   string
+}
+
+func TestRequestIDTestsSuite(t *testing.T) {
+	suite.Run(t, new(RequestIDTestsSuite))
+}
+
+func (suite *RequestIDTestsSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.router = gin.New()
+	suite.router.Use(Middleware())
+	suite.router.GET("/", func(ctx *gin.Context) {
+		suite.seen = FromContext(ctx.Request.Context())
+	})
+}
+
+func (suite *RequestIDTestsSuite) serve(id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if id != "" {
+		req.Header.Set(Header, id)
+	}
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (suite *RequestIDTestsSuite) TestMiddleware_AcceptsCallerID() {
+	recorder := suite.serve("gateway-1:abc")
+
+	assert.Equal(suite.T(), "gateway-1:abc", suite.seen)
+	assert.Equal(suite.T(), "gateway-1:abc", recorder.Header().Get(Header))
+}
+
+func (suite *RequestIDTestsSuite) TestMiddleware_GeneratesID() {
+	recorder := suite.serve("")
+
+	assert.Len(suite.T(), suite.seen, 36)
+	assert.Equal(suite.T(), suite.seen, recorder.Header().Get(Header))
+}
+
+func (suite *RequestIDTestsSuite) TestMiddleware_ReplacesInvalidID() {
+	recorder := suite.serve("abc\r\nX-Injected: 1")
+
+	assert.Len(suite.T(), suite.seen, 36)
+	assert.Equal(suite.T(), suite.seen, recorder.Header().Get(Header))
+}
+
+func (suite *RequestIDTestsSuite) TestValid() {
+	assert.True(suite.T(), Valid("0f8fad5b-d9cb-469f-a165-70867728950e"))
+	assert.False(suite.T(), Valid(""))
+	assert.False(suite.T(), Valid("with space"))
+	assert.False(suite.T(), Valid(strings.Repeat("a", 129)))
+}
+
+func (suite *RequestIDTestsSuite) TestSetHeader() {
+	header := http.Header{}
+	SetHeader(context.Background(), header)
+	assert.Empty(suite.T(), header.Get(Header))
+
+	SetHeader(NewContext(context.Background(), "abc"), header)
+	assert.Equal(suite.T(), "abc", header.Get(Header))
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"user-ms/src/dto"
+	"user-ms/src/mapper"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ConnectionService struct {
+	ConnectionRepository repository.IConnectionRepository
+	UserRepository       repository.IUserRepository
+	Transactions         repository.ITransactionManager
+	NotificationPolicy   INotificationPolicy
+	Logger               *logrus.Entry
+}
+
+type IConnectionService interface {
+	Invite(context.Context, string, int) (int, error)
+	Accept(context.Context, string, int) (*dto.ConnectionDTO, error)
+	Remove(string, int) error
+	GetConnections(int) []dto.ConnectionDTO
+	GetInvitations(string) ([]dto.ConnectionDTO, error)
+	AreConnected(int, int) bool
+}
+
+func NewConnectionService(connectionRepository repository.IConnectionRepository, userRepository repository.IUserRepository, transactions repository.ITransactionManager, notificationPolicy INotificationPolicy, logger *logrus.Entry) IConnectionService {
+	return &ConnectionService{
+		connectionRepository,
+		userRepository,
+		transactions,
+		notificationPolicy,
+		logger,
+	}
+}
+
+func (service *ConnectionService) Invite(ctx context.Context, inviterAuth0ID string, inviteeId int) (int, error) {
+	service.Logger.Info(fmt.Sprintf("User with auth0 id %s inviting user with id %d to connect", inviterAuth0ID, inviteeId))
+	inviter, err := service.UserRepository.GetByAuth0ID(inviterAuth0ID)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return -1, err
+	}
+
+	if inviter.ID == inviteeId {
+		service.Logger.Debug("User can't connect with himself")
+		return -1, errors.New("can't connect with yourself")
+	}
+
+	invitee, err := service.UserRepository.GetByID(inviteeId)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return -1, err
+	}
+
+	connection := model.Connection{InviterId: inviter.ID, InviteeId: invitee.ID, RequestStatus: model.PENDING}
+
+	var connectionId int
+	err = service.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		connectionId, err = repositories.Connections.AddConnection(&connection)
+		if err != nil {
+			return err
+		}
+
+		notification, err := NewNotification(invitee, inviter, ConnectionInvitedTemplate, nil)
+		if err != nil {
+			return err
+		}
+
+		service.Logger.Info("Adding connection invitation notification to the outbox")
+		return enqueueNotification(ctx, repositories.Outbox, service.NotificationPolicy, invitee, notification)
+	})
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return -1, err
+	}
+
+	return connectionId, nil
+}
+
+func (service *ConnectionService) Accept(ctx context.Context, inviteeAuth0ID string, connectionId int) (*dto.ConnectionDTO, error) {
+	service.Logger.Info(fmt.Sprintf("Accepting connection with id %d", connectionId))
+	invitee, err := service.UserRepository.GetByAuth0ID(inviteeAuth0ID)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	connection, err := service.ConnectionRepository.GetByID(connectionId)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	if connection.InviteeId != invitee.ID || connection.RequestStatus != model.PENDING {
+		service.Logger.Debug(fmt.Sprintf("User with id %d can't accept connection with id %d", invitee.ID, connectionId))
+		return nil, errors.New("can't accept the connection")
+	}
+
+	connection.RequestStatus = model.ACCEPTED
+	err = service.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		connection, err = repositories.Connections.UpdateConnection(connection)
+		if err != nil {
+			return err
+		}
+
+		inviter, _ := repositories.Users.GetByID(connection.InviterId)
+
+		notification, err := NewNotification(inviter, invitee, ConnectionAcceptedTemplate, nil)
+		if err != nil {
+			return err
+		}
+
+		service.Logger.Info("Adding accepted connection notification to the outbox")
+		return enqueueNotification(ctx, repositories.Outbox, service.NotificationPolicy, inviter, notification)
+	})
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	return mapper.ConnectionToDTO(connection), nil
+}
+
+// Remove lets either side withdraw a pending invitation or end an accepted
+// connection. The other side is not notified.
+func (service *ConnectionService) Remove(userAuth0ID string, connectionId int) error {
+	service.Logger.Info(fmt.Sprintf("Removing connection with id %d", connectionId))
+	user, err := service.UserRepository.GetByAuth0ID(userAuth0ID)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return err
+	}
+
+	connection, err := service.ConnectionRepository.GetByID(connectionId)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return err
+	}
+
+	if connection.InviterId != user.ID && connection.InviteeId != user.ID {
+		service.Logger.Debug(fmt.Sprintf("User with id %d is not part of connection with id %d", user.ID, connectionId))
+		return errors.New("can't remove the connection")
+	}
+
+	if err := service.ConnectionRepository.DeleteConnection(connectionId); err != nil {
+		service.Logger.Debug(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func (service *ConnectionService) GetConnections(userId int) []dto.ConnectionDTO {
+	service.Logger.Info(fmt.Sprintf("Getting connections for user with id %d", userId))
+	connections := service.ConnectionRepository.GetConnections(userId)
+
+	res := make([]dto.ConnectionDTO, len(connections))
+	for i := 0; i < len(connections); i++ {
+		res[i] = *mapper.ConnectionToDTO(&connections[i])
+	}
+	return res
+}
+
+func (service *ConnectionService) GetInvitations(userAuth0ID string) ([]dto.ConnectionDTO, error) {
+	service.Logger.Info(fmt.Sprintf("Getting connection invitations for user %s", userAuth0ID))
+	user, err := service.UserRepository.GetByAuth0ID(userAuth0ID)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	invitations := service.ConnectionRepository.GetInvitations(user.ID)
+
+	res := make([]dto.ConnectionDTO, len(invitations))
+	for i := 0; i < len(invitations); i++ {
+		res[i] = *mapper.ConnectionToDTO(&invitations[i])
+	}
+	return res, nil
+}
+
+// AreConnected reports whether two users share an accepted connection. Other
+// services (e.g. messaging) use it to decide whether users may interact.
+func (service *ConnectionService) AreConnected(userId int, otherUserId int) bool {
+	connection, err := service.ConnectionRepository.GetBetween(userId, otherUserId)
+	if err != nil {
+		return false
+	}
+	return connection.RequestStatus == model.ACCEPTED
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"user-ms/src/logging"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConnectionTestsSuite struct {
+	suite.Suite
+	connectionRepositoryMock *repository.ConnectionRepositoryMock
+	userRepositoryMock       *repository.UserRepositoryMock
+	service                  IConnectionService
+}
+
+func TestConnectionTestsSuite(t *testing.T) {
+	suite.Run(t, new(ConnectionTestsSuite))
+}
+
+func (suite *ConnectionTestsSuite) SetupSuite() {
+	suite.connectionRepositoryMock = new(repository.ConnectionRepositoryMock)
+	suite.userRepositoryMock = new(repository.UserRepositoryMock)
+	suite.service = NewConnectionService(suite.connectionRepositoryMock, suite.userRepositoryMock, &repository.TransactionManagerMock{}, NewNotificationPolicy(suite.userRepositoryMock, nil, logging.Logger()), logging.Logger())
+}
+
+func (suite *ConnectionTestsSuite) TestNewConnectionService() {
+	assert.NotNil(suite.T(), suite.service, "Service is nil")
+}
+
+func (suite *ConnectionTestsSuite) TestInvite_Yourself() {
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|1").Return(&model.User{ID: 1}, nil).Once()
+
+	id, err := suite.service.Invite(context.Background(), "auth0|1", 1)
+
+	assert.Equal(suite.T(), -1, id)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ConnectionTestsSuite) TestAccept_NotInvitee() {
+	connection := model.Connection{ID: 10, InviterId: 1, InviteeId: 2, RequestStatus: model.PENDING}
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|1").Return(&model.User{ID: 1}, nil).Once()
+	suite.connectionRepositoryMock.On("GetByID", 10).Return(&connection, nil).Once()
+
+	connectionDTO, err := suite.service.Accept(context.Background(), "auth0|1", 10)
+
+	assert.Nil(suite.T(), connectionDTO)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ConnectionTestsSuite) TestRemove_NotPartOfConnection() {
+	connection := model.Connection{ID: 11, InviterId: 1, InviteeId: 2, RequestStatus: model.ACCEPTED}
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|3").Return(&model.User{ID: 3}, nil).Once()
+	suite.connectionRepositoryMock.On("GetByID", 11).Return(&connection, nil).Once()
+
+	err := suite.service.Remove("auth0|3", 11)
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ConnectionTestsSuite) TestRemove_ByInvitee() {
+	connection := model.Connection{ID: 12, InviterId: 1, InviteeId: 2, RequestStatus: model.PENDING}
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|2").Return(&model.User{ID: 2}, nil).Once()
+	suite.connectionRepositoryMock.On("GetByID", 12).Return(&connection, nil).Once()
+	suite.connectionRepositoryMock.On("DeleteConnection", 12).Return(nil).Once()
+
+	err := suite.service.Remove("auth0|2", 12)
+
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ConnectionTestsSuite) TestAreConnected() {
+	suite.connectionRepositoryMock.On("GetBetween", 1, 2).Return(&model.Connection{RequestStatus: model.ACCEPTED}, nil).Once()
+	suite.connectionRepositoryMock.On("GetBetween", 1, 3).Return(&model.Connection{RequestStatus: model.PENDING}, nil).Once()
+	suite.connectionRepositoryMock.On("GetBetween", 1, 4).Return(nil, errors.New("Connection between users 1 and 4 not found")).Once()
+
+	assert.True(suite.T(), suite.service.AreConnected(1, 2))
+	assert.False(suite.T(), suite.service.AreConnected(1, 3))
+	assert.False(suite.T(), suite.service.AreConnected(1, 4))
+}
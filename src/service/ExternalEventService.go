@@ -0,0 +1,161 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/mapper"
+	"user-ms/src/model"
+	"user-ms/src/rabbitmq"
+	"user-ms/src/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Types of the events consumed from other services, as rabbitmq.Router
+// patterns. They match both CloudEvents types, e.g.
+// "dislinkt.posts.content.reported", and plain routing keys.
+const (
+	ContentReportedEvents      = "#.content.reported.#"
+	Auth0UserBlockedEvents     = "#.auth0.user.blocked.#"
+	Auth0PasswordChangedEvents = "#.auth0.user.password_changed.#"
+)
+
+// ExternalEventService applies events from other services to users. Every
+// event is handled in one transaction together with recording its message ID,
+// so a redelivered event changes nothing.
+type ExternalEventService struct {
+	Transactions    repository.ITransactionManager
+	ReportThreshold int
+	Logger          *logrus.Entry
+}
+
+type IExternalEventService interface {
+	ContentReported(rabbitmq.Delivery) error
+	UserBlocked(rabbitmq.Delivery) error
+	PasswordChanged(rabbitmq.Delivery) error
+}
+
+func NewExternalEventService(transactions repository.ITransactionManager, reportThreshold int, logger *logrus.Entry) IExternalEventService {
+	return &ExternalEventService{
+		transactions,
+		reportThreshold,
+		logger,
+	}
+}
+
+func RegisterExternalEvents(router *rabbitmq.Router, service IExternalEventService) {
+	router.Handle(ContentReportedEvents, service.ContentReported)
+	router.Handle(Auth0UserBlockedEvents, service.UserBlocked)
+	router.Handle(Auth0PasswordChangedEvents, service.PasswordChanged)
+}
+
+// ContentReported counts a report against the author and flags them once the
+// count reaches ReportThreshold.
+func (service *ExternalEventService) ContentReported(delivery rabbitmq.Delivery) error {
+	var event dto.ContentReportedEventDTO
+	if err := decodeEvent(delivery, &event); err != nil {
+		return err
+	}
+	if event.AuthorAuth0ID == "" {
+		return rabbitmq.Permanent(errors.New("content report without an author"))
+	}
+
+	return service.process(delivery, func(repositories *repository.Repositories) error {
+		user, err := getByAuth0ID(repositories, event.AuthorAuth0ID)
+		if err != nil {
+			return err
+		}
+
+		user.ReportCount++
+		if !user.Flagged && service.ReportThreshold > 0 && user.ReportCount >= service.ReportThreshold {
+			user.Flagged = true
+			service.Logger.Warn(fmt.Sprintf("User with id %d flagged after %d reports", user.ID, user.ReportCount))
+		}
+
+		_, err = repositories.Users.Update(user)
+		return err
+	})
+}
+
+// UserBlocked deactivates a user whose Auth0 account was blocked.
+func (service *ExternalEventService) UserBlocked(delivery rabbitmq.Delivery) error {
+	var event dto.Auth0UserEventDTO
+	if err := decodeEvent(delivery, &event); err != nil {
+		return err
+	}
+
+	return service.process(delivery, func(repositories *repository.Repositories) error {
+		user, err := getByAuth0ID(repositories, event.UserAuth0ID)
+		if err != nil {
+			return err
+		}
+		if !user.Active {
+			return nil
+		}
+
+		user.Active = false
+		if _, err := repositories.Users.Update(user); err != nil {
+			return err
+		}
+
+		service.Logger.Info(fmt.Sprintf("Deactivated user with id %d blocked in Auth0", user.ID))
+		return enqueueEvent(delivery.Context, repositories.Outbox, rabbitmq.UserDeactivated, mapper.UserToEventDTO(user))
+	})
+}
+
+// PasswordChanged records when the user last changed their password in Auth0.
+func (service *ExternalEventService) PasswordChanged(delivery rabbitmq.Delivery) error {
+	var event dto.Auth0UserEventDTO
+	if err := decodeEvent(delivery, &event); err != nil {
+		return err
+	}
+
+	return service.process(delivery, func(repositories *repository.Repositories) error {
+		user, err := getByAuth0ID(repositories, event.UserAuth0ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		user.PasswordChangedAt = &now
+		_, err = repositories.Users.Update(user)
+		return err
+	})
+}
+
+// process runs fn unless the delivery was processed before.
+func (service *ExternalEventService) process(delivery rabbitmq.Delivery, fn func(*repository.Repositories) error) error {
+	return service.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		processed := model.ProcessedMessage{MessageId: delivery.MessageId, Type: delivery.Type, ProcessedAt: time.Now()}
+		first, err := repositories.ProcessedMessages.MarkProcessed(&processed)
+		if err != nil {
+			return err
+		}
+		if !first {
+			service.Logger.Info(fmt.Sprintf("Skipping message %s, it was already processed", delivery.MessageId))
+			return nil
+		}
+
+		return fn(repositories)
+	})
+}
+
+// getByAuth0ID treats an unknown user as permanent: redelivering the event
+// won't make the user appear.
+func getByAuth0ID(repositories *repository.Repositories, auth0ID string) (*model.User, error) {
+	user, err := repositories.Users.GetByAuth0ID(auth0ID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, rabbitmq.Permanent(err)
+	}
+	return user, err
+}
+
+func decodeEvent(delivery rabbitmq.Delivery, event interface{}) error {
+	if err := json.Unmarshal(delivery.Data, event); err != nil {
+		return rabbitmq.Permanent(err)
+	}
+	return nil
+}
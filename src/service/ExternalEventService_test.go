@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/model"
+	"user-ms/src/rabbitmq"
+	"user-ms/src/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExternalEventServiceTestsSuite struct {
+	suite.Suite
+	userRepositoryMock             *repository.UserRepositoryMock
+	outboxRepositoryMock           *repository.OutboxRepositoryMock
+	processedMessageRepositoryMock *repository.ProcessedMessageRepositoryMock
+	service                        IExternalEventService
+}
+
+func TestExternalEventServiceTestsSuite(t *testing.T) {
+	suite.Run(t, new(ExternalEventServiceTestsSuite))
+}
+
+func (suite *ExternalEventServiceTestsSuite) SetupSuite() {
+	suite.userRepositoryMock = new(repository.UserRepositoryMock)
+	suite.outboxRepositoryMock = new(repository.OutboxRepositoryMock)
+	suite.processedMessageRepositoryMock = new(repository.ProcessedMessageRepositoryMock)
+	transactions := &repository.TransactionManagerMock{Repositories: &repository.Repositories{
+		Users:             suite.userRepositoryMock,
+		Outbox:            suite.outboxRepositoryMock,
+		ProcessedMessages: suite.processedMessageRepositoryMock,
+	}}
+	suite.service = NewExternalEventService(transactions, 2, logging.Logger())
+}
+
+func processed(messageId string) interface{} {
+	return mock.MatchedBy(func(message *model.ProcessedMessage) bool {
+		return message.MessageId == messageId
+	})
+}
+
+func (suite *ExternalEventServiceTestsSuite) TestContentReported_FlagsAtThreshold() {
+	user := &model.User{ID: 1, Auth0ID: "auth0|author", ReportCount: 1}
+	delivery := rabbitmq.Delivery{MessageId: "report-1", Type: "dislinkt.posts.content.reported", Data: []byte(`{"ContentId":"42","AuthorAuth0ID":"auth0|author"}`)}
+	suite.processedMessageRepositoryMock.On("MarkProcessed", processed("report-1")).Return(true, nil).Once()
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|author").Return(user, nil).Once()
+	suite.userRepositoryMock.On("Update", user).Return(&dto.UserResponseDTO{}, nil).Once()
+
+	err := suite.service.ContentReported(delivery)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, user.ReportCount)
+	assert.True(suite.T(), user.Flagged)
+}
+
+func (suite *ExternalEventServiceTestsSuite) TestContentReported_Duplicate() {
+	delivery := rabbitmq.Delivery{MessageId: "report-2", Data: []byte(`{"AuthorAuth0ID":"auth0|duplicate"}`)}
+	suite.processedMessageRepositoryMock.On("MarkProcessed", processed("report-2")).Return(false, nil).Once()
+
+	err := suite.service.ContentReported(delivery)
+
+	assert.Nil(suite.T(), err)
+	for _, call := range suite.userRepositoryMock.Calls {
+		assert.NotEqual(suite.T(), "auth0|duplicate", call.Arguments.Get(0))
+	}
+}
+
+func (suite *ExternalEventServiceTestsSuite) TestContentReported_MalformedIsPermanent() {
+	err := suite.service.ContentReported(rabbitmq.Delivery{MessageId: "report-3", Data: []byte("not json")})
+
+	assert.True(suite.T(), errors.Is(err, rabbitmq.ErrPermanent))
+}
+
+func (suite *ExternalEventServiceTestsSuite) TestUserBlocked_DeactivatesAndEmitsEvent() {
+	user := &model.User{ID: 2, Auth0ID: "auth0|blocked", Active: true}
+	delivery := rabbitmq.Delivery{MessageId: "blocked-1", Data: []byte(`{"UserAuth0ID":"auth0|blocked"}`)}
+	suite.processedMessageRepositoryMock.On("MarkProcessed", processed("blocked-1")).Return(true, nil).Once()
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|blocked").Return(user, nil).Once()
+	suite.userRepositoryMock.On("Update", user).Return(&dto.UserResponseDTO{}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "user.deactivated.v1"
+	})).Return(nil).Once()
+
+	err := suite.service.UserBlocked(delivery)
+
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), user.Active)
+}
+
+func (suite *ExternalEventServiceTestsSuite) TestUserBlocked_UnknownUserIsPermanent() {
+	delivery := rabbitmq.Delivery{MessageId: "blocked-2", Data: []byte(`{"UserAuth0ID":"auth0|unknown"}`)}
+	suite.processedMessageRepositoryMock.On("MarkProcessed", processed("blocked-2")).Return(true, nil).Once()
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|unknown").Return(nil, fmt.Errorf("%w: auth0_ID auth0|unknown", repository.ErrUserNotFound)).Once()
+
+	err := suite.service.UserBlocked(delivery)
+
+	assert.True(suite.T(), errors.Is(err, rabbitmq.ErrPermanent))
+}
+
+func (suite *ExternalEventServiceTestsSuite) TestPasswordChanged_DatabaseErrorIsRetried() {
+	delivery := rabbitmq.Delivery{MessageId: "password-1", Data: []byte(`{"UserAuth0ID":"auth0|unreachable"}`)}
+	suite.processedMessageRepositoryMock.On("MarkProcessed", processed("password-1")).Return(true, nil).Once()
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|unreachable").Return(nil, errors.New("connection refused")).Once()
+
+	err := suite.service.PasswordChanged(delivery)
+
+	assert.NotNil(suite.T(), err)
+	assert.False(suite.T(), errors.Is(err, rabbitmq.ErrPermanent))
+}
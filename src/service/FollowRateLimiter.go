@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"user-ms/src/metrics"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FollowAttemptRetention is how long follow attempts are kept, the longest
+// window the rate limiter looks at.
+const FollowAttemptRetention = 24 * time.Hour
+
+// FollowLimits holds per-user quotas. A zero limit or cooldown disables that
+// check.
+type FollowLimits struct {
+	FollowsPerHour    int
+	FollowsPerDay     int
+	RequestsPerHour   int
+	RequestsPerDay    int
+	RejectionCooldown time.Duration
+}
+
+// RateLimitError is returned when a follow or following request is refused
+// by the rate limiter. RetryAfter tells the caller when the action will be
+// allowed again.
+type RateLimitError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many follow attempts (%s), retry in %s", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+type IFollowRateLimiter interface {
+	AllowFollow(*model.User) error
+	AllowRequest(*model.User, int) error
+}
+
+// FollowRateLimiter enforces follow quotas from the database, so limits hold
+// across replicas. Follows are counted from the follow attempts and requests
+// from the following requests, both kept after an unfollow. Quotas belong to
+// the authenticated caller. Accounts younger than NewAccountAge, or without
+// an Auth0 identity, get NewAccountLimits instead of Limits.
+type FollowRateLimiter struct {
+	FollowerRepository         repository.IFollowerRepository
+	FollowingRequestRepository repository.IFollowingRequestRepository
+	Limits                     FollowLimits
+	NewAccountLimits           FollowLimits
+	NewAccountAge              time.Duration
+	Metrics                    *metrics.DomainMetrics
+	Logger                     *logrus.Entry
+}
+
+func NewFollowRateLimiter(followerRepository repository.IFollowerRepository, followingRequestRepository repository.IFollowingRequestRepository, limits FollowLimits, newAccountLimits FollowLimits, newAccountAge time.Duration, domainMetrics *metrics.DomainMetrics, logger *logrus.Entry) IFollowRateLimiter {
+	return &FollowRateLimiter{
+		followerRepository,
+		followingRequestRepository,
+		limits,
+		newAccountLimits,
+		newAccountAge,
+		domainMetrics,
+		logger,
+	}
+}
+
+func (limiter *FollowRateLimiter) AllowFollow(caller *model.User) error {
+	limits := limiter.limitsFor(caller)
+
+	count := func(since time.Time) (int, *time.Time) {
+		return limiter.FollowerRepository.CountFollowAttemptsSince(caller.ID, since)
+	}
+	if err := limiter.checkWindow("follow", "hourly", limits.FollowsPerHour, time.Hour, count); err != nil {
+		return err
+	}
+	return limiter.checkWindow("follow", "daily", limits.FollowsPerDay, 24*time.Hour, count)
+}
+
+func (limiter *FollowRateLimiter) AllowRequest(caller *model.User, followingId int) error {
+	limits := limiter.limitsFor(caller)
+
+	if limits.RejectionCooldown > 0 {
+		rejected, err := limiter.FollowingRequestRepository.GetLastRejected(caller.ID, followingId)
+		if err == nil && rejected.DecidedAt != nil {
+			if retryAfter := time.Until(rejected.DecidedAt.Add(limits.RejectionCooldown)); retryAfter > 0 {
+				return limiter.reject("request", "cooldown", retryAfter)
+			}
+		}
+	}
+
+	count := func(since time.Time) (int, *time.Time) {
+		return limiter.FollowingRequestRepository.CountRequestsSince(caller.ID, since)
+	}
+	if err := limiter.checkWindow("request", "hourly", limits.RequestsPerHour, time.Hour, count); err != nil {
+		return err
+	}
+	return limiter.checkWindow("request", "daily", limits.RequestsPerDay, 24*time.Hour, count)
+}
+
+func (limiter *FollowRateLimiter) limitsFor(user *model.User) FollowLimits {
+	if user.Auth0ID == "" || (!user.CreatedAt.IsZero() && time.Since(user.CreatedAt) < limiter.NewAccountAge) {
+		return limiter.NewAccountLimits
+	}
+	return limiter.Limits
+}
+
+func (limiter *FollowRateLimiter) checkWindow(action string, reason string, limit int, window time.Duration, count func(time.Time) (int, *time.Time)) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	used, oldest := count(time.Now().Add(-window))
+	if used < limit {
+		return nil
+	}
+
+	retryAfter := window
+	if oldest != nil {
+		retryAfter = time.Until(oldest.Add(window))
+	}
+	return limiter.reject(action, reason, retryAfter)
+}
+
+func (limiter *FollowRateLimiter) reject(action string, reason string, retryAfter time.Duration) error {
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	limiter.Metrics.FollowRateLimited(action, reason)
+	limiter.Logger.Info(fmt.Sprintf("Rate limited %s (%s), retry after %s", action, reason, retryAfter))
+	return &RateLimitError{Reason: reason, RetryAfter: retryAfter}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"user-ms/src/logging"
+	"user-ms/src/metrics"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type FollowRateLimiterTestsSuite struct {
+	suite.Suite
+	followerRepositoryMock         *repository.FollowerRepositoryMock
+	followingRequestRepositoryMock *repository.FollowingRequestRepositoryMock
+	limiter                        IFollowRateLimiter
+}
+
+func TestFollowRateLimiterTestsSuite(t *testing.T) {
+	suite.Run(t, new(FollowRateLimiterTestsSuite))
+}
+
+func (suite *FollowRateLimiterTestsSuite) SetupSuite() {
+	suite.followerRepositoryMock = new(repository.FollowerRepositoryMock)
+	suite.followingRequestRepositoryMock = new(repository.FollowingRequestRepositoryMock)
+
+	limits := FollowLimits{FollowsPerHour: 10, FollowsPerDay: 50, RequestsPerHour: 5, RequestsPerDay: 20, RejectionCooldown: 24 * time.Hour}
+	newAccountLimits := FollowLimits{FollowsPerHour: 2, FollowsPerDay: 10, RequestsPerHour: 1, RequestsPerDay: 5, RejectionCooldown: 24 * time.Hour}
+	suite.limiter = NewFollowRateLimiter(suite.followerRepositoryMock, suite.followingRequestRepositoryMock, limits, newAccountLimits, 7*24*time.Hour, nil, logging.Logger())
+}
+
+func (suite *FollowRateLimiterTestsSuite) TestAllowFollow_UnderLimit() {
+	user := model.User{ID: 1, Auth0ID: "auth0|1", CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+	suite.followerRepositoryMock.On("CountFollowAttemptsSince", 1, mock.AnythingOfType("time.Time")).Return(3, nil).Twice()
+
+	assert.Nil(suite.T(), suite.limiter.AllowFollow(&user))
+}
+
+func (suite *FollowRateLimiterTestsSuite) TestAllowFollow_NewAccountHourlyLimit() {
+	user := model.User{ID: 2, Auth0ID: "auth0|2", CreatedAt: time.Now().Add(-time.Hour)}
+	oldest := time.Now().Add(-30 * time.Minute)
+	suite.followerRepositoryMock.On("CountFollowAttemptsSince", 2, mock.AnythingOfType("time.Time")).Return(2, &oldest).Once()
+
+	registry := prometheus.NewRegistry()
+	limiter := *suite.limiter.(*FollowRateLimiter)
+	limiter.Metrics, _ = metrics.NewDomainMetrics(registry)
+
+	err := limiter.AllowFollow(&user)
+
+	var rateLimitErr *RateLimitError
+	assert.True(suite.T(), errors.As(err, &rateLimitErr))
+	assert.Equal(suite.T(), "hourly", rateLimitErr.Reason)
+	assert.InDelta(suite.T(), (30 * time.Minute).Seconds(), rateLimitErr.RetryAfter.Seconds(), 5)
+	expected := `
+# HELP follow_rate_limited_total Number of follows and following requests rejected by the rate limiter.
+# TYPE follow_rate_limited_total counter
+follow_rate_limited_total{action="follow",reason="hourly"} 1
+`
+	assert.Nil(suite.T(), testutil.GatherAndCompare(registry, strings.NewReader(expected), "follow_rate_limited_total"))
+}
+
+func (suite *FollowRateLimiterTestsSuite) TestAllowRequest_RejectionCooldown() {
+	user := model.User{ID: 3, Auth0ID: "auth0|3", CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+	decidedAt := time.Now().Add(-time.Hour)
+	rejected := model.FollowingRequest{FollowerId: 3, FollowingId: 4, RequestStatus: model.REJECTED, DecidedAt: &decidedAt}
+	suite.followingRequestRepositoryMock.On("GetLastRejected", 3, 4).Return(&rejected, nil).Once()
+
+	err := suite.limiter.AllowRequest(&user, 4)
+
+	var rateLimitErr *RateLimitError
+	assert.True(suite.T(), errors.As(err, &rateLimitErr))
+	assert.Equal(suite.T(), "cooldown", rateLimitErr.Reason)
+}
+
+func (suite *FollowRateLimiterTestsSuite) TestAllowRequest_DailyLimit() {
+	user := model.User{ID: 5, Auth0ID: "auth0|5", CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+	oldest := time.Now().Add(-20 * time.Hour)
+	suite.followingRequestRepositoryMock.On("GetLastRejected", 5, 6).Return(nil, errors.New("record not found")).Once()
+	suite.followingRequestRepositoryMock.On("CountRequestsSince", 5, mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) < 2*time.Hour
+	})).Return(1, nil).Once()
+	suite.followingRequestRepositoryMock.On("CountRequestsSince", 5, mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) > 2*time.Hour
+	})).Return(20, &oldest).Once()
+
+	err := suite.limiter.AllowRequest(&user, 6)
+
+	var rateLimitErr *RateLimitError
+	assert.True(suite.T(), errors.As(err, &rateLimitErr))
+	assert.Equal(suite.T(), "daily", rateLimitErr.Reason)
+}
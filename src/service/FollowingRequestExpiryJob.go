@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-ms/src/mapper"
+	"user-ms/src/model"
+	"user-ms/src/rabbitmq"
+	"user-ms/src/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRequestTTL is how long a request stays pending when no valid
+	// TTL is configured.
+	DefaultRequestTTL = 30 * 24 * time.Hour
+	// DefaultExpiryInterval is how often the job runs when no valid interval
+	// is configured.
+	DefaultExpiryInterval = time.Hour
+)
+
+// FollowingRequestExpiryJob periodically expires pending following requests
+// that are older than TTL. It also deletes the follow attempts the rate
+// limiter no longer looks at.
+type FollowingRequestExpiryJob struct {
+	Transactions       repository.ITransactionManager
+	NotificationPolicy INotificationPolicy
+	TTL                time.Duration
+	Interval           time.Duration
+	Notify             bool
+	Logger             *logrus.Entry
+}
+
+func NewFollowingRequestExpiryJob(transactions repository.ITransactionManager, notificationPolicy INotificationPolicy, ttl time.Duration, interval time.Duration, notify bool, logger *logrus.Entry) *FollowingRequestExpiryJob {
+	// A TTL that isn't positive would expire every pending request at once.
+	if ttl <= 0 {
+		logger.Warn(fmt.Sprintf("Invalid following request TTL %s, using %s", ttl, DefaultRequestTTL))
+		ttl = DefaultRequestTTL
+	}
+	// time.NewTicker panics on an interval that isn't positive.
+	if interval <= 0 {
+		logger.Warn(fmt.Sprintf("Invalid following request expiry interval %s, using %s", interval, DefaultExpiryInterval))
+		interval = DefaultExpiryInterval
+	}
+	return &FollowingRequestExpiryJob{
+		transactions,
+		notificationPolicy,
+		ttl,
+		interval,
+		notify,
+		logger,
+	}
+}
+
+// Start runs the job every Interval until stop is closed.
+func (job *FollowingRequestExpiryJob) Start(stop <-chan struct{}) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		job.ExpireStaleRequests()
+		job.DeleteOldFollowAttempts()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (job *FollowingRequestExpiryJob) ExpireStaleRequests() (int, error) {
+	cutoff := time.Now().Add(-job.TTL)
+	job.Logger.Info(fmt.Sprintf("Expiring following requests created before %s", cutoff.Format(time.RFC3339)))
+
+	var expired []model.FollowingRequest
+	err := job.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		expired, err = repositories.FollowingRequests.ExpireRequests(cutoff)
+		if err != nil {
+			return err
+		}
+
+		for i := range expired {
+			request := &expired[i]
+			if err := enqueueEvent(context.Background(), repositories.Outbox, rabbitmq.RequestDecided, mapper.RequestToEventDTO(request)); err != nil {
+				return err
+			}
+			if !job.Notify {
+				continue
+			}
+
+			follower, err := repositories.Users.GetByID(request.FollowerId)
+			if err != nil {
+				job.Logger.Debug(err.Error())
+				continue
+			}
+			following, err := repositories.Users.GetByID(request.FollowingId)
+			if err != nil {
+				job.Logger.Debug(err.Error())
+				continue
+			}
+
+			notification, err := NewNotification(follower, nil, FollowRequestExpiredTemplate, map[string]string{"target": following.Username})
+			if err != nil {
+				return err
+			}
+
+			job.Logger.Info("Adding expired following request notification to the outbox")
+			if err := enqueueNotification(context.Background(), repositories.Outbox, job.NotificationPolicy, follower, notification); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		job.Logger.Error(err.Error())
+		return 0, err
+	}
+
+	job.Logger.Info(fmt.Sprintf("Expired %d following requests", len(expired)))
+	return len(expired), nil
+}
+
+func (job *FollowingRequestExpiryJob) DeleteOldFollowAttempts() error {
+	err := job.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		return repositories.Followers.DeleteFollowAttemptsBefore(time.Now().Add(-FollowAttemptRetention))
+	})
+	if err != nil {
+		job.Logger.Error(err.Error())
+	}
+	return err
+}
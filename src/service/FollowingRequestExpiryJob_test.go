@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"user-ms/src/logging"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type FollowingRequestExpiryJobTestsSuite struct {
+	suite.Suite
+	followingRequestRepositoryMock *repository.FollowingRequestRepositoryMock
+	followerRepositoryMock         *repository.FollowerRepositoryMock
+	userRepositoryMock             *repository.UserRepositoryMock
+	outboxRepositoryMock           *repository.OutboxRepositoryMock
+	job                            *FollowingRequestExpiryJob
+}
+
+func TestFollowingRequestExpiryJobTestsSuite(t *testing.T) {
+	suite.Run(t, new(FollowingRequestExpiryJobTestsSuite))
+}
+
+func (suite *FollowingRequestExpiryJobTestsSuite) SetupSuite() {
+	suite.followingRequestRepositoryMock = new(repository.FollowingRequestRepositoryMock)
+	suite.followerRepositoryMock = new(repository.FollowerRepositoryMock)
+	suite.userRepositoryMock = new(repository.UserRepositoryMock)
+	suite.outboxRepositoryMock = new(repository.OutboxRepositoryMock)
+	transactions := &repository.TransactionManagerMock{Repositories: &repository.Repositories{
+		Users:             suite.userRepositoryMock,
+		Followers:         suite.followerRepositoryMock,
+		FollowingRequests: suite.followingRequestRepositoryMock,
+		Outbox:            suite.outboxRepositoryMock,
+	}}
+	suite.job = NewFollowingRequestExpiryJob(transactions, NewNotificationPolicy(suite.userRepositoryMock, nil, logging.Logger()), 24*time.Hour, time.Hour, false, logging.Logger())
+}
+
+func (suite *FollowingRequestExpiryJobTestsSuite) TestExpireStaleRequests() {
+	expired := []model.FollowingRequest{
+		{ID: 1, FollowerId: 1234, FollowingId: 2222, RequestStatus: model.EXPIRED},
+		{ID: 2, FollowerId: 3333, FollowingId: 2222, RequestStatus: model.EXPIRED},
+	}
+	suite.followingRequestRepositoryMock.On("ExpireRequests", mock.MatchedBy(func(cutoff time.Time) bool {
+		return time.Since(cutoff) >= 24*time.Hour
+	})).Return(expired, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow_request.decided.v1" && strings.Contains(message.Body, `"RequestStatus":"EXPIRED"`)
+	})).Return(nil).Times(2)
+
+	count, err := suite.job.ExpireStaleRequests()
+
+	assert.Equal(suite.T(), 2, count)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *FollowingRequestExpiryJobTestsSuite) TestExpireStaleRequests_RepositoryError() {
+	suite.followingRequestRepositoryMock.On("ExpireRequests", mock.AnythingOfType("time.Time")).Return(nil, errors.New("database is closed")).Once()
+
+	count, err := suite.job.ExpireStaleRequests()
+
+	assert.Equal(suite.T(), 0, count)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *FollowingRequestExpiryJobTestsSuite) TestDeleteOldFollowAttempts() {
+	suite.followerRepositoryMock.On("DeleteFollowAttemptsBefore", mock.MatchedBy(func(cutoff time.Time) bool {
+		return time.Since(cutoff) >= FollowAttemptRetention
+	})).Return(nil).Once()
+
+	assert.Nil(suite.T(), suite.job.DeleteOldFollowAttempts())
+}
+
+func (suite *FollowingRequestExpiryJobTestsSuite) TestNewFollowingRequestExpiryJob_InvalidTTL() {
+	job := NewFollowingRequestExpiryJob(nil, nil, -time.Hour, time.Hour, false, logging.Logger())
+
+	assert.Equal(suite.T(), DefaultRequestTTL, job.TTL)
+}
+
+func (suite *FollowingRequestExpiryJobTestsSuite) TestNewFollowingRequestExpiryJob_InvalidInterval() {
+	job := NewFollowingRequestExpiryJob(nil, nil, 24*time.Hour, 0, false, logging.Logger())
+
+	assert.Equal(suite.T(), DefaultExpiryInterval, job.Interval)
+}
@@ -1,136 +1,406 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 	"user-ms/src/dto"
+	"user-ms/src/logging"
 	"user-ms/src/mapper"
+	"user-ms/src/metrics"
 	"user-ms/src/model"
 	"user-ms/src/rabbitmq"
 	"user-ms/src/repository"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
-	"github.com/streadway/amqp"
 )
 
+// ErrNotTheCaller is returned when a follow is asked for on behalf of
+// another user.
+var ErrNotTheCaller = errors.New("users can only follow as themselves")
+
 type FollowingService struct {
 	FollowerRepository         repository.IFollowerRepository
 	FollowingRequestRepository repository.IFollowingRequestRepository
 	UserRepository             repository.IUserRepository
-	RabbitMQChannel            *amqp.Channel
+	Transactions               repository.ITransactionManager
+	RateLimiter                IFollowRateLimiter
+	NotificationPolicy         INotificationPolicy
+	NotifyRejections           bool
+	Metrics                    *metrics.DomainMetrics
 	Logger                     *logrus.Entry
 }
 
 type IFollowingService interface {
-	CreateRequest(*dto.FollowingRequestDTO) (int, error)
-	UpdateRequest(int, *dto.FollowingRequestDTO) (*dto.FollowingRequestDTO, error)
-	CreateFollower(*dto.FollowingRequestDTO) (int, error)
+	CreateRequest(context.Context, string, *dto.FollowingRequestDTO) (int, error)
+	UpdateRequest(context.Context, int, *dto.FollowingRequestDTO) (*dto.FollowingRequestDTO, error)
+	CreateFollower(context.Context, string, *dto.FollowingRequestDTO) (int, error)
+	GetRequestsByFollowingID(context.Context, int, string, string) ([]model.FollowingRequest, error)
+	GetFollowers(context.Context, int, string, string) ([]model.Follower, error)
+	GetFollowing(context.Context, int, string, string) ([]model.Follower, error)
+	RemoveFollower(context.Context, string, int) error
 }
 
-func NewFollowingService(followerRepository repository.IFollowerRepository, followingRequestRepository repository.IFollowingRequestRepository, userRepository repository.IUserRepository, channel *amqp.Channel, logger *logrus.Entry) IFollowingService {
+func NewFollowingService(followerRepository repository.IFollowerRepository, followingRequestRepository repository.IFollowingRequestRepository, userRepository repository.IUserRepository, transactions repository.ITransactionManager, rateLimiter IFollowRateLimiter, notificationPolicy INotificationPolicy, notifyRejections bool, domainMetrics *metrics.DomainMetrics, logger *logrus.Entry) IFollowingService {
 	return &FollowingService{
 		followerRepository,
 		followingRequestRepository,
 		userRepository,
-		channel,
+		transactions,
+		rateLimiter,
+		notificationPolicy,
+		notifyRejections,
+		domainMetrics,
 		logger,
 	}
 }
 
-func (service *FollowingService) CreateRequest(request *dto.FollowingRequestDTO) (int, error) {
-	service.Logger.Info("Requesting for follow")
-	followingRequestId, err := service.FollowingRequestRepository.AddFollowingRequest(mapper.FollowingDTOToRequestFollower(request))
+// CreateRequest creates a following request on behalf of the caller, the
+// user with callerAuth0ID, within the caller's rate limit.
+func (service *FollowingService) CreateRequest(ctx context.Context, callerAuth0ID string, request *dto.FollowingRequestDTO) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.CreateRequest")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info("Requesting for follow")
+	caller, err := service.caller(ctx, callerAuth0ID, request)
 	if err != nil {
-		service.Logger.Debug(err.Error())
-		return -1, errors.New("can't create the request")
+		logger.Debug(err.Error())
+		return -1, err
 	}
+	if service.RateLimiter != nil {
+		if err := service.RateLimiter.AllowRequest(caller, request.FollowingId); err != nil {
+			return -1, err
+		}
+	}
+
+	followingRequest := mapper.FollowingDTOToRequestFollower(request)
+	followingRequest.ActorId = request.FollowerId
 
-	follower, _ := service.UserRepository.GetByID(request.FollowerId)
-	following, _ := service.UserRepository.GetByID(request.FollowingId)
+	var followingRequestId int
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		followingRequestId, err = repositories.FollowingRequests.AddFollowingRequest(followingRequest)
+		if err != nil {
+			return err
+		}
+		if err := enqueueEvent(ctx, repositories.Outbox, rabbitmq.RequestCreated, mapper.RequestToEventDTO(followingRequest)); err != nil {
+			return err
+		}
 
-	followType := dto.Follow
-	notification := dto.NotificationDTO{Message: fmt.Sprintf("%s requested to follow you.", follower.Username), UserAuth0ID: following.Auth0ID, NotificationType: &followType}
+		actor, _ := repositories.Users.GetByID(followingRequest.ActorId)
+		following, _ := repositories.Users.GetByID(followingRequest.FollowingId)
 
-	service.Logger.Info("Adding following request notification to rabbitMQ")
-	rabbitmq.AddNotification(&notification, service.RabbitMQChannel)
+		notification, err := NewNotification(following, actor, FollowRequestedTemplate, nil)
+		if err != nil {
+			return err
+		}
 
+		logger.Info("Adding following request notification to the outbox")
+		return enqueueNotification(ctx, repositories.Outbox, service.NotificationPolicy, following, notification)
+	})
+	if err != nil {
+		logger.Debug(err.Error())
+		return -1, errors.New("can't create the request")
+	}
+
+	service.Metrics.FollowRequest(metrics.RequestCreated)
 	return followingRequestId, nil
 }
 
-func (service *FollowingService) UpdateRequest(reqId int, request *dto.FollowingRequestDTO) (*dto.FollowingRequestDTO, error) {
-	service.Logger.Info(fmt.Sprintf("Updating following request with id %d", reqId))
-	followingRequest, err := service.FollowingRequestRepository.UpdateFollowingRequest(reqId, mapper.FollowingDTOToRequestFollower(request))
+// caller looks up the user with callerAuth0ID, who may only follow as
+// themselves.
+func (service *FollowingService) caller(ctx context.Context, callerAuth0ID string, request *dto.FollowingRequestDTO) (*model.User, error) {
+	caller, err := service.UserRepository.WithContext(ctx).GetByAuth0ID(callerAuth0ID)
 	if err != nil {
-		service.Logger.Debug(err.Error())
-		return mapper.RequestToFollowingDTO(followingRequest), errors.New("can't create the request")
+		return nil, err
 	}
+	if caller.ID != request.FollowerId {
+		return nil, ErrNotTheCaller
+	}
+	return caller, nil
+}
+
+func (service *FollowingService) UpdateRequest(ctx context.Context, reqId int, request *dto.FollowingRequestDTO) (*dto.FollowingRequestDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.UpdateRequest")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Updating following request with id %d", reqId))
+	now := time.Now()
 	status := model.RequestStatus(request.RequestStatus)
-	if model.ACCEPTED == status {
-		_, err = service.FollowerRepository.AddFollower(mapper.FollowingDTOToFollower(request))
+
+	toUpdate := mapper.FollowingDTOToRequestFollower(request)
+	if status != model.PENDING {
+		toUpdate.DecidedAt = &now
+	}
+
+	var followingRequest *model.FollowingRequest
+	err := service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		followingRequest, err = repositories.FollowingRequests.UpdateFollowingRequest(reqId, toUpdate)
 		if err != nil {
-			service.Logger.Debug(err.Error())
-			return nil, errors.New("can't create the request")
+			return err
+		}
+		if model.PENDING == status {
+			return nil
+		}
+		if err := enqueueEvent(ctx, repositories.Outbox, rabbitmq.RequestDecided, mapper.RequestToEventDTO(followingRequest)); err != nil {
+			return err
+		}
+		// The parties come from the stored request, the client's copy of
+		// the IDs isn't trusted.
+		if model.REJECTED == status && service.NotifyRejections {
+			follower, err := repositories.Users.GetByID(followingRequest.FollowerId)
+			if err != nil {
+				return err
+			}
+			following, err := repositories.Users.GetByID(followingRequest.FollowingId)
+			if err != nil {
+				return err
+			}
+			return service.notifyRequester(ctx, repositories, followingRequest, follower, following, FollowRequestRejectedTemplate)
+		}
+		if model.ACCEPTED != status {
+			return nil
+		}
+
+		edge := mapper.RequestToFollower(followingRequest)
+		edge.ActorId = followingRequest.FollowingId
+		edge.DecidedAt = &now
+		if _, err := repositories.Followers.AddFollower(edge); err != nil {
+			return err
+		}
+		if err := enqueueEvent(ctx, repositories.Outbox, rabbitmq.FollowCreated, mapper.FollowerToEventDTO(edge)); err != nil {
+			return err
 		}
 
-		service.Logger.Info(fmt.Sprintf("Accepting following request with id %d", reqId))
-		follower, _ := service.UserRepository.GetByID(request.FollowerId)
-		following, _ := service.UserRepository.GetByID(request.FollowingId)
+		logger.Info(fmt.Sprintf("Accepting following request with id %d", reqId))
+		follower, _ := repositories.Users.GetByID(followingRequest.FollowerId)
+		following, _ := repositories.Users.GetByID(followingRequest.FollowingId)
+
+		notification, err := NewNotification(following, follower, FollowStartedTemplate, nil)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Adding following notification to the outbox")
+		if err := enqueueNotification(ctx, repositories.Outbox, service.NotificationPolicy, following, notification); err != nil {
+			return err
+		}
 
-		followType := dto.Follow
-		notification := dto.NotificationDTO{Message: fmt.Sprintf("%s started following you.", follower.Username), UserAuth0ID: following.Auth0ID, NotificationType: &followType}
+		return service.notifyRequester(ctx, repositories, followingRequest, follower, following, FollowRequestAcceptedTemplate)
+	})
+	if errors.Is(err, repository.ErrRequestNotPending) {
+		logger.Debug(err.Error())
+		return nil, err
+	}
+	if err != nil {
+		logger.Debug(err.Error())
+		return nil, errors.New("can't create the request")
+	}
 
-		service.Logger.Info("Adding following notification to rabbitMQ")
-		rabbitmq.AddNotification(&notification, service.RabbitMQChannel)
+	switch status {
+	case model.ACCEPTED:
+		service.Metrics.FollowRequest(metrics.RequestAccepted)
+		service.Metrics.Follow(metrics.Followed)
+	case model.REJECTED:
+		service.Metrics.FollowRequest(metrics.RequestRejected)
 	}
 	return mapper.RequestToFollowingDTO(followingRequest), nil
 }
 
-func (service *FollowingService) GetRequests() ([]model.FollowingRequest, error) {
-	service.Logger.Info("Getting all requests")
-	requests := service.FollowingRequestRepository.GetRequests()
+// notifyRequester tells the user who asked to follow how their request was
+// decided, if their follow preferences allow it.
+func (service *FollowingService) notifyRequester(ctx context.Context, repositories *repository.Repositories, request *model.FollowingRequest, follower *model.User, following *model.User, template NotificationTemplate) error {
+	logger := logging.WithContext(service.Logger, ctx)
+
+	outcome := request.RequestStatus.String()
+	notification, err := NewNotification(follower, following, template, map[string]string{"request_id": strconv.Itoa(request.ID), "outcome": outcome})
+	if err != nil {
+		return err
+	}
+
+	logger.Info(fmt.Sprintf("Adding %s follow request outcome notification to the outbox", outcome))
+	return enqueueNotification(ctx, repositories.Outbox, service.NotificationPolicy, follower, notification)
+}
+
+func (service *FollowingService) GetRequests(ctx context.Context, sort string, order string) ([]model.FollowingRequest, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.GetRequests")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info("Getting all requests")
+	orderBy, err := repository.FollowOrderBy(sort, order)
+	if err != nil {
+		logger.Debug(err.Error())
+		return nil, err
+	}
+	requests := service.FollowingRequestRepository.WithContext(ctx).GetRequests(orderBy)
 	return requests, nil
 }
 
-func (service *FollowingService) GetRequestsByFollowingID(id int) ([]model.FollowingRequest, error) {
-	service.Logger.Info(fmt.Sprintf("Getting following requests for id %d", id))
-	requests := service.FollowingRequestRepository.GetRequestsByFollowingID(id)
+func (service *FollowingService) GetRequestsByFollowingID(ctx context.Context, id int, sort string, order string) ([]model.FollowingRequest, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.GetRequestsByFollowingID")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Getting following requests for id %d", id))
+	orderBy, err := repository.FollowOrderBy(sort, order)
+	if err != nil {
+		logger.Debug(err.Error())
+		return nil, err
+	}
+	requests := service.FollowingRequestRepository.WithContext(ctx).GetRequestsByFollowingID(id, orderBy)
 	return requests, nil
 }
 
-func (service *FollowingService) CreateFollower(request *dto.FollowingRequestDTO) (int, error) {
-	service.Logger.Info("Creating follower")
-	followerId, err := service.FollowerRepository.AddFollower(mapper.FollowingDTOToFollower(request))
+// CreateFollower makes the caller, the user with callerAuth0ID, follow
+// request.FollowingId. The follow counts against the caller's rate limit
+// even after an unfollow.
+func (service *FollowingService) CreateFollower(ctx context.Context, callerAuth0ID string, request *dto.FollowingRequestDTO) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.CreateFollower")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info("Creating follower")
+	caller, err := service.caller(ctx, callerAuth0ID, request)
 	if err != nil {
-		service.Logger.Debug(err.Error())
-		return -1, errors.New("can't create the request")
+		logger.Debug(err.Error())
+		return -1, err
 	}
+	if service.RateLimiter != nil {
+		if err := service.RateLimiter.AllowFollow(caller); err != nil {
+			return -1, err
+		}
+	}
+
+	now := time.Now()
+	edge := mapper.FollowingDTOToFollower(request)
+	edge.ActorId = request.FollowerId
+	edge.DecidedAt = &now
+
+	var followerId int
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		followerId, err = repositories.Followers.AddFollower(edge)
+		if err != nil {
+			return err
+		}
+		if err := repositories.Followers.AddFollowAttempt(&model.FollowAttempt{UserId: caller.ID, CreatedAt: now}); err != nil {
+			return err
+		}
+		if err := enqueueEvent(ctx, repositories.Outbox, rabbitmq.FollowCreated, mapper.FollowerToEventDTO(edge)); err != nil {
+			return err
+		}
 
-	follower, _ := service.UserRepository.GetByID(request.FollowerId)
-	following, _ := service.UserRepository.GetByID(request.FollowingId)
+		actor, _ := repositories.Users.GetByID(edge.ActorId)
+		following, _ := repositories.Users.GetByID(edge.FollowingId)
 
-	followType := dto.Follow
-	notification := dto.NotificationDTO{Message: fmt.Sprintf("%s started following you.", follower.Username), UserAuth0ID: following.Auth0ID, NotificationType: &followType}
+		notification, err := NewNotification(following, actor, FollowStartedTemplate, nil)
+		if err != nil {
+			return err
+		}
 
-	service.Logger.Info("Adding following notification to rabbitMQ")
-	rabbitmq.AddNotification(&notification, service.RabbitMQChannel)
+		logger.Info("Adding following notification to the outbox")
+		return enqueueNotification(ctx, repositories.Outbox, service.NotificationPolicy, following, notification)
+	})
+	if err != nil {
+		logger.Debug(err.Error())
+		return -1, errors.New("can't create the request")
+	}
 
+	service.Metrics.Follow(metrics.Followed)
 	return followerId, nil
 }
 
-func (service *FollowingService) GetFollowers(id int) ([]model.Follower, error) {
-	service.Logger.Info(fmt.Sprintf("Getting followers for user with id %d", id))
-	followers := service.FollowerRepository.GetFollowers(id)
+func (service *FollowingService) GetFollowers(ctx context.Context, id int, sort string, order string) ([]model.Follower, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.GetFollowers")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Getting followers for user with id %d", id))
+	orderBy, err := repository.FollowOrderBy(sort, order)
+	if err != nil {
+		logger.Debug(err.Error())
+		return nil, err
+	}
+	followers := service.FollowerRepository.WithContext(ctx).GetFollowers(id, orderBy)
 	return followers, nil
 }
 
-func (service *FollowingService) GetFollowing(id int) ([]model.Follower, error) {
-	service.Logger.Info(fmt.Sprintf("Getting following for user with id %d", id))
-	following := service.FollowerRepository.GetFollowing(id)
+func (service *FollowingService) GetFollowing(ctx context.Context, id int, sort string, order string) ([]model.Follower, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.GetFollowing")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Getting following for user with id %d", id))
+	orderBy, err := repository.FollowOrderBy(sort, order)
+	if err != nil {
+		logger.Debug(err.Error())
+		return nil, err
+	}
+	following := service.FollowerRepository.WithContext(ctx).GetFollowing(id, orderBy)
 	return following, nil
 }
 
-func (service *FollowingService) RemoveFollowing(id int, followingId int) error {
-	service.Logger.Info(fmt.Sprintf("User with id %d unfollowed user with id %d", id, followingId))
-	service.FollowerRepository.RemoveFollowing(id, followingId)
+func (service *FollowingService) RemoveFollowing(ctx context.Context, id int, followingId int) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.RemoveFollowing")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("User with id %d unfollowed user with id %d", id, followingId))
+	removed := false
+	err := service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		removed, err = repositories.Followers.RemoveFollowing(id, followingId)
+		if err != nil || !removed {
+			return err
+		}
+
+		event := dto.FollowEventDTO{FollowerId: id, FollowingId: followingId, ActorId: id}
+		return enqueueEvent(ctx, repositories.Outbox, rabbitmq.FollowRemoved, &event)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Unfollowing someone who wasn't followed succeeds, but nothing changed.
+	if removed {
+		service.Metrics.Follow(metrics.Unfollowed)
+	}
+	return nil
+}
+
+func (service *FollowingService) RemoveFollower(ctx context.Context, userAuth0ID string, followerId int) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "FollowingService.RemoveFollower")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("User with auth0 id %s removing follower with id %d", userAuth0ID, followerId))
+	user, err := service.UserRepository.WithContext(ctx).GetByAuth0ID(userAuth0ID)
+	if err != nil {
+		logger.Debug(err.Error())
+		return err
+	}
+
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		if err := repositories.Followers.RemoveFollower(user.ID, followerId); err != nil {
+			return err
+		}
+
+		event := dto.FollowEventDTO{FollowerId: followerId, FollowingId: user.ID, ActorId: user.ID}
+		return enqueueEvent(ctx, repositories.Outbox, rabbitmq.FollowRemoved, &event)
+	})
+	if err != nil {
+		logger.Debug(err.Error())
+		return err
+	}
+
+	service.Metrics.Follow(metrics.Unfollowed)
+	logger.Info(fmt.Sprintf("User with id %d removed follower with id %d", user.ID, followerId))
 	return nil
 }
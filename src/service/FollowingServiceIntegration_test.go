@@ -5,10 +5,9 @@ import (
 	"os"
 	"testing"
 	_ "user-ms/src/dto"
+	"user-ms/src/logging"
 	"user-ms/src/model"
-	"user-ms/src/rabbitmq"
 	"user-ms/src/repository"
-	"user-ms/src/utils"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
@@ -44,6 +43,7 @@ func (suite *FollowingServiceIntegrationTestSuite) SetupSuite() {
 	db.AutoMigrate(model.FollowingRequest{})
 	db.AutoMigrate(model.Follower{})
 	db.AutoMigrate(model.User{})
+	db.AutoMigrate(model.OutboxMessage{})
 
 	followerRepository := repository.FollowerRepository{Database: db}
 	followingRequestRepository := repository.FollowingRequestRepository{Database: db}
@@ -51,20 +51,13 @@ func (suite *FollowingServiceIntegrationTestSuite) SetupSuite() {
 
 	suite.db = db
 
-	amqpServerURL := os.Getenv("AMQP_SERVER_URL")
-
-	rabbit := rabbitmq.RMQProducer{
-		ConnectionString: amqpServerURL,
-	}
-
-	channel, _ := rabbit.StartRabbitMQ()
-
 	suite.service = FollowingService{
 		FollowerRepository:         &followerRepository,
 		FollowingRequestRepository: &followingRequestRepository,
 		UserRepository:             &userRepository,
-		RabbitMQChannel:            channel,
-		Logger:                     utils.Logger(),
+		Transactions:               &repository.TransactionManager{Database: db},
+		NotificationPolicy:         NewNotificationPolicy(&userRepository, nil, logging.Logger()),
+		Logger:                     logging.Logger(),
 	}
 
 	gender := model.Female
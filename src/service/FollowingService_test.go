@@ -1,12 +1,18 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/metrics"
 	"user-ms/src/model"
 	"user-ms/src/repository"
-	"user-ms/src/utils"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -17,6 +23,7 @@ type FollowingTestsSuite struct {
 	followerRepositoryMock         *repository.FollowerRepositoryMock
 	followingRequestRepositoryMock *repository.FollowingRequestRepositoryMock
 	userRepositoryMock             *repository.UserRepositoryMock
+	outboxRepositoryMock           *repository.OutboxRepositoryMock
 	service                        IFollowingService
 }
 
@@ -28,7 +35,14 @@ func (suite *FollowingTestsSuite) SetupSuite() {
 	suite.followerRepositoryMock = new(repository.FollowerRepositoryMock)
 	suite.followingRequestRepositoryMock = new(repository.FollowingRequestRepositoryMock)
 	suite.userRepositoryMock = new(repository.UserRepositoryMock)
-	suite.service = NewFollowingService(suite.followerRepositoryMock, suite.followingRequestRepositoryMock, suite.userRepositoryMock, nil, utils.Logger())
+	suite.outboxRepositoryMock = new(repository.OutboxRepositoryMock)
+	transactions := &repository.TransactionManagerMock{Repositories: &repository.Repositories{
+		Users:             suite.userRepositoryMock,
+		Followers:         suite.followerRepositoryMock,
+		FollowingRequests: suite.followingRequestRepositoryMock,
+		Outbox:            suite.outboxRepositoryMock,
+	}}
+	suite.service = NewFollowingService(suite.followerRepositoryMock, suite.followingRequestRepositoryMock, suite.userRepositoryMock, transactions, nil, NewNotificationPolicy(suite.userRepositoryMock, nil, logging.Logger()), true, nil, logging.Logger())
 }
 
 func (suite *FollowingTestsSuite) TestNewFollowingTestsService() {
@@ -58,10 +72,212 @@ func (suite *FollowingTestsSuite) TestUpdateFollowerRequest() {
 	}
 	suite.followingRequestRepositoryMock.On("UpdateFollowingRequest", mock.AnythingOfType("int"), mock.AnythingOfType("*model.FollowingRequest")).Return(&updatedReq, nil).Once()
 	suite.followerRepositoryMock.On("AddFollower", mock.AnythingOfType("*model.Follower")).Return(1, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 2222).Return(&model.User{ID: 2222, Username: "username2"}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 1234).Return(&model.User{ID: 1234, Username: "username", FollowNotifications: true}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.AnythingOfType("*model.OutboxMessage")).Return(nil).Times(3)
 
-	reqDTO, err := suite.service.UpdateRequest(followingRequestDTO.FollowingId, &updatedReqDTO)
+	reqDTO, err := suite.service.UpdateRequest(context.Background(), followingRequestDTO.FollowingId, &updatedReqDTO)
 
 	assert.Equal(suite.T(), int(acceptedStatus), reqDTO.RequestStatus)
 	assert.Equal(suite.T(), nil, err)
 
 }
+
+func (suite *FollowingTestsSuite) TestUpdateFollowerRequest_AlreadyDecided() {
+	suite.followingRequestRepositoryMock.On("UpdateFollowingRequest", 13, mock.AnythingOfType("*model.FollowingRequest")).Return(nil, repository.ErrRequestNotPending).Once()
+	outboxCalls := len(suite.outboxRepositoryMock.Calls)
+
+	reqDTO, err := suite.service.UpdateRequest(context.Background(), 13, &dto.FollowingRequestDTO{RequestStatus: int(model.ACCEPTED)})
+
+	assert.Nil(suite.T(), reqDTO)
+	assert.Equal(suite.T(), repository.ErrRequestNotPending, err)
+	assert.Equal(suite.T(), outboxCalls, len(suite.outboxRepositoryMock.Calls), "nothing is published")
+}
+
+func (suite *FollowingTestsSuite) TestRemoveFollower() {
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|1234").Return(&model.User{ID: 1234}, nil).Once()
+	suite.followerRepositoryMock.On("RemoveFollower", 1234, 2222).Return(nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow.removed.v1"
+	})).Return(nil).Once()
+
+	err := suite.service.RemoveFollower(context.Background(), "auth0|1234", 2222)
+
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *FollowingTestsSuite) TestRemoveFollower_NotAFollower() {
+	coupleErr := errors.New("the couple doesn't exist")
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|1234").Return(&model.User{ID: 1234}, nil).Once()
+	suite.followerRepositoryMock.On("RemoveFollower", 1234, 3333).Return(coupleErr).Once()
+
+	err := suite.service.RemoveFollower(context.Background(), "auth0|1234", 3333)
+
+	assert.Equal(suite.T(), coupleErr, err)
+}
+
+func (suite *FollowingTestsSuite) TestRemoveFollowing() {
+	suite.followerRepositoryMock.On("RemoveFollowing", 1234, 5678).Return(true, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow.removed.v1" && strings.Contains(message.Body, `"FollowingId":5678`)
+	})).Return(nil).Once()
+
+	err := suite.service.(*FollowingService).RemoveFollowing(context.Background(), 1234, 5678)
+
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *FollowingTestsSuite) TestRemoveFollowing_NotFollowing() {
+	suite.followerRepositoryMock.On("RemoveFollowing", 1234, 6789).Return(false, nil).Once()
+	outboxCalls := len(suite.outboxRepositoryMock.Calls)
+
+	registry := prometheus.NewRegistry()
+	service := *suite.service.(*FollowingService)
+	service.Metrics, _ = metrics.NewDomainMetrics(registry)
+
+	err := service.RemoveFollowing(context.Background(), 1234, 6789)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), outboxCalls, len(suite.outboxRepositoryMock.Calls), "no follow.removed event")
+	count, _ := testutil.GatherAndCount(registry, "users_follows_total")
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *FollowingTestsSuite) TestGetFollowers_UnknownSortKey() {
+	followers, err := suite.service.GetFollowers(context.Background(), 1234, "username", "")
+
+	assert.Nil(suite.T(), followers)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *FollowingTestsSuite) TestCreateFollower_OutboxError() {
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|3333").Return(&model.User{ID: 3333}, nil).Once()
+	suite.followerRepositoryMock.On("AddFollower", mock.AnythingOfType("*model.Follower")).Return(7, nil).Once()
+	suite.followerRepositoryMock.On("AddFollowAttempt", mock.AnythingOfType("*model.FollowAttempt")).Return(nil).Once()
+	suite.userRepositoryMock.On("GetByID", 3333).Return(&model.User{ID: 3333, Username: "username3"}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 4444).Return(&model.User{ID: 4444, Username: "username4", FollowNotifications: true}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.AnythingOfType("*model.OutboxMessage")).Return(errors.New("database is closed")).Once()
+
+	id, err := suite.service.CreateFollower(context.Background(), "auth0|3333", &dto.FollowingRequestDTO{FollowerId: 3333, FollowingId: 4444})
+
+	assert.Equal(suite.T(), -1, id)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *FollowingTestsSuite) TestCreateFollower_OnBehalfOfAnotherUser() {
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|5151").Return(&model.User{ID: 5151}, nil).Once()
+	followerCalls := len(suite.followerRepositoryMock.Calls)
+
+	id, err := suite.service.CreateFollower(context.Background(), "auth0|5151", &dto.FollowingRequestDTO{FollowerId: 5252, FollowingId: 5353})
+
+	assert.Equal(suite.T(), -1, id)
+	assert.Equal(suite.T(), ErrNotTheCaller, err)
+	assert.Equal(suite.T(), followerCalls, len(suite.followerRepositoryMock.Calls), "nothing is written")
+}
+
+func (suite *FollowingTestsSuite) TestCreateFollower_NotificationsTurnedOff() {
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|5555").Return(&model.User{ID: 5555}, nil).Once()
+	suite.followerRepositoryMock.On("AddFollower", mock.AnythingOfType("*model.Follower")).Return(8, nil).Once()
+	suite.followerRepositoryMock.On("AddFollowAttempt", mock.MatchedBy(func(attempt *model.FollowAttempt) bool {
+		return attempt.UserId == 5555
+	})).Return(nil).Once()
+	suite.userRepositoryMock.On("GetByID", 5555).Return(&model.User{ID: 5555, Username: "username5"}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 6666).Return(&model.User{ID: 6666, Username: "username6", FollowNotifications: false}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.AnythingOfType("*model.OutboxMessage")).Return(nil).Once()
+	outboxCalls := len(suite.outboxRepositoryMock.Calls)
+
+	id, err := suite.service.CreateFollower(context.Background(), "auth0|5555", &dto.FollowingRequestDTO{FollowerId: 5555, FollowingId: 6666})
+
+	assert.Equal(suite.T(), 8, id)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), outboxCalls+1, len(suite.outboxRepositoryMock.Calls), "only the follow event is written")
+}
+
+func (suite *FollowingTestsSuite) TestUpdateFollowerRequest_NotifiesRequesterOfAcceptance() {
+	suite.followingRequestRepositoryMock.On("UpdateFollowingRequest", 11, mock.AnythingOfType("*model.FollowingRequest")).Return(&model.FollowingRequest{ID: 11, FollowingId: 7777, FollowerId: 8888, RequestStatus: model.ACCEPTED}, nil).Once()
+	suite.followerRepositoryMock.On("AddFollower", mock.AnythingOfType("*model.Follower")).Return(9, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 8888).Return(&model.User{ID: 8888, Auth0ID: "auth0|8888", Username: "username8", FollowNotifications: true}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 7777).Return(&model.User{ID: 7777, Username: "username7"}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow_request.decided.v1" || message.RoutingKey == "follow.created.v1"
+	})).Return(nil).Twice()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return strings.Contains(message.Body, "dislinkt.users.notification.follow_request") &&
+			strings.Contains(message.Body, "username7 accepted your follow request.")
+	})).Return(nil).Once()
+
+	_, err := suite.service.UpdateRequest(context.Background(), 11, &dto.FollowingRequestDTO{FollowingId: 7777, FollowerId: 8888, RequestStatus: int(model.ACCEPTED)})
+
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *FollowingTestsSuite) TestUpdateFollowerRequest_NotifiesRequesterOfRejection() {
+	suite.followingRequestRepositoryMock.On("UpdateFollowingRequest", 12, mock.AnythingOfType("*model.FollowingRequest")).Return(&model.FollowingRequest{ID: 12, FollowingId: 7777, FollowerId: 9999, RequestStatus: model.REJECTED}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 9999).Return(&model.User{ID: 9999, Auth0ID: "auth0|9999", Username: "username9", FollowNotifications: true}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 7777).Return(&model.User{ID: 7777, Username: "username7"}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow_request.decided.v1"
+	})).Return(nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return strings.Contains(message.Body, "username7 declined your follow request.") &&
+			strings.Contains(message.Body, `"outcome":"REJECTED"`)
+	})).Return(nil).Once()
+
+	registry := prometheus.NewRegistry()
+	service := *suite.service.(*FollowingService)
+	service.Metrics, _ = metrics.NewDomainMetrics(registry)
+
+	_, err := service.UpdateRequest(context.Background(), 12, &dto.FollowingRequestDTO{FollowingId: 7777, FollowerId: 9999, RequestStatus: int(model.REJECTED)})
+
+	assert.Nil(suite.T(), err)
+	expected := `
+# HELP users_follow_requests_total Follow requests created, accepted and rejected.
+# TYPE users_follow_requests_total counter
+users_follow_requests_total{outcome="rejected"} 1
+`
+	assert.Nil(suite.T(), testutil.GatherAndCompare(registry, strings.NewReader(expected), "users_follow_requests_total", "users_follows_total"))
+}
+
+func (suite *FollowingTestsSuite) TestUpdateFollowerRequest_NotifiesStoredParties() {
+	suite.followingRequestRepositoryMock.On("UpdateFollowingRequest", 13, mock.AnythingOfType("*model.FollowingRequest")).Return(&model.FollowingRequest{ID: 13, FollowingId: 7171, FollowerId: 8181, RequestStatus: model.ACCEPTED}, nil).Once()
+	suite.followerRepositoryMock.On("AddFollower", mock.MatchedBy(func(edge *model.Follower) bool {
+		return edge.FollowerId == 8181 && edge.FollowingId == 7171 && edge.ActorId == 7171
+	})).Return(10, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 8181).Return(&model.User{ID: 8181, Auth0ID: "auth0|8181", Username: "username81", FollowNotifications: true}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 7171).Return(&model.User{ID: 7171, Auth0ID: "auth0|7171", Username: "username71", FollowNotifications: true}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow_request.decided.v1" || message.RoutingKey == "follow.created.v1"
+	})).Return(nil).Twice()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return strings.Contains(message.Body, "username81 started following you.") && strings.Contains(message.Body, `"ActorId":8181`)
+	})).Return(nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return strings.Contains(message.Body, "username71 accepted your follow request.") && strings.Contains(message.Body, `"ActorId":7171`)
+	})).Return(nil).Once()
+
+	// The client names other users than the ones on the stored request.
+	_, err := suite.service.UpdateRequest(context.Background(), 13, &dto.FollowingRequestDTO{FollowingId: 1, FollowerId: 2, RequestStatus: int(model.ACCEPTED)})
+
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *FollowingTestsSuite) TestCreateRequest_ActorIsTheStoredFollower() {
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|4141").Return(&model.User{ID: 4141}, nil).Once()
+	suite.followingRequestRepositoryMock.On("AddFollowingRequest", mock.MatchedBy(func(request *model.FollowingRequest) bool {
+		return request.FollowerId == 4141 && request.ActorId == 4141
+	})).Return(14, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 4141).Return(&model.User{ID: 4141, Username: "username41"}, nil).Once()
+	suite.userRepositoryMock.On("GetByID", 4242).Return(&model.User{ID: 4242, Auth0ID: "auth0|4242", Username: "username42", FollowNotifications: true}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow_request.created.v1"
+	})).Return(nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return strings.Contains(message.Body, "username41") && strings.Contains(message.Body, `"ActorId":4141`)
+	})).Return(nil).Once()
+
+	// ActorId in the body is ignored, the follower is the one asking.
+	id, err := suite.service.CreateRequest(context.Background(), "auth0|4141", &dto.FollowingRequestDTO{FollowerId: 4141, FollowingId: 4242, ActorId: 4242})
+
+	assert.Equal(suite.T(), 14, id)
+	assert.Nil(suite.T(), err)
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	GraphFormatCSV     = "csv"
+	GraphFormatGraphML = "graphml"
+)
+
+type GraphExportService struct {
+	FollowerRepository repository.IFollowerRepository
+	UserRepository     repository.IUserRepository
+	Logger             *logrus.Entry
+}
+
+type IGraphExportService interface {
+	Export(io.Writer, *dto.GraphExportRequestDTO) error
+}
+
+func NewGraphExportService(followerRepository repository.IFollowerRepository, userRepository repository.IUserRepository, logger *logrus.Entry) IGraphExportService {
+	return &GraphExportService{
+		followerRepository,
+		userRepository,
+		logger,
+	}
+}
+
+// GraphContentType returns the MIME type of an export format.
+func GraphContentType(format string) string {
+	if strings.ToLower(format) == GraphFormatGraphML {
+		return "application/graphml+xml"
+	}
+	return "text/csv"
+}
+
+// Export streams the follow graph to w as a CSV edge list or a GraphML
+// document. Nothing but the current row is held in memory.
+//
+// Over HTTP the status is sent before the first rows, so a successful
+// response doesn't mean the export is complete. When the export fails after
+// part of it reached w, it is ended with a marker instead: a
+// "# export failed: ..." line for CSV, and for GraphML a comment of the same
+// text with the document left unclosed, so it doesn't parse.
+func (service *GraphExportService) Export(w io.Writer, request *dto.GraphExportRequestDTO) error {
+	filter, err := graphExportFilter(request)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return err
+	}
+
+	format := strings.ToLower(request.Format)
+	if format == "" {
+		format = GraphFormatCSV
+	}
+
+	service.Logger.Info(fmt.Sprintf("Exporting follow graph as %s", format))
+	out := &countingWriter{w: w}
+	switch format {
+	case GraphFormatCSV:
+		err = service.exportCSV(out, filter, request.WithUsers)
+	case GraphFormatGraphML:
+		err = service.exportGraphML(out, filter, request.WithUsers)
+	default:
+		err = errors.New(fmt.Sprintf("Unknown export format %s", request.Format))
+	}
+
+	if err != nil {
+		service.Logger.Debug(err.Error())
+	}
+	return err
+}
+
+func graphExportFilter(request *dto.GraphExportRequestDTO) (repository.FollowEdgeFilter, error) {
+	filter := repository.FollowEdgeFilter{ActiveOnly: request.ActiveOnly}
+
+	from, err := parseExportDate(request.From)
+	if err != nil {
+		return filter, err
+	}
+	to, err := parseExportDate(request.To)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.From = from
+	filter.To = to
+	return filter, nil
+}
+
+// parseExportDate accepts either a day (2006-01-02) or an RFC 3339 timestamp.
+func parseExportDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return &date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid date %s", value))
+	}
+	return &date, nil
+}
+
+func (service *GraphExportService) exportCSV(w *countingWriter, filter repository.FollowEdgeFilter, withUsers bool) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"follower_id", "following_id", "created_at"}
+	if withUsers {
+		header = append(header, "follower_username", "following_username")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	err := service.FollowerRepository.StreamEdges(filter, func(edge *dto.FollowEdgeDTO) error {
+		record := []string{strconv.Itoa(edge.FollowerId), strconv.Itoa(edge.FollowingId), formatExportTime(edge.CreatedAt)}
+		if withUsers {
+			record = append(record, edge.FollowerUsername, edge.FollowingUsername)
+		}
+		return writer.Write(record)
+	})
+	if err != nil {
+		if w.written > 0 {
+			writer.Flush()
+			fmt.Fprintf(w, "# export failed: %s\n", exportErrorText(err))
+		}
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportGraphML writes all users as nodes first and then the edges, each in
+// its own streamed query.
+func (service *GraphExportService) exportGraphML(w *countingWriter, filter repository.FollowEdgeFilter, withUsers bool) error {
+	out := bufio.NewWriter(w)
+
+	out.WriteString(xml.Header)
+	out.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	if withUsers {
+		out.WriteString(`  <key id="username" for="node" attr.name="username" attr.type="string"/>` + "\n")
+		out.WriteString(`  <key id="public" for="node" attr.name="public" attr.type="boolean"/>` + "\n")
+		out.WriteString(`  <key id="active" for="node" attr.name="active" attr.type="boolean"/>` + "\n")
+	}
+	out.WriteString(`  <key id="created_at" for="edge" attr.name="created_at" attr.type="string"/>` + "\n")
+	out.WriteString(`  <graph id="followers" edgedefault="directed">` + "\n")
+
+	err := service.UserRepository.StreamUsers(filter.ActiveOnly, func(user *model.User) error {
+		if !withUsers {
+			_, err := fmt.Fprintf(out, "    <node id=\"u%d\"/>\n", user.ID)
+			return err
+		}
+		fmt.Fprintf(out, "    <node id=\"u%d\">\n", user.ID)
+		out.WriteString(`      <data key="username">`)
+		xml.EscapeText(out, []byte(user.Username))
+		out.WriteString("</data>\n")
+		fmt.Fprintf(out, "      <data key=\"public\">%t</data>\n", user.Public)
+		fmt.Fprintf(out, "      <data key=\"active\">%t</data>\n", user.Active)
+		_, err := out.WriteString("    </node>\n")
+		return err
+	})
+	if err != nil {
+		return failGraphML(w, out, err)
+	}
+
+	err = service.FollowerRepository.StreamEdges(filter, func(edge *dto.FollowEdgeDTO) error {
+		_, err := fmt.Fprintf(out, "    <edge source=\"u%d\" target=\"u%d\"><data key=\"created_at\">%s</data></edge>\n",
+			edge.FollowerId, edge.FollowingId, formatExportTime(edge.CreatedAt))
+		return err
+	})
+	if err != nil {
+		return failGraphML(w, out, err)
+	}
+
+	out.WriteString("  </graph>\n")
+	out.WriteString("</graphml>\n")
+	return out.Flush()
+}
+
+// failGraphML ends a GraphML export that failed after part of it was
+// written, see Export.
+func failGraphML(w *countingWriter, out *bufio.Writer, err error) error {
+	if w.written > 0 {
+		fmt.Fprintf(out, "<!-- export failed: %s -->\n", strings.ReplaceAll(exportErrorText(err), "--", "- -"))
+		out.Flush()
+	}
+	return err
+}
+
+// exportErrorText is the error on a single line, for the failure markers.
+func exportErrorText(err error) string {
+	return strings.Join(strings.Fields(err.Error()), " ")
+}
+
+// countingWriter counts the bytes written to w, which tells whether an
+// export has sent anything yet.
+type countingWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	n, err := writer.w.Write(p)
+	writer.written += int64(n)
+	return n, err
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type GraphExportTestsSuite struct {
+	suite.Suite
+	followerRepositoryMock *repository.FollowerRepositoryMock
+	userRepositoryMock     *repository.UserRepositoryMock
+	service                IGraphExportService
+}
+
+func TestGraphExportTestsSuite(t *testing.T) {
+	suite.Run(t, new(GraphExportTestsSuite))
+}
+
+func (suite *GraphExportTestsSuite) SetupSuite() {
+	suite.followerRepositoryMock = new(repository.FollowerRepositoryMock)
+	suite.userRepositoryMock = new(repository.UserRepositoryMock)
+	suite.service = NewGraphExportService(suite.followerRepositoryMock, suite.userRepositoryMock, logging.Logger())
+}
+
+func (suite *GraphExportTestsSuite) TestExport_CSVWithUsers() {
+	createdAt := time.Date(2022, 7, 1, 10, 0, 0, 0, time.UTC)
+	edges := []dto.FollowEdgeDTO{
+		{FollowerId: 1, FollowingId: 2, CreatedAt: &createdAt, FollowerUsername: "pera", FollowingUsername: "mika"},
+	}
+	from := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	suite.followerRepositoryMock.On("StreamEdges", repository.FollowEdgeFilter{From: &from}).Return(edges, nil).Once()
+
+	var out bytes.Buffer
+	err := suite.service.Export(&out, &dto.GraphExportRequestDTO{Format: "csv", From: "2022-06-01", WithUsers: true})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "follower_id,following_id,created_at,follower_username,following_username\n1,2,2022-07-01T10:00:00Z,pera,mika\n", out.String())
+}
+
+func (suite *GraphExportTestsSuite) TestExport_GraphML() {
+	users := []model.User{{ID: 1, Username: "pera"}, {ID: 2, Username: "mika & co"}}
+	edges := []dto.FollowEdgeDTO{{FollowerId: 1, FollowingId: 2}}
+	suite.userRepositoryMock.On("StreamUsers", true).Return(users, nil).Once()
+	suite.followerRepositoryMock.On("StreamEdges", mock.MatchedBy(func(filter repository.FollowEdgeFilter) bool {
+		return filter.ActiveOnly
+	})).Return(edges, nil).Once()
+
+	var out bytes.Buffer
+	err := suite.service.Export(&out, &dto.GraphExportRequestDTO{Format: "graphml", ActiveOnly: true, WithUsers: true})
+
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), out.String(), `<data key="username">mika &amp; co</data>`)
+	assert.Contains(suite.T(), out.String(), `<edge source="u1" target="u2">`)
+}
+
+func (suite *GraphExportTestsSuite) TestExport_InvalidDate() {
+	err := suite.service.Export(&bytes.Buffer{}, &dto.GraphExportRequestDTO{From: "yesterday"})
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *GraphExportTestsSuite) TestExport_UnknownFormat() {
+	err := suite.service.Export(&bytes.Buffer{}, &dto.GraphExportRequestDTO{Format: "gexf"})
+
+	assert.Equal(suite.T(), errors.New("Unknown export format gexf"), err)
+}
+
+func (suite *GraphExportTestsSuite) TestExport_CSVMarksFailureAfterPartialOutput() {
+	edges := make([]dto.FollowEdgeDTO, 500)
+	for i := range edges {
+		edges[i] = dto.FollowEdgeDTO{FollowerId: i + 1, FollowingId: i + 2, FollowerUsername: "follower", FollowingUsername: "following"}
+	}
+	filter := repository.FollowEdgeFilter{ActiveOnly: true}
+	suite.followerRepositoryMock.On("StreamEdges", filter).Return(edges, errors.New("connection reset\nby peer")).Once()
+
+	var out bytes.Buffer
+	err := suite.service.Export(&out, &dto.GraphExportRequestDTO{Format: "csv", ActiveOnly: true, WithUsers: true})
+
+	assert.NotNil(suite.T(), err)
+	assert.Contains(suite.T(), out.String(), "500,501,,follower,following\n")
+	assert.True(suite.T(), strings.HasSuffix(out.String(), "\n# export failed: connection reset by peer\n"))
+}
+
+func (suite *GraphExportTestsSuite) TestExport_CSVFailureBeforeOutput() {
+	suite.followerRepositoryMock.On("StreamEdges", repository.FollowEdgeFilter{}).Return(nil, errors.New("connection refused")).Once()
+
+	var out bytes.Buffer
+	err := suite.service.Export(&out, &dto.GraphExportRequestDTO{Format: "csv"})
+
+	assert.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), 0, out.Len())
+}
+
+func (suite *GraphExportTestsSuite) TestExport_GraphMLMarksFailureAfterPartialOutput() {
+	users := make([]model.User, 200)
+	for i := range users {
+		users[i] = model.User{ID: i + 1, Username: "imported-user"}
+	}
+	suite.userRepositoryMock.On("StreamUsers", false).Return(users, nil).Once()
+	suite.followerRepositoryMock.On("StreamEdges", repository.FollowEdgeFilter{}).Return(nil, errors.New("query canceled -- timeout")).Once()
+
+	var out bytes.Buffer
+	err := suite.service.Export(&out, &dto.GraphExportRequestDTO{Format: "graphml", WithUsers: true})
+
+	assert.NotNil(suite.T(), err)
+	assert.True(suite.T(), strings.HasSuffix(out.String(), "    </node>\n<!-- export failed: query canceled - - timeout -->\n"))
+	assert.NotContains(suite.T(), out.String(), "</graphml>")
+}
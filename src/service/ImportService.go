@@ -0,0 +1,444 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"user-ms/src/auth0"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/mapper"
+	"user-ms/src/model"
+	"user-ms/src/rabbitmq"
+	"user-ms/src/repository"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ImportFormatNDJSON = "ndjson"
+	ImportFormatCSV    = "csv"
+
+	importCreated = "created"
+	importSkipped = "skipped"
+	importFailed  = "failed"
+)
+
+// ErrTooManyImportRows is returned when the input has more rows than the
+// import was allowed to take; nothing is imported then.
+var ErrTooManyImportRows = errors.New("too many rows to import")
+
+// ImportService bulk-loads users and follow edges, e.g. when migrating a
+// partner community. Imports are idempotent: users that already exist (by
+// email) and edges that already exist are skipped, so a failed run can be
+// repeated with the same input. Imported users and follows are announced
+// with the same domain events as registrations and follows. An import stops
+// at the next row once its context is cancelled.
+type ImportService struct {
+	UserRepo           repository.IUserRepository
+	FollowerRepository repository.IFollowerRepository
+	Transactions       repository.ITransactionManager
+	Auth0Client        auth0.Auth0Client
+	Auth0Interval      time.Duration
+	Logger             *logrus.Entry
+}
+
+type IImportService interface {
+	ImportUsers(context.Context, io.Reader, string, bool, int) (*dto.ImportReportDTO, error)
+	ImportFollows(context.Context, io.Reader, string, int) (*dto.ImportReportDTO, error)
+}
+
+func NewImportService(userRepository repository.IUserRepository, followerRepository repository.IFollowerRepository, transactions repository.ITransactionManager, auth0Client auth0.Auth0Client, auth0Interval time.Duration, logger *logrus.Entry) IImportService {
+	return &ImportService{
+		userRepository,
+		followerRepository,
+		transactions,
+		auth0Client,
+		auth0Interval,
+		logger,
+	}
+}
+
+// ImportUsers creates a user for every record. When createIdentities is set,
+// the matching Auth0 identity is created too, at most one every
+// Auth0Interval. With maxRows above 0 the whole input is read first and
+// rejected with ErrTooManyImportRows if it's longer. The error is only set
+// when the input can't be read at all, is too long or the import was
+// cancelled; per-row problems end up in the report.
+func (service *ImportService) ImportUsers(ctx context.Context, r io.Reader, format string, createIdentities bool, maxRows int) (*dto.ImportReportDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ImportService.ImportUsers")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Importing users from %s", format))
+	report := &dto.ImportReportDTO{}
+
+	var throttle <-chan time.Time
+	if createIdentities && service.Auth0Interval > 0 {
+		ticker := time.NewTicker(service.Auth0Interval)
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	err := readLimitedImportRecords(r, format, maxRows, func(row int, decode func(interface{}) error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var record dto.UserImportDTO
+		if err := decode(&record); err != nil {
+			addImportResult(report, row, "", importFailed, err)
+			return nil
+		}
+
+		status, err := service.importUser(ctx, &record, createIdentities, throttle)
+		addImportResult(report, row, record.Email, status, err)
+		return nil
+	}, csvUserImport)
+
+	logger.Info(fmt.Sprintf("Imported users: %d created, %d skipped, %d failed", report.Created, report.Skipped, report.Failed))
+	return report, err
+}
+
+func (service *ImportService) importUser(ctx context.Context, record *dto.UserImportDTO, createIdentities bool, throttle <-chan time.Time) (string, error) {
+	if err := ValidatePassword(record.Password); err != nil {
+		return importFailed, err
+	}
+
+	gender, err := parseImportGender(record.Gender)
+	if err != nil {
+		return importFailed, err
+	}
+
+	user := mapper.UserImportDTOToUser(record, gender)
+	if err := user.Validate(); err != nil {
+		return importFailed, err
+	}
+
+	if _, err := service.UserRepo.GetByEmail(user.Email); err == nil {
+		return importSkipped, errors.New("user already exists")
+	}
+	if _, err := service.UserRepo.GetByExactUsername(user.Username); err == nil {
+		return importFailed, errors.New(fmt.Sprintf("username %s is taken", user.Username))
+	}
+
+	if user.Password, err = HashPassword(user.Password); err != nil {
+		return importFailed, err
+	}
+
+	if !createIdentities {
+		err := service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+			userID, err := repositories.Users.AddUser(user)
+			if err != nil {
+				return err
+			}
+			user.ID = userID
+			return enqueueEvent(ctx, repositories.Outbox, rabbitmq.UserRegistered, mapper.UserToEventDTO(user))
+		})
+		if err != nil {
+			return importFailed, err
+		}
+		return importCreated, nil
+	}
+
+	if throttle != nil {
+		select {
+		case <-throttle:
+		case <-ctx.Done():
+			return importFailed, ctx.Err()
+		}
+	}
+
+	userID, err := service.UserRepo.AddUser(user)
+	if err != nil {
+		return importFailed, err
+	}
+	user.ID = userID
+
+	auth0ID, err := service.Auth0Client.Register(ctx, record.Email, record.Password)
+	if err != nil {
+		// Roll back so the row is imported again on the next run.
+		if deleteErr := service.UserRepo.DeleteUser(userID); deleteErr != nil {
+			service.Logger.Debug(deleteErr.Error())
+		}
+		return importFailed, err
+	}
+
+	user.Auth0ID = auth0ID
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		if _, err := repositories.Users.Update(user); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, repositories.Outbox, rabbitmq.UserRegistered, mapper.UserToEventDTO(user))
+	})
+	if err != nil {
+		// Without the Auth0 ID the identity would be orphaned and the next
+		// run would fail on it, so both are removed, even when the import
+		// has been cancelled meanwhile.
+		if deleteErr := service.Auth0Client.Delete(context.Background(), auth0ID); deleteErr != nil {
+			service.Logger.Error(fmt.Sprintf("Can't delete Auth0 user %s: %s", auth0ID, deleteErr.Error()))
+		}
+		if deleteErr := service.UserRepo.DeleteUser(userID); deleteErr != nil {
+			service.Logger.Debug(deleteErr.Error())
+		}
+		return importFailed, err
+	}
+
+	return importCreated, nil
+}
+
+// ImportFollows creates follow edges between users referenced by username.
+// maxRows and the error are as for ImportUsers.
+func (service *ImportService) ImportFollows(ctx context.Context, r io.Reader, format string, maxRows int) (*dto.ImportReportDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ImportService.ImportFollows")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Importing follows from %s", format))
+	report := &dto.ImportReportDTO{}
+
+	err := readLimitedImportRecords(r, format, maxRows, func(row int, decode func(interface{}) error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var record dto.FollowImportDTO
+		if err := decode(&record); err != nil {
+			addImportResult(report, row, "", importFailed, err)
+			return nil
+		}
+
+		status, err := service.importFollow(ctx, &record)
+		addImportResult(report, row, fmt.Sprintf("%s->%s", record.FollowerUsername, record.FollowingUsername), status, err)
+		return nil
+	}, csvFollowImport)
+
+	logger.Info(fmt.Sprintf("Imported follows: %d created, %d skipped, %d failed", report.Created, report.Skipped, report.Failed))
+	return report, err
+}
+
+func (service *ImportService) importFollow(ctx context.Context, record *dto.FollowImportDTO) (string, error) {
+	follower, err := service.UserRepo.GetByExactUsername(record.FollowerUsername)
+	if err != nil {
+		return importFailed, err
+	}
+	following, err := service.UserRepo.GetByExactUsername(record.FollowingUsername)
+	if err != nil {
+		return importFailed, err
+	}
+	if follower.ID == following.ID {
+		return importFailed, errors.New("user can't follow himself")
+	}
+
+	decidedAt := time.Now()
+	edge := model.Follower{FollowerId: follower.ID, FollowingId: following.ID, ActorId: follower.ID, DecidedAt: &decidedAt}
+	if record.CreatedAt != nil {
+		edge.CreatedAt = *record.CreatedAt
+		edge.DecidedAt = record.CreatedAt
+	}
+
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		if _, err := repositories.Followers.AddFollower(&edge); err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, repositories.Outbox, rabbitmq.FollowCreated, mapper.FollowerToEventDTO(&edge))
+	})
+	if err != nil {
+		if err.Error() == "the couple already exists" {
+			return importSkipped, err
+		}
+		return importFailed, err
+	}
+
+	return importCreated, nil
+}
+
+func addImportResult(report *dto.ImportReportDTO, row int, key string, status string, err error) {
+	report.Total++
+	switch status {
+	case importCreated:
+		report.Created++
+		return
+	case importSkipped:
+		report.Skipped++
+	default:
+		status = importFailed
+		report.Failed++
+	}
+
+	result := dto.ImportRowResultDTO{Row: row, Key: key, Status: status}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	report.Rows = append(report.Rows, result)
+}
+
+type importRecord struct {
+	row    int
+	decode func(interface{}) error
+}
+
+// readLimitedImportRecords is readImportRecords for inputs of at most
+// maxRows records, or any length if maxRows isn't above 0. The records are
+// read before fn is called for any of them, so a longer input is rejected
+// as a whole.
+func readLimitedImportRecords(r io.Reader, format string, maxRows int, fn func(int, func(interface{}) error) error, fromCSV func(map[string]string, interface{}) error) error {
+	if maxRows <= 0 {
+		return readImportRecords(r, format, fn, fromCSV)
+	}
+
+	var records []importRecord
+	err := readImportRecords(r, format, func(row int, decode func(interface{}) error) error {
+		if len(records) == maxRows {
+			return fmt.Errorf("%w, at most %d are accepted", ErrTooManyImportRows, maxRows)
+		}
+		records = append(records, importRecord{row, decode})
+		return nil
+	}, fromCSV)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := fn(record.row, record.decode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readImportRecords calls fn once per input record with a function that
+// decodes the record into a DTO, and stops at the first error fn returns.
+// Rows are numbered from 1; for CSV the header is not counted.
+func readImportRecords(r io.Reader, format string, fn func(int, func(interface{}) error) error, fromCSV func(map[string]string, interface{}) error) error {
+	switch strings.ToLower(format) {
+	case ImportFormatNDJSON, "":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		row := 0
+		for scanner.Scan() {
+			// The scanner reuses its buffer, and the decoder may be called
+			// after the next line was read.
+			line := append([]byte(nil), scanner.Bytes()...)
+			if len(strings.TrimSpace(string(line))) == 0 {
+				continue
+			}
+			row++
+			if err := fn(row, func(v interface{}) error {
+				return json.Unmarshal(line, v)
+			}); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	case ImportFormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return err
+		}
+		for i := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		}
+		row := 0
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			row++
+			if err != nil {
+				// Malformed rows are reported and skipped; anything else,
+				// like a truncated body, would fail again on every read.
+				var parseErr *csv.ParseError
+				if !errors.As(err, &parseErr) {
+					return err
+				}
+				if err := fn(row, func(interface{}) error { return err }); err != nil {
+					return err
+				}
+				continue
+			}
+			fields := make(map[string]string, len(header))
+			for i, column := range header {
+				if i < len(record) {
+					fields[column] = strings.TrimSpace(record[i])
+				}
+			}
+			if err := fn(row, func(v interface{}) error {
+				return fromCSV(fields, v)
+			}); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unknown import format %s", format))
+	}
+}
+
+func csvUserImport(fields map[string]string, v interface{}) error {
+	record := v.(*dto.UserImportDTO)
+	record.Username = fields["username"]
+	record.FirstName = fields["first_name"]
+	record.LastName = fields["last_name"]
+	record.Email = fields["email"]
+	record.Password = fields["password"]
+	record.PhoneNumber = fields["phone_number"]
+	record.Gender = fields["gender"]
+	record.Biography = fields["biography"]
+	record.Education = fields["education"]
+	record.WorkExperience = fields["work_experience"]
+	record.Skills = fields["skills"]
+	record.Interests = fields["interests"]
+
+	if value := fields["date_of_birth"]; value != "" {
+		dateOfBirth, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid date_of_birth %s", value))
+		}
+		record.DateOfBirth = float32(dateOfBirth)
+	}
+	if value := fields["public"]; value != "" {
+		public, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid public %s", value))
+		}
+		record.Public = public
+	}
+	return nil
+}
+
+func csvFollowImport(fields map[string]string, v interface{}) error {
+	record := v.(*dto.FollowImportDTO)
+	record.FollowerUsername = fields["follower_username"]
+	record.FollowingUsername = fields["following_username"]
+
+	if value := fields["created_at"]; value != "" {
+		createdAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid created_at %s", value))
+		}
+		record.CreatedAt = &createdAt
+	}
+	return nil
+}
+
+func parseImportGender(value string) (model.Gender, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "male", "m", "0":
+		return model.Male, nil
+	case "female", "f", "1":
+		return model.Female, nil
+	default:
+		return model.Male, errors.New(fmt.Sprintf("Invalid gender %s", value))
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"user-ms/src/auth0"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ImportTestsSuite struct {
+	suite.Suite
+	userRepositoryMock     *repository.UserRepositoryMock
+	followerRepositoryMock *repository.FollowerRepositoryMock
+	outboxRepositoryMock   *repository.OutboxRepositoryMock
+	auth0ClientMock        *auth0.Auth0ClientMock
+	service                IImportService
+}
+
+func TestImportTestsSuite(t *testing.T) {
+	suite.Run(t, new(ImportTestsSuite))
+}
+
+func (suite *ImportTestsSuite) SetupSuite() {
+	suite.userRepositoryMock = new(repository.UserRepositoryMock)
+	suite.followerRepositoryMock = new(repository.FollowerRepositoryMock)
+	suite.outboxRepositoryMock = new(repository.OutboxRepositoryMock)
+	suite.auth0ClientMock = new(auth0.Auth0ClientMock)
+	transactions := &repository.TransactionManagerMock{Repositories: &repository.Repositories{
+		Users:     suite.userRepositoryMock,
+		Followers: suite.followerRepositoryMock,
+		Outbox:    suite.outboxRepositoryMock,
+	}}
+	suite.service = NewImportService(suite.userRepositoryMock, suite.followerRepositoryMock, transactions, suite.auth0ClientMock, 0, logging.Logger())
+}
+
+func (suite *ImportTestsSuite) TestImportUsers_NDJSON() {
+	input := strings.Join([]string{
+		`{"username":"pera","first_name":"Pera","last_name":"Peric","email":"pera@partner.com","password":"password1","gender":"male"}`,
+		`{"username":"mika","first_name":"Mika","last_name":"Mikic","email":"mika@partner.com","password":"short","gender":"male"}`,
+		`{"username":"zika","first_name":"Zika","last_name":"Zikic","email":"zika@partner.com","password":"password1","gender":"male"}`,
+		`not json`,
+	}, "\n")
+
+	suite.userRepositoryMock.On("GetByEmail", "pera@partner.com").Return(nil, errors.New("User with email pera@partner.com not found")).Once()
+	suite.userRepositoryMock.On("GetByExactUsername", "pera").Return(nil, errors.New("User with username pera not found")).Once()
+	suite.userRepositoryMock.On("AddUser", mock.AnythingOfType("*model.User")).Return(7, nil).Once()
+	suite.auth0ClientMock.On("Register", "pera@partner.com", "password1").Return("auth0|7", nil).Once()
+	suite.userRepositoryMock.On("Update", mock.MatchedBy(func(user *model.User) bool {
+		return user.Auth0ID == "auth0|7"
+	})).Return(&dto.UserResponseDTO{ID: 7}, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "user.registered.v1"
+	})).Return(nil).Once()
+	suite.userRepositoryMock.On("GetByEmail", "zika@partner.com").Return(&dto.UserResponseDTO{ID: 8}, nil).Once()
+
+	report, err := suite.service.ImportUsers(context.Background(), strings.NewReader(input), ImportFormatNDJSON, true, 0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 4, report.Total)
+	assert.Equal(suite.T(), 1, report.Created)
+	assert.Equal(suite.T(), 1, report.Skipped)
+	assert.Equal(suite.T(), 2, report.Failed)
+	assert.Equal(suite.T(), 2, report.Rows[0].Row)
+	assert.Equal(suite.T(), "Password must be at least 8 characters long!", report.Rows[0].Error)
+}
+
+func (suite *ImportTestsSuite) TestImportUsers_WithoutIdentities() {
+	input := `{"username":"steva","first_name":"Steva","last_name":"Stevic","email":"steva@partner.com","password":"password1","gender":"male"}`
+
+	suite.userRepositoryMock.On("GetByEmail", "steva@partner.com").Return(nil, errors.New("User with email steva@partner.com not found")).Once()
+	suite.userRepositoryMock.On("GetByExactUsername", "steva").Return(nil, errors.New("User with username steva not found")).Once()
+	suite.userRepositoryMock.On("AddUser", mock.MatchedBy(func(user *model.User) bool {
+		return user.Username == "steva"
+	})).Return(10, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "user.registered.v1" && strings.Contains(message.Body, "steva")
+	})).Return(nil).Once()
+
+	report, err := suite.service.ImportUsers(context.Background(), strings.NewReader(input), ImportFormatNDJSON, false, 0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, report.Created)
+	suite.auth0ClientMock.AssertNotCalled(suite.T(), "Register", "steva@partner.com", "password1")
+}
+
+func (suite *ImportTestsSuite) TestImportUsers_RemovesIdentityWhenSaveFails() {
+	input := `{"username":"laza","first_name":"Laza","last_name":"Lazic","email":"laza@partner.com","password":"password1","gender":"male"}`
+
+	suite.userRepositoryMock.On("GetByEmail", "laza@partner.com").Return(nil, errors.New("User with email laza@partner.com not found")).Once()
+	suite.userRepositoryMock.On("GetByExactUsername", "laza").Return(nil, errors.New("User with username laza not found")).Once()
+	suite.userRepositoryMock.On("AddUser", mock.AnythingOfType("*model.User")).Return(9, nil).Once()
+	suite.auth0ClientMock.On("Register", "laza@partner.com", "password1").Return("auth0|9", nil).Once()
+	suite.userRepositoryMock.On("Update", mock.MatchedBy(func(user *model.User) bool {
+		return user.Auth0ID == "auth0|9"
+	})).Return(nil, errors.New("connection reset")).Once()
+	suite.auth0ClientMock.On("Delete", "auth0|9").Return(nil).Once()
+	suite.userRepositoryMock.On("DeleteUser", 9).Return(nil).Once()
+
+	report, err := suite.service.ImportUsers(context.Background(), strings.NewReader(input), ImportFormatNDJSON, true, 0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, report.Failed)
+	suite.auth0ClientMock.AssertCalled(suite.T(), "Delete", "auth0|9")
+	suite.userRepositoryMock.AssertCalled(suite.T(), "DeleteUser", 9)
+}
+
+func (suite *ImportTestsSuite) TestImportFollows_CSV() {
+	input := "follower_username,following_username\npera,mika\npera,zika\n"
+
+	suite.userRepositoryMock.On("GetByExactUsername", "pera").Return(&model.User{ID: 1}, nil).Twice()
+	suite.userRepositoryMock.On("GetByExactUsername", "mika").Return(&model.User{ID: 2}, nil).Once()
+	suite.userRepositoryMock.On("GetByExactUsername", "zika").Return(&model.User{ID: 3}, nil).Once()
+	suite.followerRepositoryMock.On("AddFollower", mock.MatchedBy(func(follower *model.Follower) bool {
+		return follower.FollowingId == 2
+	})).Return(1, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "follow.created.v1"
+	})).Return(nil).Once()
+	suite.followerRepositoryMock.On("AddFollower", mock.MatchedBy(func(follower *model.Follower) bool {
+		return follower.FollowingId == 3
+	})).Return(-1, errors.New("the couple already exists")).Once()
+
+	report, err := suite.service.ImportFollows(context.Background(), strings.NewReader(input), ImportFormatCSV, 0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, report.Created)
+	assert.Equal(suite.T(), 1, report.Skipped)
+	assert.Equal(suite.T(), "pera->zika", report.Rows[0].Key)
+}
+
+func (suite *ImportTestsSuite) TestImportFollows_CSVReadError() {
+	input := io.MultiReader(strings.NewReader("follower_username,following_username\n"), iotest.ErrReader(errors.New("unexpected EOF")))
+
+	report, err := suite.service.ImportFollows(context.Background(), input, ImportFormatCSV, 0)
+
+	assert.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), 0, report.Total)
+}
+
+func (suite *ImportTestsSuite) TestImportFollows_CSVParseError() {
+	input := "follower_username,following_username\n\"pera,mika\n"
+
+	report, err := suite.service.ImportFollows(context.Background(), strings.NewReader(input), ImportFormatCSV, 0)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, report.Failed)
+}
+
+func (suite *ImportTestsSuite) TestImport_UnknownFormat() {
+	_, err := suite.service.ImportFollows(context.Background(), strings.NewReader(""), "xml", 0)
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ImportTestsSuite) TestImportFollows_TooManyRows() {
+	input := "follower_username,following_username\npera,mika\npera,zika\nmika,zika\n"
+
+	report, err := suite.service.ImportFollows(context.Background(), strings.NewReader(input), ImportFormatCSV, 2)
+
+	assert.True(suite.T(), errors.Is(err, ErrTooManyImportRows))
+	assert.Equal(suite.T(), 0, report.Total)
+}
+
+func (suite *ImportTestsSuite) TestImportUsers_NDJSONWithinLimit() {
+	input := strings.Join([]string{
+		`{"username":"jova","first_name":"Jova","last_name":"Jovic","email":"jova@partner.com","password":"password1","gender":"male"}`,
+		`{"username":"nata","first_name":"Nata","last_name":"Natic","email":"nata@partner.com","password":"password1","gender":"female"}`,
+	}, "\n")
+
+	suite.userRepositoryMock.On("GetByEmail", "jova@partner.com").Return(&dto.UserResponseDTO{ID: 11}, nil).Once()
+	suite.userRepositoryMock.On("GetByEmail", "nata@partner.com").Return(&dto.UserResponseDTO{ID: 12}, nil).Once()
+
+	report, err := suite.service.ImportUsers(context.Background(), strings.NewReader(input), ImportFormatNDJSON, false, 2)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, report.Skipped)
+	assert.Equal(suite.T(), "jova@partner.com", report.Rows[0].Key)
+	assert.Equal(suite.T(), "nata@partner.com", report.Rows[1].Key)
+}
+
+func (suite *ImportTestsSuite) TestImportFollows_StopsWhenCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := suite.service.ImportFollows(ctx, strings.NewReader("follower_username,following_username\npera,mika\n"), ImportFormatCSV, 0)
+
+	assert.True(suite.T(), errors.Is(err, context.Canceled))
+	assert.Equal(suite.T(), 0, report.Total)
+}
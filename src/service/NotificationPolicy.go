@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationPolicy decides whether and how a notification is sent to its
+// recipient. Every notification users-ms produces goes through it, and other
+// services ask it through the notification check endpoint.
+type NotificationPolicy struct {
+	UserRepository       repository.IUserRepository
+	PreferenceRepository repository.INotificationPreferenceRepository
+	Logger               *logrus.Entry
+}
+
+type INotificationPolicy interface {
+	Allow(*model.User, dto.NotificationType) bool
+	Delivery(*model.User, dto.NotificationType, time.Time) dto.NotificationDeliveryDTO
+	IsAllowed(int, dto.NotificationType) (bool, error)
+	Check(int, dto.NotificationType) (*dto.NotificationCheckDTO, error)
+}
+
+func NewNotificationPolicy(userRepository repository.IUserRepository, preferenceRepository repository.INotificationPreferenceRepository, logger *logrus.Entry) INotificationPolicy {
+	return &NotificationPolicy{
+		userRepository,
+		preferenceRepository,
+		logger,
+	}
+}
+
+// Allow reports whether the notification would be delivered through any
+// channel right now.
+func (policy *NotificationPolicy) Allow(recipient *model.User, notificationType dto.NotificationType) bool {
+	return len(policy.Delivery(recipient, notificationType, time.Now()).Channels) > 0
+}
+
+// Delivery picks the channels from the recipient's preference matrix, see
+// preferenceTypeOf. Push is left out during quiet hours; in-app and email,
+// which may be batched into a digest, are not intrusive. Without a preference
+// repository, or when it fails, the legacy booleans decide.
+func (policy *NotificationPolicy) Delivery(recipient *model.User, notificationType dto.NotificationType, now time.Time) dto.NotificationDeliveryDTO {
+	if recipient == nil {
+		return dto.NotificationDeliveryDTO{}
+	}
+
+	preferenceType := preferenceTypeOf(notificationType)
+	preference := defaultPreference(recipient, preferenceType)
+	var schedule *model.NotificationSchedule
+	if policy.PreferenceRepository != nil {
+		stored, err := policy.PreferenceRepository.GetPreferences(recipient.ID)
+		if err == nil {
+			schedule, err = policy.PreferenceRepository.GetSchedule(recipient.ID)
+		}
+		if err != nil {
+			policy.Logger.Error(fmt.Sprintf("Can't load notification preferences of user with id %d, using the defaults: %s", recipient.ID, err.Error()))
+		}
+		for _, row := range stored {
+			if row.NotificationType == preferenceType.String() {
+				preference = row
+			}
+		}
+	}
+
+	var delivery dto.NotificationDeliveryDTO
+	if preference.InApp {
+		delivery.Channels = append(delivery.Channels, string(model.InAppChannel))
+	}
+	if preference.Email {
+		delivery.Channels = append(delivery.Channels, string(model.EmailChannel))
+		delivery.Digest = string(preference.Digest)
+	}
+	if preference.Push && !inQuietHours(schedule, now) {
+		delivery.Channels = append(delivery.Channels, string(model.PushChannel))
+	}
+	return delivery
+}
+
+func (policy *NotificationPolicy) IsAllowed(userId int, notificationType dto.NotificationType) (bool, error) {
+	check, err := policy.Check(userId, notificationType)
+	if err != nil {
+		return false, err
+	}
+	return check.Allowed, nil
+}
+
+func (policy *NotificationPolicy) Check(userId int, notificationType dto.NotificationType) (*dto.NotificationCheckDTO, error) {
+	user, err := policy.UserRepository.GetByID(userId)
+	if err != nil {
+		policy.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	delivery := policy.Delivery(user, notificationType, time.Now())
+	allowed := len(delivery.Channels) > 0
+	policy.Logger.Debug(fmt.Sprintf("%s notifications allowed for user with id %d: %t", notificationType, userId, allowed))
+	return &dto.NotificationCheckDTO{
+		UserId:           userId,
+		NotificationType: notificationType.String(),
+		Allowed:          allowed,
+		Channels:         delivery.Channels,
+		Digest:           delivery.Digest,
+	}, nil
+}
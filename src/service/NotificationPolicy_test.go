@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotificationPolicyTestsSuite struct {
+	suite.Suite
+	userRepositoryMock *repository.UserRepositoryMock
+	policy             INotificationPolicy
+}
+
+func TestNotificationPolicyTestsSuite(t *testing.T) {
+	suite.Run(t, new(NotificationPolicyTestsSuite))
+}
+
+func (suite *NotificationPolicyTestsSuite) SetupSuite() {
+	suite.userRepositoryMock = new(repository.UserRepositoryMock)
+	suite.policy = NewNotificationPolicy(suite.userRepositoryMock, nil, logging.Logger())
+}
+
+func (suite *NotificationPolicyTestsSuite) TestAllow() {
+	user := &model.User{FollowNotifications: true, LikeNotifications: true}
+
+	assert.True(suite.T(), suite.policy.Allow(user, dto.Follow))
+	assert.True(suite.T(), suite.policy.Allow(user, dto.Connection))
+	assert.True(suite.T(), suite.policy.Allow(user, dto.Like))
+	assert.False(suite.T(), suite.policy.Allow(user, dto.Message))
+	assert.False(suite.T(), suite.policy.Allow(user, dto.Comment))
+	assert.False(suite.T(), suite.policy.Allow(nil, dto.Follow))
+}
+
+func (suite *NotificationPolicyTestsSuite) TestIsAllowed() {
+	suite.userRepositoryMock.On("GetByID", 1).Return(&model.User{ID: 1, CommentNotifications: true}, nil).Once()
+
+	allowed, err := suite.policy.IsAllowed(1, dto.Comment)
+
+	assert.True(suite.T(), allowed)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *NotificationPolicyTestsSuite) TestIsAllowed_UnknownUser() {
+	suite.userRepositoryMock.On("GetByID", 2).Return(nil, errors.New("user not found")).Once()
+
+	allowed, err := suite.policy.IsAllowed(2, dto.Comment)
+
+	assert.False(suite.T(), allowed)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *NotificationPolicyTestsSuite) TestParseNotificationType() {
+	notificationType, err := dto.ParseNotificationType("Like")
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), dto.Like, notificationType)
+
+	_, err = dto.ParseNotificationType("poke")
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *NotificationPolicyTestsSuite) TestDelivery_PreferenceMatrix() {
+	preferenceRepositoryMock := new(repository.NotificationPreferenceRepositoryMock)
+	policy := NewNotificationPolicy(suite.userRepositoryMock, preferenceRepositoryMock, logging.Logger())
+	user := &model.User{ID: 3, LikeNotifications: true}
+	preferenceRepositoryMock.On("GetPreferences", 3).Return([]model.NotificationPreference{
+		{UserId: 3, NotificationType: "follow", Email: true, Push: true, Digest: model.DailyDigest},
+	}, nil)
+	preferenceRepositoryMock.On("GetSchedule", 3).Return(&model.NotificationSchedule{UserId: 3, QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "Europe/Belgrade"}, nil)
+	belgrade, _ := time.LoadLocation("Europe/Belgrade")
+
+	noon := policy.Delivery(user, dto.Follow, time.Date(2022, 7, 1, 12, 0, 0, 0, belgrade))
+	night := policy.Delivery(user, dto.Follow, time.Date(2022, 7, 1, 23, 30, 0, 0, belgrade))
+	like := policy.Delivery(user, dto.Like, time.Date(2022, 7, 1, 12, 0, 0, 0, belgrade))
+
+	assert.Equal(suite.T(), dto.NotificationDeliveryDTO{Channels: []string{"email", "push"}, Digest: "daily"}, noon)
+	assert.Equal(suite.T(), dto.NotificationDeliveryDTO{Channels: []string{"email"}, Digest: "daily"}, night)
+	assert.Equal(suite.T(), []string{"in_app", "push"}, like.Channels)
+	assert.Empty(suite.T(), policy.Delivery(user, dto.Message, time.Now()).Channels)
+}
+
+func (suite *NotificationPolicyTestsSuite) TestInQuietHours() {
+	schedule := &model.NotificationSchedule{QuietHoursStart: "09:00", QuietHoursEnd: "17:00"}
+
+	assert.True(suite.T(), inQuietHours(schedule, time.Date(2022, 7, 1, 9, 0, 0, 0, time.UTC)))
+	assert.False(suite.T(), inQuietHours(schedule, time.Date(2022, 7, 1, 17, 0, 0, 0, time.UTC)))
+	assert.False(suite.T(), inQuietHours(nil, time.Now()))
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/mapper"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+const quietHoursLayout = "15:04"
+
+// NotificationPreferenceService manages the notification preference matrix.
+// The legacy notification booleans on the user are kept in sync with it, so
+// the old set-notifications endpoints keep working as a coarse view.
+type NotificationPreferenceService struct {
+	UserRepository repository.IUserRepository
+	Transactions   repository.ITransactionManager
+	Logger         *logrus.Entry
+}
+
+type INotificationPreferenceService interface {
+	GetPreferences(string) (*dto.NotificationPreferencesDTO, error)
+	UpdatePreferences(string, *dto.NotificationPreferencesDTO) (*dto.NotificationPreferencesDTO, error)
+}
+
+func NewNotificationPreferenceService(userRepository repository.IUserRepository, transactions repository.ITransactionManager, logger *logrus.Entry) INotificationPreferenceService {
+	return &NotificationPreferenceService{
+		userRepository,
+		transactions,
+		logger,
+	}
+}
+
+func (service *NotificationPreferenceService) GetPreferences(userAuth0ID string) (*dto.NotificationPreferencesDTO, error) {
+	service.Logger.Info(fmt.Sprintf("Getting notification preferences for user %s", userAuth0ID))
+	user, err := service.UserRepository.GetByAuth0ID(userAuth0ID)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	var preferences *dto.NotificationPreferencesDTO
+	err = service.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		stored, err := repositories.NotificationPreferences.GetPreferences(user.ID)
+		if err != nil {
+			return err
+		}
+		schedule, err := repositories.NotificationPreferences.GetSchedule(user.ID)
+		if err != nil {
+			return err
+		}
+
+		preferences = mapper.NotificationPreferencesToDTO(effectivePreferences(user, stored), schedule)
+		return nil
+	})
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences changes the types listed in update and leaves the others
+// as they are. Quiet hours and timezone are always replaced.
+func (service *NotificationPreferenceService) UpdatePreferences(userAuth0ID string, update *dto.NotificationPreferencesDTO) (*dto.NotificationPreferencesDTO, error) {
+	service.Logger.Info(fmt.Sprintf("Updating notification preferences for user %s", userAuth0ID))
+	if err := validateNotificationPreferences(update); err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	user, err := service.UserRepository.GetByAuth0ID(userAuth0ID)
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	var preferences *dto.NotificationPreferencesDTO
+	err = service.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		stored, err := repositories.NotificationPreferences.GetPreferences(user.ID)
+		if err != nil {
+			return err
+		}
+
+		matrix := effectivePreferences(user, stored)
+		for i := range update.Preferences {
+			updated := mapper.NotificationPreferenceDTOToPreference(&update.Preferences[i])
+			updated.NotificationType = strings.ToLower(updated.NotificationType)
+			if updated.Digest == "" {
+				updated.Digest = model.InstantDigest
+			}
+			for j := range matrix {
+				if matrix[j].NotificationType == updated.NotificationType {
+					matrix[j] = *updated
+				}
+			}
+		}
+
+		if err := repositories.NotificationPreferences.SavePreferences(user.ID, matrix); err != nil {
+			return err
+		}
+		schedule := &model.NotificationSchedule{UserId: user.ID, QuietHoursStart: update.QuietHoursStart, QuietHoursEnd: update.QuietHoursEnd, Timezone: update.Timezone}
+		if err := repositories.NotificationPreferences.SaveSchedule(schedule); err != nil {
+			return err
+		}
+
+		syncLegacyNotifications(user, matrix)
+		if _, err := repositories.Users.Update(user); err != nil {
+			return err
+		}
+
+		preferences = mapper.NotificationPreferencesToDTO(matrix, schedule)
+		return nil
+	})
+	if err != nil {
+		service.Logger.Debug(err.Error())
+		return nil, err
+	}
+
+	service.Logger.Info(fmt.Sprintf("Successfully updated notification preferences for user %s", userAuth0ID))
+	return preferences, nil
+}
+
+func validateNotificationPreferences(preferences *dto.NotificationPreferencesDTO) error {
+	for _, preference := range preferences.Preferences {
+		notificationType, err := dto.ParseNotificationType(preference.NotificationType)
+		if err != nil || preferenceTypeOf(notificationType) != notificationType {
+			return fmt.Errorf("no preferences for notification type %s", preference.NotificationType)
+		}
+		switch model.DigestFrequency(preference.Digest) {
+		case "", model.InstantDigest, model.DailyDigest, model.WeeklyDigest:
+		default:
+			return fmt.Errorf("unknown digest frequency: %s", preference.Digest)
+		}
+	}
+
+	if (preferences.QuietHoursStart == "") != (preferences.QuietHoursEnd == "") {
+		return errors.New("quiet hours need both a start and an end")
+	}
+	for _, clock := range []string{preferences.QuietHoursStart, preferences.QuietHoursEnd} {
+		if _, err := time.Parse(quietHoursLayout, clock); clock != "" && err != nil {
+			return fmt.Errorf("quiet hours must look like 22:00, got %s", clock)
+		}
+	}
+	if _, err := time.LoadLocation(preferences.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone: %s", preferences.Timezone)
+	}
+	return nil
+}
+
+// effectivePreferences fills in the types the user has no stored row for.
+// Those follow the legacy booleans: in-app and push when the type is on,
+// nothing otherwise, and no email.
+func effectivePreferences(user *model.User, stored []model.NotificationPreference) []model.NotificationPreference {
+	matrix := make([]model.NotificationPreference, 0, len(dto.NotificationTypes))
+	for _, notificationType := range dto.NotificationTypes {
+		preference := defaultPreference(user, notificationType)
+		for _, row := range stored {
+			if row.NotificationType == notificationType.String() {
+				preference = row
+			}
+		}
+		matrix = append(matrix, preference)
+	}
+	return matrix
+}
+
+func defaultPreference(user *model.User, notificationType dto.NotificationType) model.NotificationPreference {
+	enabled := legacyNotifications(user, notificationType)
+	return model.NotificationPreference{
+		UserId:           user.ID,
+		NotificationType: notificationType.String(),
+		InApp:            enabled,
+		Push:             enabled,
+		Digest:           model.InstantDigest,
+	}
+}
+
+// preferenceTypeOf returns the type whose preferences apply to notifications
+// of notificationType.
+func preferenceTypeOf(notificationType dto.NotificationType) dto.NotificationType {
+	if notificationType == dto.FollowRequestOutcome {
+		return dto.Follow
+	}
+	return notificationType
+}
+
+// legacyNotifications reads the boolean that used to decide everything.
+// Connection notifications follow the follow notification setting.
+func legacyNotifications(user *model.User, notificationType dto.NotificationType) bool {
+	switch notificationType {
+	case dto.Message:
+		return user.MessageNotifications
+	case dto.Follow, dto.Connection:
+		return user.FollowNotifications
+	case dto.Like:
+		return user.LikeNotifications
+	case dto.Comment:
+		return user.CommentNotifications
+	}
+	return false
+}
+
+// syncLegacyNotifications sets each legacy boolean to whether the type is
+// delivered through any channel.
+func syncLegacyNotifications(user *model.User, matrix []model.NotificationPreference) {
+	for _, preference := range matrix {
+		enabled := preference.InApp || preference.Email || preference.Push
+		switch preference.NotificationType {
+		case dto.Message.String():
+			user.MessageNotifications = enabled
+		case dto.Follow.String():
+			user.FollowNotifications = enabled
+		case dto.Like.String():
+			user.LikeNotifications = enabled
+		case dto.Comment.String():
+			user.CommentNotifications = enabled
+		}
+	}
+}
+
+// applyLegacyNotifications updates the matrix after the legacy booleans were
+// set: turning a type off turns off all of its channels, turning it on
+// restores the default channels unless some are on already.
+func applyLegacyNotifications(user *model.User, matrix []model.NotificationPreference) {
+	for i := range matrix {
+		notificationType, err := dto.ParseNotificationType(matrix[i].NotificationType)
+		if err != nil {
+			continue
+		}
+
+		preference := &matrix[i]
+		if !legacyNotifications(user, notificationType) {
+			preference.InApp, preference.Email, preference.Push = false, false, false
+		} else if !preference.InApp && !preference.Email && !preference.Push {
+			preference.InApp, preference.Push = true, true
+		}
+	}
+}
+
+// inQuietHours reports whether now falls into the schedule's quiet hours,
+// which may wrap around midnight.
+func inQuietHours(schedule *model.NotificationSchedule, now time.Time) bool {
+	if schedule == nil || schedule.QuietHoursStart == "" || schedule.QuietHoursEnd == "" {
+		return false
+	}
+
+	start, err := time.Parse(quietHoursLayout, schedule.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(quietHoursLayout, schedule.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute <= endMinute {
+		return startMinute <= minute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
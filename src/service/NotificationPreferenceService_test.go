@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"user-ms/src/dto"
+	"user-ms/src/logging"
+	"user-ms/src/model"
+	"user-ms/src/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotificationPreferenceServiceTestsSuite struct {
+	suite.Suite
+	userRepositoryMock       *repository.UserRepositoryMock
+	preferenceRepositoryMock *repository.NotificationPreferenceRepositoryMock
+	service                  INotificationPreferenceService
+}
+
+func TestNotificationPreferenceServiceTestsSuite(t *testing.T) {
+	suite.Run(t, new(NotificationPreferenceServiceTestsSuite))
+}
+
+func (suite *NotificationPreferenceServiceTestsSuite) SetupSuite() {
+	suite.userRepositoryMock = new(repository.UserRepositoryMock)
+	suite.preferenceRepositoryMock = new(repository.NotificationPreferenceRepositoryMock)
+	transactions := &repository.TransactionManagerMock{Repositories: &repository.Repositories{
+		Users:                   suite.userRepositoryMock,
+		NotificationPreferences: suite.preferenceRepositoryMock,
+	}}
+	suite.service = NewNotificationPreferenceService(suite.userRepositoryMock, transactions, logging.Logger())
+}
+
+func (suite *NotificationPreferenceServiceTestsSuite) TestGetPreferences_DefaultsFromLegacySettings() {
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|legacy").Return(&model.User{ID: 1, FollowNotifications: true}, nil).Once()
+	suite.preferenceRepositoryMock.On("GetPreferences", 1).Return([]model.NotificationPreference{}, nil).Once()
+	suite.preferenceRepositoryMock.On("GetSchedule", 1).Return(nil, nil).Once()
+
+	preferences, err := suite.service.GetPreferences("auth0|legacy")
+
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), preferences.Preferences, len(dto.NotificationTypes))
+	assert.Equal(suite.T(), dto.NotificationPreferenceDTO{NotificationType: "message", Digest: "instant"}, preferences.Preferences[0])
+	assert.Equal(suite.T(), dto.NotificationPreferenceDTO{NotificationType: "follow", InApp: true, Push: true, Digest: "instant"}, preferences.Preferences[1])
+}
+
+func (suite *NotificationPreferenceServiceTestsSuite) TestUpdatePreferences_SyncsLegacySettings() {
+	user := &model.User{ID: 2, MessageNotifications: true, FollowNotifications: true}
+	suite.userRepositoryMock.On("GetByAuth0ID", "auth0|matrix").Return(user, nil).Once()
+	suite.preferenceRepositoryMock.On("GetPreferences", 2).Return([]model.NotificationPreference{}, nil).Once()
+	suite.preferenceRepositoryMock.On("SavePreferences", 2, mock.MatchedBy(func(matrix []model.NotificationPreference) bool {
+		return len(matrix) == len(dto.NotificationTypes) && matrix[0].NotificationType == "message" && !matrix[0].InApp && matrix[2].Email
+	})).Return(nil).Once()
+	suite.preferenceRepositoryMock.On("SaveSchedule", mock.MatchedBy(func(schedule *model.NotificationSchedule) bool {
+		return schedule.UserId == 2 && schedule.Timezone == "Europe/Belgrade"
+	})).Return(nil).Once()
+	suite.userRepositoryMock.On("Update", user).Return(&dto.UserResponseDTO{}, nil).Once()
+
+	update := &dto.NotificationPreferencesDTO{
+		Preferences: []dto.NotificationPreferenceDTO{
+			{NotificationType: "Message"},
+			{NotificationType: "like", Email: true, Digest: "weekly"},
+		},
+		QuietHoursStart: "22:00",
+		QuietHoursEnd:   "07:00",
+		Timezone:        "Europe/Belgrade",
+	}
+	preferences, err := suite.service.UpdatePreferences("auth0|matrix", update)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "22:00", preferences.QuietHoursStart)
+	assert.False(suite.T(), user.MessageNotifications)
+	assert.True(suite.T(), user.FollowNotifications)
+	assert.True(suite.T(), user.LikeNotifications)
+}
+
+func (suite *NotificationPreferenceServiceTestsSuite) TestUpdatePreferences_Invalid() {
+	invalid := []*dto.NotificationPreferencesDTO{
+		{Preferences: []dto.NotificationPreferenceDTO{{NotificationType: "poke"}}},
+		{Preferences: []dto.NotificationPreferenceDTO{{NotificationType: "like", Digest: "hourly"}}},
+		{QuietHoursStart: "22:00"},
+		{QuietHoursStart: "10pm", QuietHoursEnd: "7am"},
+		{Timezone: "Mars/Olympus_Mons"},
+	}
+
+	for _, preferences := range invalid {
+		_, err := suite.service.UpdatePreferences("auth0|invalid", preferences)
+		assert.NotNil(suite.T(), err)
+	}
+}
+
+func (suite *NotificationPreferenceServiceTestsSuite) TestApplyLegacyNotifications() {
+	user := &model.User{FollowNotifications: true}
+	matrix := []model.NotificationPreference{
+		{NotificationType: "follow"},
+		{NotificationType: "like", Email: true},
+		{NotificationType: "connection", Email: true},
+	}
+
+	applyLegacyNotifications(user, matrix)
+
+	assert.True(suite.T(), matrix[0].InApp && matrix[0].Push)
+	assert.False(suite.T(), matrix[1].Email)
+	assert.Equal(suite.T(), model.NotificationPreference{NotificationType: "connection", Email: true}, matrix[2])
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"user-ms/src/dto"
+	"user-ms/src/model"
+)
+
+// DefaultLocale is used for users without a preferred language and for
+// templates missing a translation.
+const DefaultLocale = "en"
+
+var SupportedLocales = []string{"en", "sr"}
+
+type NotificationTemplate string
+
+const (
+	FollowRequestedTemplate       NotificationTemplate = "follow.requested"
+	FollowStartedTemplate         NotificationTemplate = "follow.started"
+	FollowRequestExpiredTemplate  NotificationTemplate = "follow.request_expired"
+	FollowRequestAcceptedTemplate NotificationTemplate = "follow_request.accepted"
+	FollowRequestRejectedTemplate NotificationTemplate = "follow_request.rejected"
+	ConnectionInvitedTemplate     NotificationTemplate = "connection.invited"
+	ConnectionAcceptedTemplate    NotificationTemplate = "connection.accepted"
+)
+
+type notificationTemplate struct {
+	notificationType dto.NotificationType
+	messages         map[string]string
+}
+
+// notificationTemplates is the catalog of notification messages, keyed by
+// template and locale. Messages are text/template strings over the
+// notification params; "actor" is always set when there is an actor.
+var notificationTemplates = map[NotificationTemplate]notificationTemplate{
+	FollowRequestedTemplate: {dto.Follow, map[string]string{
+		"en": "{{.actor}} requested to follow you.",
+		"sr": "{{.actor}} želi da vas prati.",
+	}},
+	FollowStartedTemplate: {dto.Follow, map[string]string{
+		"en": "{{.actor}} started following you.",
+		"sr": "{{.actor}} vas sada prati.",
+	}},
+	FollowRequestExpiredTemplate: {dto.Follow, map[string]string{
+		"en": "Your follow request to {{.target}} has expired.",
+		"sr": "Vaš zahtev za praćenje korisnika {{.target}} je istekao.",
+	}},
+	FollowRequestAcceptedTemplate: {dto.FollowRequestOutcome, map[string]string{
+		"en": "{{.actor}} accepted your follow request.",
+		"sr": "Vaš zahtev za praćenje korisnika {{.actor}} je prihvaćen.",
+	}},
+	FollowRequestRejectedTemplate: {dto.FollowRequestOutcome, map[string]string{
+		"en": "{{.actor}} declined your follow request.",
+		"sr": "Vaš zahtev za praćenje korisnika {{.actor}} je odbijen.",
+	}},
+	ConnectionInvitedTemplate: {dto.Connection, map[string]string{
+		"en": "{{.actor}} wants to connect with you.",
+		"sr": "{{.actor}} želi da se poveže sa vama.",
+	}},
+	ConnectionAcceptedTemplate: {dto.Connection, map[string]string{
+		"en": "{{.actor}} accepted your invitation to connect.",
+		"sr": "{{.actor}} i vi ste sada povezani.",
+	}},
+}
+
+var parsedTemplates = map[string]*template.Template{}
+
+func init() {
+	for name, catalogEntry := range notificationTemplates {
+		for locale, message := range catalogEntry.messages {
+			key := string(name) + "." + locale
+			parsedTemplates[key] = template.Must(template.New(key).Option("missingkey=error").Parse(message))
+		}
+	}
+}
+
+// NormalizeLocale maps a language tag such as "sr-Latn-RS" or "EN" to a
+// supported locale.
+func NormalizeLocale(language string) (string, bool) {
+	base := strings.ToLower(strings.SplitN(strings.ReplaceAll(language, "_", "-"), "-", 2)[0])
+	for _, locale := range SupportedLocales {
+		if locale == base {
+			return locale, true
+		}
+	}
+	return DefaultLocale, false
+}
+
+// RenderNotification renders a template in locale, falling back to the
+// default locale when there is no translation.
+func RenderNotification(name NotificationTemplate, locale string, params map[string]string) (string, error) {
+	locale, _ = NormalizeLocale(locale)
+	parsed, ok := parsedTemplates[string(name)+"."+locale]
+	if !ok {
+		parsed, ok = parsedTemplates[string(name)+"."+DefaultLocale]
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown notification template %s", name)
+	}
+
+	var message bytes.Buffer
+	if err := parsed.Execute(&message, params); err != nil {
+		return "", err
+	}
+	return message.String(), nil
+}
+
+// NewNotification builds a notification for recipient rendered in their
+// preferred language. actor may be nil for notifications nobody caused.
+func NewNotification(recipient *model.User, actor *model.User, name NotificationTemplate, params map[string]string) (*dto.NotificationDTO, error) {
+	catalogEntry, ok := notificationTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown notification template %s", name)
+	}
+
+	all := map[string]string{}
+	for key, value := range params {
+		all[key] = value
+	}
+
+	notificationType := catalogEntry.notificationType
+	notification := dto.NotificationDTO{
+		UserAuth0ID:      recipient.Auth0ID,
+		NotificationType: &notificationType,
+		Template:         string(name),
+		Params:           all,
+	}
+	if actor != nil {
+		notification.ActorId = actor.ID
+		notification.ActorUsername = actor.Username
+		all["actor"] = actor.Username
+	}
+
+	notification.Locale, _ = NormalizeLocale(recipient.PreferredLanguage)
+	message, err := RenderNotification(name, notification.Locale, all)
+	if err != nil {
+		return nil, err
+	}
+	notification.Message = message
+	return &notification, nil
+}
+
+// NotificationTemplates returns the catalog, sorted by template.
+func NotificationTemplates() []dto.NotificationTemplateDTO {
+	templates := make([]dto.NotificationTemplateDTO, 0, len(notificationTemplates))
+	for name, catalogEntry := range notificationTemplates {
+		messages := map[string]string{}
+		for locale, message := range catalogEntry.messages {
+			messages[locale] = message
+		}
+		templates = append(templates, dto.NotificationTemplateDTO{Template: string(name), NotificationType: catalogEntry.notificationType.String(), Messages: messages})
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Template < templates[j].Template
+	})
+	return templates
+}
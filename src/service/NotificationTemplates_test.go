@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+	"user-ms/src/dto"
+	"user-ms/src/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotificationTemplatesTestsSuite struct {
+	suite.Suite
+}
+
+func TestNotificationTemplatesTestsSuite(t *testing.T) {
+	suite.Run(t, new(NotificationTemplatesTestsSuite))
+}
+
+func (suite *NotificationTemplatesTestsSuite) TestNewNotification_RecipientLocale() {
+	actor := &model.User{ID: 7, Username: "marko"}
+	recipient := &model.User{ID: 8, Auth0ID: "auth0|8", PreferredLanguage: "sr"}
+
+	notification, err := NewNotification(recipient, actor, FollowStartedTemplate, nil)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "marko vas sada prati.", notification.Message)
+	assert.Equal(suite.T(), "sr", notification.Locale)
+	assert.Equal(suite.T(), "follow.started", notification.Template)
+	assert.Equal(suite.T(), dto.Follow, *notification.NotificationType)
+	assert.Equal(suite.T(), 7, notification.ActorId)
+	assert.Equal(suite.T(), "marko", notification.Params["actor"])
+	assert.Equal(suite.T(), "auth0|8", notification.UserAuth0ID)
+}
+
+func (suite *NotificationTemplatesTestsSuite) TestNewNotification_DefaultLocale() {
+	recipient := &model.User{ID: 9, PreferredLanguage: "de"}
+
+	notification, err := NewNotification(recipient, nil, FollowRequestExpiredTemplate, map[string]string{"target": "ana"})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "Your follow request to ana has expired.", notification.Message)
+	assert.Equal(suite.T(), "en", notification.Locale)
+	assert.Equal(suite.T(), 0, notification.ActorId)
+}
+
+func (suite *NotificationTemplatesTestsSuite) TestRenderNotification_MissingParam() {
+	_, err := RenderNotification(FollowRequestExpiredTemplate, "en", map[string]string{})
+
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *NotificationTemplatesTestsSuite) TestNormalizeLocale() {
+	locale, ok := NormalizeLocale("sr-Latn-RS")
+	assert.Equal(suite.T(), "sr", locale)
+	assert.True(suite.T(), ok)
+
+	locale, ok = NormalizeLocale("")
+	assert.Equal(suite.T(), DefaultLocale, locale)
+	assert.False(suite.T(), ok)
+}
+
+func (suite *NotificationTemplatesTestsSuite) TestCatalogIsComplete() {
+	for _, template := range NotificationTemplates() {
+		for _, locale := range SupportedLocales {
+			assert.NotEmpty(suite.T(), template.Messages[locale], "%s has no %s message", template.Template, locale)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-ms/src/dto"
+	"user-ms/src/metrics"
+	"user-ms/src/model"
+	"user-ms/src/rabbitmq"
+	"user-ms/src/repository"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+)
+
+// enqueueNotification stores notification in the outbox, addressed to the
+// channels the recipient's preferences allow, unless there are none. It must
+// be given the outbox repository of the transaction that makes the change it
+// announces.
+func enqueueNotification(ctx context.Context, outbox repository.IOutboxRepository, policy INotificationPolicy, recipient *model.User, notification *dto.NotificationDTO) error {
+	delivery := policy.Delivery(recipient, *notification.NotificationType, time.Now())
+	if len(delivery.Channels) == 0 {
+		return nil
+	}
+	notification.Channels = delivery.Channels
+	notification.Digest = delivery.Digest
+
+	msg, err := rabbitmq.NewNotificationMessage(ctx, notification)
+	if err != nil {
+		return err
+	}
+
+	return enqueue(outbox, msg)
+}
+
+// enqueueEvent stores a domain event in the outbox, see enqueueNotification.
+func enqueueEvent(ctx context.Context, outbox repository.IOutboxRepository, eventType rabbitmq.EventType, data interface{}) error {
+	msg, err := rabbitmq.NewDomainEventMessage(ctx, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	return enqueue(outbox, msg)
+}
+
+func enqueue(outbox repository.IOutboxRepository, msg rabbitmq.Message) error {
+	row, err := msg.ToOutbox()
+	if err != nil {
+		return err
+	}
+
+	return outbox.Add(row)
+}
+
+// OutboxRelay publishes messages from the outbox table to RabbitMQ. A message
+// is marked delivered only after the broker confirmed it, so delivery is
+// at-least-once. Rows are claimed with SKIP LOCKED, which lets every replica
+// run a relay. The claimed rows stay locked while their confirms are awaited,
+// so no new message of a batch is published after BatchTimeout; the rest are
+// left for the next batch.
+type OutboxRelay struct {
+	Transactions repository.ITransactionManager
+	Publisher    rabbitmq.Publisher
+	BatchSize    int
+	Interval     time.Duration
+	Retention    time.Duration
+	MaxAttempts  int
+	BatchTimeout time.Duration
+	Metrics      *metrics.DomainMetrics
+	Logger       *logrus.Entry
+}
+
+func NewOutboxRelay(transactions repository.ITransactionManager, publisher rabbitmq.Publisher, batchSize int, interval time.Duration, retention time.Duration, maxAttempts int, batchTimeout time.Duration, domainMetrics *metrics.DomainMetrics, logger *logrus.Entry) *OutboxRelay {
+	return &OutboxRelay{
+		transactions,
+		publisher,
+		batchSize,
+		interval,
+		retention,
+		maxAttempts,
+		batchTimeout,
+		domainMetrics,
+		logger,
+	}
+}
+
+// Start relays messages every Interval until stop is closed. A full batch is
+// followed immediately by the next one.
+func (relay *OutboxRelay) Start(stop <-chan struct{}) {
+	ticker := time.NewTicker(relay.Interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := relay.RelayPending(); err == nil && sent == relay.BatchSize {
+			continue
+		}
+		relay.deleteDelivered()
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// RelayPending publishes one batch of pending messages and returns how many of
+// them were delivered. A message that fails is retried in later batches
+// until it has been tried MaxAttempts times, or right away given up on when
+// it can't be decoded, and the rest of the batch is relayed meanwhile. When
+// the broker can't be reached at all the batch stops and only the error is
+// recorded, so an outage doesn't count against the messages.
+func (relay *OutboxRelay) RelayPending() (int, error) {
+	sent := 0
+	err := relay.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		pending, err := repositories.Outbox.ClaimPending(relay.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		deadline := time.Now().Add(relay.BatchTimeout)
+		for i := range pending {
+			if relay.BatchTimeout > 0 && time.Now().After(deadline) {
+				relay.Logger.Warn(fmt.Sprintf("Outbox batch took longer than %s, leaving %d messages for the next one", relay.BatchTimeout, len(pending)-i))
+				break
+			}
+
+			row := &pending[i]
+			msg, err := rabbitmq.MessageFromOutbox(row)
+			if err != nil {
+				relay.Logger.Error(fmt.Sprintf("Giving up on outbox message %s that can't be decoded: %s", row.MessageId, err.Error()))
+				relay.recordNotification(row, metrics.PublishFailed)
+				if err := repositories.Outbox.MarkDead(row.ID, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+			err = relay.publish(msg)
+
+			// The broker accepted an unroutable message and dropped it, so
+			// retrying won't help; it's kept as delivered with the error.
+			lastError := ""
+			if errors.Is(err, rabbitmq.ErrUnroutable) {
+				lastError = err.Error()
+				relay.recordNotification(row, metrics.Unroutable)
+			} else if err != nil {
+				relay.Logger.Error(fmt.Sprintf("Can't relay outbox message %s: %s", row.MessageId, err.Error()))
+				relay.recordNotification(row, metrics.PublishFailed)
+				if publisherUnavailable(err) {
+					return repositories.Outbox.RecordError(row.ID, err.Error())
+				}
+				if err := relay.markFailed(repositories.Outbox, row, err); err != nil {
+					return err
+				}
+				continue
+			} else {
+				relay.recordNotification(row, metrics.Published)
+			}
+
+			if err := repositories.Outbox.MarkDelivered(row.ID, lastError); err != nil {
+				return err
+			}
+			sent++
+		}
+		return nil
+	})
+	if err != nil {
+		relay.Logger.Error(err.Error())
+		return 0, err
+	}
+
+	if sent > 0 {
+		relay.Logger.Info(fmt.Sprintf("Relayed %d outbox messages", sent))
+	}
+	return sent, nil
+}
+
+// publish sends msg in a span that follows from the one that enqueued it, so
+// the trace continues across the outbox.
+func (relay *OutboxRelay) publish(msg rabbitmq.Message) error {
+	options := []opentracing.StartSpanOption{ext.SpanKindProducer}
+	if parent, err := rabbitmq.ExtractTrace(msg.Publishing.Headers); err == nil {
+		options = append(options, opentracing.FollowsFrom(parent))
+	}
+	span := opentracing.StartSpan("AMQP publish "+msg.RoutingKey, options...)
+	defer span.Finish()
+	span.SetTag("message_bus.destination", msg.Exchange)
+	span.SetTag("message_id", msg.Publishing.MessageId)
+
+	if msg.Publishing.Headers == nil {
+		msg.Publishing.Headers = amqp.Table{}
+	}
+	rabbitmq.InjectTrace(opentracing.ContextWithSpan(context.Background(), span), msg.Publishing.Headers)
+
+	err := relay.Publisher.PublishConfirmed(msg)
+	if err != nil {
+		ext.Error.Set(span, true)
+	}
+	return err
+}
+
+// markFailed records a failed publish, giving up on the message once it
+// has been tried MaxAttempts times.
+func (relay *OutboxRelay) markFailed(outbox repository.IOutboxRepository, row *model.OutboxMessage, err error) error {
+	if relay.MaxAttempts > 0 && row.Attempts+1 >= relay.MaxAttempts {
+		relay.Logger.Error(fmt.Sprintf("Giving up on outbox message %s after %d attempts", row.MessageId, row.Attempts+1))
+		return outbox.MarkDead(row.ID, err.Error())
+	}
+	return outbox.MarkFailed(row.ID, err.Error())
+}
+
+// publisherUnavailable reports whether err means no message could be
+// published right now, as opposed to this one being rejected.
+func publisherUnavailable(err error) bool {
+	return errors.Is(err, rabbitmq.ErrNotConnected) || errors.Is(err, rabbitmq.ErrClosed) ||
+		errors.Is(err, rabbitmq.ErrNoPublisher) || errors.Is(err, rabbitmq.ErrBufferFull)
+}
+
+func (relay *OutboxRelay) recordNotification(row *model.OutboxMessage, outcome string) {
+	if row.Exchange == rabbitmq.NotificationExchange {
+		relay.Metrics.NotificationPublished(row.Type, outcome)
+	}
+}
+
+func (relay *OutboxRelay) deleteDelivered() {
+	if relay.Retention <= 0 {
+		return
+	}
+
+	// Processed consumer messages are kept as long, a redelivery that late
+	// isn't expected.
+	cutoff := time.Now().Add(-relay.Retention)
+	err := relay.Transactions.Transaction(func(repositories *repository.Repositories) error {
+		if err := repositories.Outbox.DeleteDeliveredBefore(cutoff); err != nil {
+			return err
+		}
+		return repositories.ProcessedMessages.DeleteProcessedBefore(cutoff)
+	})
+	if err != nil {
+		relay.Logger.Error(err.Error())
+	}
+}
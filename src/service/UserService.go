@@ -1,104 +1,185 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"user-ms/src/auth0"
 	"user-ms/src/dto"
+	"user-ms/src/logging"
 	"user-ms/src/mapper"
+	"user-ms/src/metrics"
+	"user-ms/src/model"
+	"user-ms/src/rabbitmq"
 	"user-ms/src/repository"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	UserRepo    repository.IUserRepository
-	Auth0Client auth0.Auth0Client
-	Logger      *logrus.Entry
+	UserRepo     repository.IUserRepository
+	Auth0Client  auth0.Auth0Client
+	Transactions repository.ITransactionManager
+	Metrics      *metrics.DomainMetrics
+	Logger       *logrus.Entry
 }
 
 type IUserService interface {
-	Register(*dto.RegistrationRequestDTO) (int, error)
-	GetByEmail(string) (*dto.UserResponseDTO, error)
-	GetByUsername(string) []dto.UserResponseDTO
-	Update(*dto.UserUpdateDTO) (*dto.UserResponseDTO, error)
-	BlockUser(int, string) error
-	UnblockUser(int, string) error
-	GetBlockedUsers(string) []dto.BlockedUserDTO
-	SetNotifications(*dto.NotificationsUpdateDTO, string) error
-	GetNotifications(string) *dto.NotificationsUpdateDTO
-}
-
-func NewUserService(userRepository repository.IUserRepository, auth0Client auth0.Auth0Client, logger *logrus.Entry) IUserService {
+	Register(context.Context, *dto.RegistrationRequestDTO) (int, error)
+	GetByEmail(context.Context, string) (*dto.UserResponseDTO, error)
+	GetByUsername(context.Context, string) []dto.UserResponseDTO
+	Update(context.Context, *dto.UserUpdateDTO) (*dto.UserResponseDTO, error)
+	BlockUser(context.Context, int, string) error
+	UnblockUser(context.Context, int, string) error
+	GetBlockedUsers(context.Context, string) []dto.BlockedUserDTO
+	SetNotifications(context.Context, *dto.NotificationsUpdateDTO, string) error
+	GetNotifications(context.Context, string) *dto.NotificationsUpdateDTO
+}
+
+func NewUserService(userRepository repository.IUserRepository, auth0Client auth0.Auth0Client, transactions repository.ITransactionManager, domainMetrics *metrics.DomainMetrics, logger *logrus.Entry) IUserService {
 	return &UserService{
 		userRepository,
 		auth0Client,
+		transactions,
+		domainMetrics,
 		logger,
 	}
 }
 
-func (service *UserService) Register(userToRegister *dto.RegistrationRequestDTO) (int, error) {
-	if strings.TrimSpace(userToRegister.Password) == "" || len(userToRegister.Password) < 8 {
-		service.Logger.Debug("Password must be at least 8 characters long!")
-		return -1, errors.New("Password must be at least 8 characters long!")
+// ValidatePassword checks a plain text password against the password policy.
+func ValidatePassword(password string) error {
+	if strings.TrimSpace(password) == "" || len(password) < 8 {
+		return errors.New("Password must be at least 8 characters long!")
+	}
+	if match, _ := regexp.MatchString(".*\\d.*", password); !match {
+		return errors.New("Password must contain at least one number!")
 	}
-	if match, _ := regexp.MatchString(".*\\d.*", userToRegister.Password); !match {
-		service.Logger.Debug("Password must contain at least one number!")
-		return -1, errors.New("Password must contain at least one number!")
+	return nil
+}
+
+func (service *UserService) Register(ctx context.Context, userToRegister *dto.RegistrationRequestDTO) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.Register")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	if err := ValidatePassword(userToRegister.Password); err != nil {
+		logger.Debug(err.Error())
+		service.Metrics.Registration("invalid_password")
+		return -1, err
 	}
 
 	user := mapper.RegistrationRequestDTOToUser(userToRegister)
+	user.PreferredLanguage, _ = NormalizeLocale(user.PreferredLanguage)
 
 	err := user.Validate()
 	if err != nil {
-		service.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
+		service.Metrics.Registration("invalid_user")
 		return -1, err
 	}
 
 	user.Password, err = HashPassword(user.Password)
 	if err != nil {
-		service.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
+		service.Metrics.Registration("internal_error")
 		return -1, err
 	}
 
-	service.Logger.Info(fmt.Sprintf("Adding user to database with username %s", user.Username))
-	addedUserID, err := service.UserRepo.AddUser(user)
+	logger.Info(fmt.Sprintf("Adding user to database with username %s", user.Username))
+	addedUserID, err := service.UserRepo.WithContext(ctx).AddUser(user)
 	if err != nil {
-		service.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
+		service.Metrics.Registration("database_error")
 		return -1, err
 	}
 
-	service.Logger.Info(fmt.Sprintf("Calling Auth0 to add user with username %s", user.Username))
-	if auth0ID, err := service.Auth0Client.Register(userToRegister.Email, userToRegister.Password); err != nil {
-		service.Logger.Debug(err.Error())
-		service.Logger.Info(fmt.Sprintf("Deleting user with username %s", user.Username))
-		if err = service.UserRepo.DeleteUser(addedUserID); err != nil {
-			service.Logger.Debug(err.Error())
+	logger.Info(fmt.Sprintf("Calling Auth0 to add user with username %s", user.Username))
+	if auth0ID, err := service.Auth0Client.Register(ctx, userToRegister.Email, userToRegister.Password); err != nil {
+		logger.Debug(err.Error())
+		service.Metrics.Registration("auth0_error")
+		logger.Info(fmt.Sprintf("Deleting user with username %s", user.Username))
+		if err = service.UserRepo.WithContext(ctx).DeleteUser(addedUserID); err != nil {
+			logger.Debug(err.Error())
 			return -1, err
 		}
 		return -1, err
 	} else {
-		service.Logger.Info(fmt.Sprintf("Updating user with username %s", user.Username))
+		logger.Info(fmt.Sprintf("Updating user with username %s", user.Username))
 		user.Auth0ID = auth0ID
-		service.UserRepo.Update(user)
+		err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+			if _, err := repositories.Users.Update(user); err != nil {
+				return err
+			}
+			return enqueueEvent(ctx, repositories.Outbox, rabbitmq.UserRegistered, mapper.UserToEventDTO(user))
+		})
+		if err != nil {
+			logger.Debug(err.Error())
+			service.Metrics.Registration("database_error")
+			return -1, err
+		}
 	}
 
-	service.Logger.Info(fmt.Sprintf("Succesfully registered user with id %d", addedUserID))
+	service.Metrics.Registration(metrics.RegistrationSucceeded)
+	logger.Info(fmt.Sprintf("Succesfully registered user with id %d", addedUserID))
 	return addedUserID, nil
 }
 
+// changedUserFields lists the profile fields an update changed, named as in
+// the user JSON representation.
+func changedUserFields(before *model.User, after *model.User) []string {
+	fields := []struct {
+		name    string
+		changed bool
+	}{
+		{"first_name", before.FirstName != after.FirstName},
+		{"last_name", before.LastName != after.LastName},
+		{"email", before.Email != after.Email},
+		{"phone_number", before.PhoneNumber != after.PhoneNumber},
+		{"gender", !sameGender(before.Gender, after.Gender)},
+		{"user_name", before.Username != after.Username},
+		{"date_of_birth", before.DateOfBirth != after.DateOfBirth},
+		{"biography", before.Biography != after.Biography},
+		{"education", before.Education != after.Education},
+		{"work_experience", before.WorkExperience != after.WorkExperience},
+		{"skills", before.Skills != after.Skills},
+		{"interests", before.Interests != after.Interests},
+		{"public", before.Public != after.Public},
+		{"preferred_language", before.PreferredLanguage != after.PreferredLanguage},
+	}
+
+	changed := []string{}
+	for _, field := range fields {
+		if field.changed {
+			changed = append(changed, field.name)
+		}
+	}
+	return changed
+}
+
+func sameGender(a *model.Gender, b *model.Gender) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
 }
 
-func (service *UserService) GetByUsername(username string) []dto.UserResponseDTO {
-	service.Logger.Info(fmt.Sprintf("Getting users by username %s", username))
+func (service *UserService) GetByUsername(ctx context.Context, username string) []dto.UserResponseDTO {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.GetByUsername")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Getting users by username %s", username))
 
-	users := service.UserRepo.GetByUsername(username)
+	users := service.UserRepo.WithContext(ctx).GetByUsername(username)
 
 	res := make([]dto.UserResponseDTO, len(users))
 
@@ -109,19 +190,31 @@ func (service *UserService) GetByUsername(username string) []dto.UserResponseDTO
 	return res
 }
 
-func (service *UserService) GetByEmail(email string) (*dto.UserResponseDTO, error) {
-	service.Logger.Info("Getting user by email")
-	return service.UserRepo.GetByEmail(email)
+func (service *UserService) GetByEmail(ctx context.Context, email string) (*dto.UserResponseDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.GetByEmail")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info("Getting user by email")
+	return service.UserRepo.WithContext(ctx).GetByEmail(email)
 }
 
-func (service *UserService) GetAll() ([]*dto.UserResponseDTO, error) {
-	service.Logger.Info("Getting all users")
-	return service.UserRepo.GetAll()
+func (service *UserService) GetAll(ctx context.Context) ([]*dto.UserResponseDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.GetAll")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info("Getting all users")
+	return service.UserRepo.WithContext(ctx).GetAll()
 }
 
-func (service *UserService) GetByID(id int) (*dto.UserResponseDTO, error) {
-	service.Logger.Info(fmt.Sprintf("Getting user by id %d", id))
-	user, err := service.UserRepo.GetByID(id)
+func (service *UserService) GetByID(ctx context.Context, id int) (*dto.UserResponseDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.GetByID")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Getting user by id %d", id))
+	user, err := service.UserRepo.WithContext(ctx).GetByID(id)
 	if err == nil {
 		userResponse := *mapper.UserToDTO(user)
 		return &userResponse, err
@@ -129,77 +222,148 @@ func (service *UserService) GetByID(id int) (*dto.UserResponseDTO, error) {
 	return nil, err
 }
 
-func (service *UserService) Update(userToUpdate *dto.UserUpdateDTO) (*dto.UserResponseDTO, error) {
-	service.Logger.Info(fmt.Sprintf("Updating user with id %d", userToUpdate.ID))
-	userEntity, errr := service.UserRepo.GetByID(userToUpdate.ID)
+func (service *UserService) Update(ctx context.Context, userToUpdate *dto.UserUpdateDTO) (*dto.UserResponseDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.Update")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Updating user with id %d", userToUpdate.ID))
+	userEntity, errr := service.UserRepo.WithContext(ctx).GetByID(userToUpdate.ID)
 	if errr != nil {
-		service.Logger.Debug(errr.Error())
+		logger.Debug(errr.Error())
 		return nil, errr
 	}
 
 	user := mapper.UserUpdateDTOToUser(userToUpdate)
 	user.Password = userEntity.Password
 	user.Auth0ID = userEntity.Auth0ID
+	// Save writes every column, so the fields the profile form doesn't carry
+	// have to be copied over or they would be reset.
+	user.Active = userEntity.Active
+	user.MessageNotifications = userEntity.MessageNotifications
+	user.FollowNotifications = userEntity.FollowNotifications
+	user.LikeNotifications = userEntity.LikeNotifications
+	user.CommentNotifications = userEntity.CommentNotifications
+	user.Flagged = userEntity.Flagged
+	user.ReportCount = userEntity.ReportCount
+	user.PasswordChangedAt = userEntity.PasswordChangedAt
+	user.CreatedAt = userEntity.CreatedAt
+	// Clients that don't know about the language keep the current one.
+	if user.PreferredLanguage == "" {
+		user.PreferredLanguage = userEntity.PreferredLanguage
+	} else {
+		user.PreferredLanguage, _ = NormalizeLocale(user.PreferredLanguage)
+	}
 
 	err := user.Validate()
 	if err != nil {
-		service.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		return nil, err
 	}
 
-	userDTO, err := service.UserRepo.Update(user)
+	var userDTO *dto.UserResponseDTO
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		var err error
+		userDTO, err = repositories.Users.Update(user)
+		if err != nil {
+			return err
+		}
+
+		changedFields := changedUserFields(userEntity, user)
+		if len(changedFields) == 0 {
+			return nil
+		}
+		event := dto.UserUpdatedEventDTO{User: *mapper.UserToEventDTO(user), ChangedFields: changedFields}
+		return enqueueEvent(ctx, repositories.Outbox, rabbitmq.UserUpdated, &event)
+	})
 	if err != nil {
-		service.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		return nil, err
 	}
 
-	service.Logger.Info(fmt.Sprintf("Updating user with id %d in Auth0", userToUpdate.ID))
-	if err := service.Auth0Client.Update(user.Email, user.Auth0ID); err != nil {
-		service.Logger.Debug(err.Error())
+	logger.Info(fmt.Sprintf("Updating user with id %d in Auth0", userToUpdate.ID))
+	if err := service.Auth0Client.Update(ctx, user.Email, user.Auth0ID); err != nil {
+		logger.Debug(err.Error())
 		return nil, err
 	}
 
-	service.Logger.Info(fmt.Sprintf("Succesfully updated user with %d", userToUpdate.ID))
+	logger.Info(fmt.Sprintf("Succesfully updated user with %d", userToUpdate.ID))
 	return userDTO, nil
 }
 
-func (service *UserService) BlockUser(blockingID int, userAuth0ID string) error {
-	service.Logger.Info(fmt.Sprintf("Blocking user with id %d", blockingID))
-	blockedUserEntity, err := service.UserRepo.GetByID(blockingID)
+func (service *UserService) BlockUser(ctx context.Context, blockingID int, userAuth0ID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.BlockUser")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Blocking user with id %d", blockingID))
+	blockedUserEntity, err := service.UserRepo.WithContext(ctx).GetByID(blockingID)
 	if err != nil {
 		return err
 	}
 
-	userEntity, _ := service.UserRepo.GetByAuth0ID(userAuth0ID)
+	userEntity, _ := service.UserRepo.WithContext(ctx).GetByAuth0ID(userAuth0ID)
 
 	userEntity.Blocked = append(userEntity.Blocked, *blockedUserEntity)
 
-	service.UserRepo.Update(userEntity)
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		if _, err := repositories.Users.Update(userEntity); err != nil {
+			return err
+		}
+
+		event := dto.UserBlockedEventDTO{UserId: userEntity.ID, BlockedUserId: blockingID}
+		return enqueueEvent(ctx, repositories.Outbox, rabbitmq.UserBlocked, &event)
+	})
+	if err != nil {
+		logger.Debug(err.Error())
+		return err
+	}
 
-	service.Logger.Info(fmt.Sprintf("Succesfully blocked user with %d", blockingID))
+	service.Metrics.Block(metrics.Blocked)
+	logger.Info(fmt.Sprintf("Succesfully blocked user with %d", blockingID))
 	return nil
 }
 
-func (service *UserService) UnblockUser(blockingID int, userAuth0ID string) error {
-	service.Logger.Info(fmt.Sprintf("Unblocking user with id %d", blockingID))
-	_, err := service.UserRepo.GetByID(blockingID)
+func (service *UserService) UnblockUser(ctx context.Context, blockingID int, userAuth0ID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.UnblockUser")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Unblocking user with id %d", blockingID))
+	_, err := service.UserRepo.WithContext(ctx).GetByID(blockingID)
 	if err != nil {
-		service.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		return err
 	}
 
-	userEntity, _ := service.UserRepo.GetByAuth0ID(userAuth0ID)
+	userEntity, _ := service.UserRepo.WithContext(ctx).GetByAuth0ID(userAuth0ID)
 
-	service.UserRepo.UnblockUser(blockingID, userEntity.ID)
+	err = service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		if err := repositories.Users.UnblockUser(blockingID, userEntity.ID); err != nil {
+			return err
+		}
+
+		event := dto.UserBlockedEventDTO{UserId: userEntity.ID, BlockedUserId: blockingID}
+		return enqueueEvent(ctx, repositories.Outbox, rabbitmq.UserUnblocked, &event)
+	})
+	if err != nil {
+		logger.Debug(err.Error())
+		return err
+	}
 
-	service.Logger.Info(fmt.Sprintf("Succesfully unblocked user with %d", blockingID))
+	service.Metrics.Block(metrics.Unblocked)
+	logger.Info(fmt.Sprintf("Succesfully unblocked user with %d", blockingID))
 	return nil
 }
 
-func (service *UserService) GetBlockedUsers(userAuth0ID string) []dto.BlockedUserDTO {
-	service.Logger.Info(fmt.Sprintf("Getting blocked users for user %s", userAuth0ID))
-	userEntity, _ := service.UserRepo.GetByAuth0ID(userAuth0ID)
-	blockedUsers := service.UserRepo.GetBlockedUsers(userEntity.ID)
+func (service *UserService) GetBlockedUsers(ctx context.Context, userAuth0ID string) []dto.BlockedUserDTO {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.GetBlockedUsers")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Getting blocked users for user %s", userAuth0ID))
+	userEntity, _ := service.UserRepo.WithContext(ctx).GetByAuth0ID(userAuth0ID)
+	blockedUsers := service.UserRepo.WithContext(ctx).GetBlockedUsers(userEntity.ID)
 
 	res := make([]dto.BlockedUserDTO, len(blockedUsers))
 	for i := 0; i < len(blockedUsers); i++ {
@@ -208,35 +372,59 @@ func (service *UserService) GetBlockedUsers(userAuth0ID string) []dto.BlockedUse
 	return res
 }
 
-func (service *UserService) SetNotifications(notificationSettings *dto.NotificationsUpdateDTO, userAuth0ID string) error {
-	service.Logger.Info(fmt.Sprintf("Setting notifications for user with %s", userAuth0ID))
-	userEntity, _ := service.UserRepo.GetByAuth0ID(userAuth0ID)
+func (service *UserService) SetNotifications(ctx context.Context, notificationSettings *dto.NotificationsUpdateDTO, userAuth0ID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.SetNotifications")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Setting notifications for user with %s", userAuth0ID))
+	userEntity, _ := service.UserRepo.WithContext(ctx).GetByAuth0ID(userAuth0ID)
 
 	userEntity.MessageNotifications = notificationSettings.MessageNotifications
 	userEntity.FollowNotifications = notificationSettings.FollowNotifications
 	userEntity.CommentNotifications = notificationSettings.CommentNotifications
 	userEntity.LikeNotifications = notificationSettings.LikeNotifications
 
-	_, err := service.UserRepo.Update(userEntity)
+	err := service.Transactions.WithContext(ctx).Transaction(func(repositories *repository.Repositories) error {
+		if _, err := repositories.Users.Update(userEntity); err != nil {
+			return err
+		}
+
+		stored, err := repositories.NotificationPreferences.GetPreferences(userEntity.ID)
+		if err != nil {
+			return err
+		}
+		matrix := effectivePreferences(userEntity, stored)
+		applyLegacyNotifications(userEntity, matrix)
+		return repositories.NotificationPreferences.SavePreferences(userEntity.ID, matrix)
+	})
 	if err != nil {
-		service.Logger.Debug(err.Error())
+		logger.Debug(err.Error())
 		return err
 	}
 
-	service.Logger.Info(fmt.Sprintf("Succesfully set notification settings for user %s", userAuth0ID))
+	logger.Info(fmt.Sprintf("Succesfully set notification settings for user %s", userAuth0ID))
 	return nil
 }
 
-func (service *UserService) GetNotifications(userAuth0ID string) *dto.NotificationsUpdateDTO {
-	service.Logger.Info(fmt.Sprintf("Getting notification settings for user %s", userAuth0ID))
-	userEntity, _ := service.UserRepo.GetByAuth0ID(userAuth0ID)
+func (service *UserService) GetNotifications(ctx context.Context, userAuth0ID string) *dto.NotificationsUpdateDTO {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.GetNotifications")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info(fmt.Sprintf("Getting notification settings for user %s", userAuth0ID))
+	userEntity, _ := service.UserRepo.WithContext(ctx).GetByAuth0ID(userAuth0ID)
 
 	notificationSettings := mapper.UserToNotificationsDTO(userEntity)
 
 	return notificationSettings
 }
 
-func (service *UserService) GetByParam(param string) ([]*dto.UserResponseDTO, error) {
-	service.Logger.Info("Getting users by param")
-	return service.UserRepo.GetBySearchParam(param)
+func (service *UserService) GetByParam(ctx context.Context, param string) ([]*dto.UserResponseDTO, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserService.GetByParam")
+	defer span.Finish()
+	logger := logging.WithContext(service.Logger, ctx)
+
+	logger.Info("Getting users by param")
+	return service.UserRepo.WithContext(ctx).GetBySearchParam(param)
 }
@@ -1,14 +1,15 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
 	"user-ms/src/auth0"
 	"user-ms/src/dto"
+	"user-ms/src/logging"
 	"user-ms/src/model"
 	"user-ms/src/repository"
-	"user-ms/src/utils"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
@@ -41,6 +42,8 @@ func (suite *UserServiceIntegrationTestSuite) SetupSuite() {
 	db, _ := gorm.Open("postgres", connectionString)
 
 	db.AutoMigrate(model.User{})
+	db.AutoMigrate(model.OutboxMessage{})
+	db.AutoMigrate(model.NotificationPreference{})
 
 	db.Where("1=1").Delete(model.User{})
 
@@ -51,9 +54,10 @@ func (suite *UserServiceIntegrationTestSuite) SetupSuite() {
 	suite.db = db
 
 	suite.service = UserService{
-		UserRepo:    &userRepository,
-		Auth0Client: auth0Client,
-		Logger:      utils.Logger(),
+		UserRepo:     &userRepository,
+		Auth0Client:  auth0Client,
+		Transactions: &repository.TransactionManager{Database: db},
+		Logger:       logging.Logger(),
 	}
 
 	gender := model.Female
@@ -84,7 +88,7 @@ func TestUserServiceIntegrationTestSuite(t *testing.T) {
 func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetByEmail_UserDoesNotExist() {
 	email := "no@test.com"
 
-	user, err := suite.service.GetByEmail(email)
+	user, err := suite.service.GetByEmail(context.Background(), email)
 
 	assert.Nil(suite.T(), user)
 	assert.NotNil(suite.T(), err)
@@ -93,7 +97,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetByEm
 func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetByEmail_UserExists() {
 	email := "test@test.com"
 
-	user, err := suite.service.GetByEmail(email)
+	user, err := suite.service.GetByEmail(context.Background(), email)
 
 	assert.NotNil(suite.T(), user)
 	assert.Equal(suite.T(), email, user.Email)
@@ -103,7 +107,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetByEm
 func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetByID_UserDoesNotExist() {
 	id := 2000000
 
-	user, err := suite.service.GetByID(id)
+	user, err := suite.service.GetByID(context.Background(), id)
 
 	assert.Nil(suite.T(), user)
 	assert.NotNil(suite.T(), err)
@@ -112,7 +116,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetByID
 func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetByID_UserExists() {
 	id := 1
 
-	user, err := suite.service.GetByID(id)
+	user, err := suite.service.GetByID(context.Background(), id)
 
 	assert.NotNil(suite.T(), user)
 	assert.Equal(suite.T(), id, user.ID)
@@ -123,7 +127,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_Registe
 	userDto := dto.RegistrationRequestDTO{}
 	userDto.Password = "pass"
 
-	user, err := suite.service.Register(&userDto)
+	user, err := suite.service.Register(context.Background(), &userDto)
 
 	assert.Equal(suite.T(), -1, user)
 	assert.NotNil(suite.T(), err)
@@ -137,7 +141,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_Registe
 		Password:  "test",
 	}
 
-	user, err := suite.service.Register(&userDto)
+	user, err := suite.service.Register(context.Background(), &userDto)
 
 	assert.Equal(suite.T(), -1, user)
 	assert.NotNil(suite.T(), err)
@@ -156,7 +160,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_Registe
 		Gender:      &gender,
 	}
 
-	user, err := suite.service.Register(&userDto)
+	user, err := suite.service.Register(context.Background(), &userDto)
 
 	assert.Equal(suite.T(), -1, user)
 	assert.NotNil(suite.T(), err)
@@ -166,7 +170,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_Update_
 	userDto := dto.UserUpdateDTO{}
 	userDto.ID = 100
 
-	user, err := suite.service.Update(&userDto)
+	user, err := suite.service.Update(context.Background(), &userDto)
 
 	assert.Nil(suite.T(), user)
 	assert.NotNil(suite.T(), err)
@@ -184,7 +188,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_Update_
 		Username:    "username",
 	}
 
-	user, err := suite.service.Update(&userDto)
+	user, err := suite.service.Update(context.Background(), &userDto)
 
 	assert.NotNil(suite.T(), user)
 	assert.Nil(suite.T(), err)
@@ -193,14 +197,14 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_Update_
 
 func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_BlockUser_UserDoesNotExist() {
 
-	err := suite.service.BlockUser(10000000, "auth0|62cac9f9117230969f05f366")
+	err := suite.service.BlockUser(context.Background(), 10000000, "auth0|62cac9f9117230969f05f366")
 
 	assert.NotNil(suite.T(), err)
 }
 
 func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetBlockedUsers_EmptyList() {
 
-	list := suite.service.GetBlockedUsers("auth0|62cac9f9117230969f05f366")
+	list := suite.service.GetBlockedUsers(context.Background(), "auth0|62cac9f9117230969f05f366")
 
 	assert.NotNil(suite.T(), list)
 	assert.Equal(suite.T(), 0, len(list))
@@ -214,9 +218,9 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_SetNoti
 		CommentNotifications: false,
 	}
 
-	err := suite.service.SetNotifications(&notifications, "auth0|62cac9f9117230969f05f366")
+	err := suite.service.SetNotifications(context.Background(), &notifications, "auth0|62cac9f9117230969f05f366")
 
-	n := suite.service.GetNotifications("auth0|62cac9f9117230969f05f366")
+	n := suite.service.GetNotifications(context.Background(), "auth0|62cac9f9117230969f05f366")
 
 	assert.NotNil(suite.T(), notifications)
 	assert.Equal(suite.T(), true, n.FollowNotifications)
@@ -229,7 +233,7 @@ func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_SetNoti
 
 func (suite *UserServiceIntegrationTestSuite) TestIntegrationUserService_GetNotifications_Pass() {
 
-	notifications := suite.service.GetNotifications("auth0|62cac9f9117230969f05f366")
+	notifications := suite.service.GetNotifications(context.Background(), "auth0|62cac9f9117230969f05f366")
 
 	assert.NotNil(suite.T(), notifications)
 	assert.Equal(suite.T(), false, notifications.FollowNotifications)
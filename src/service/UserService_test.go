@@ -1,16 +1,22 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 	"user-ms/src/auth0"
 	"user-ms/src/dto"
+	"user-ms/src/logging"
 	"user-ms/src/mapper"
+	"user-ms/src/metrics"
 	"user-ms/src/model"
 	"user-ms/src/repository"
-	"user-ms/src/utils"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -18,9 +24,10 @@ import (
 
 type UserServiceUnitTestsSuite struct {
 	suite.Suite
-	userRepositoryMock *repository.UserRepositoryMock
-	auth0ClientMock    *auth0.Auth0ClientMock
-	service            IUserService
+	userRepositoryMock   *repository.UserRepositoryMock
+	auth0ClientMock      *auth0.Auth0ClientMock
+	outboxRepositoryMock *repository.OutboxRepositoryMock
+	service              IUserService
 }
 
 func TestUserServiceUnitTestsSuite(t *testing.T) {
@@ -30,7 +37,12 @@ func TestUserServiceUnitTestsSuite(t *testing.T) {
 func (suite *UserServiceUnitTestsSuite) SetupSuite() {
 	suite.userRepositoryMock = new(repository.UserRepositoryMock)
 	suite.auth0ClientMock = new(auth0.Auth0ClientMock)
-	suite.service = NewUserService(suite.userRepositoryMock, suite.auth0ClientMock, utils.Logger())
+	suite.outboxRepositoryMock = new(repository.OutboxRepositoryMock)
+	transactions := &repository.TransactionManagerMock{Repositories: &repository.Repositories{
+		Users:  suite.userRepositoryMock,
+		Outbox: suite.outboxRepositoryMock,
+	}}
+	suite.service = NewUserService(suite.userRepositoryMock, suite.auth0ClientMock, transactions, nil, logging.Logger())
 }
 
 func (suite *UserServiceUnitTestsSuite) TestNewUserService() {
@@ -42,7 +54,7 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_Register_PasswordDoesntC
 	userEntity.Password = "password"
 
 	passwordErr := errors.New("Password must contain at least one number!")
-	_, err := suite.service.Register(&userEntity)
+	_, err := suite.service.Register(context.Background(), &userEntity)
 
 	assert.Equal(suite.T(), passwordErr, err)
 }
@@ -52,7 +64,7 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_Register_PasswordIsLessT
 	userEntity.Password = "pass"
 
 	passwordErr := errors.New("Password must be at least 8 characters long!")
-	_, err := suite.service.Register(&userEntity)
+	_, err := suite.service.Register(context.Background(), &userEntity)
 
 	assert.Equal(suite.T(), passwordErr, err)
 }
@@ -75,10 +87,13 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_Register_ValidDataProvid
 
 	forReturn := mapper.UserToDTO(user)
 
-	suite.userRepositoryMock.On("AddUser", mock.AnythingOfType("*model.User")).Return(1, nil).Once()
+	suite.userRepositoryMock.On("AddUser", mock.MatchedBy(func(u *model.User) bool { return u.Active })).Return(1, nil).Once()
 	suite.auth0ClientMock.On("Register", userDTO.Email, userDTO.Password).Return("123", nil).Once()
 	suite.userRepositoryMock.On("Update", mock.AnythingOfType("*model.User")).Return(forReturn, nil).Once()
-	userID, err := suite.service.Register(&userDTO)
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "user.registered.v1"
+	})).Return(nil).Once()
+	userID, err := suite.service.Register(context.Background(), &userDTO)
 
 	assert.Equal(suite.T(), 1, userID)
 	assert.Equal(suite.T(), nil, err)
@@ -90,7 +105,7 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_GetByEmail_UserDoesNotEx
 
 	suite.userRepositoryMock.On("GetByEmail", email).Return(nil, err).Once()
 
-	_, userErr := suite.service.GetByEmail(email)
+	_, userErr := suite.service.GetByEmail(context.Background(), email)
 
 	assert.Equal(suite.T(), err, userErr)
 }
@@ -101,7 +116,7 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_GetByEmail_UserExists()
 
 	suite.userRepositoryMock.On("GetByEmail", email).Return(&userEntity, nil).Once()
 
-	retUser, _ := suite.service.GetByEmail(email)
+	retUser, _ := suite.service.GetByEmail(context.Background(), email)
 
 	assert.Equal(suite.T(), userEntity, *retUser)
 }
@@ -114,7 +129,7 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_Update_UserToUpdateNotFo
 
 	suite.userRepositoryMock.On("GetByID", userEntity.ID).Return(nil, err).Once()
 
-	_, e := suite.service.Update(&userEntity)
+	_, e := suite.service.Update(context.Background(), &userEntity)
 
 	assert.Equal(suite.T(), err, e)
 }
@@ -160,10 +175,13 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_Update_UserUpdated() {
 		Auth0ID:     "123",
 	}
 	suite.userRepositoryMock.On("Update", toUpdate).Return(&forReturn, nil).Once()
+	suite.outboxRepositoryMock.On("Add", mock.MatchedBy(func(message *model.OutboxMessage) bool {
+		return message.RoutingKey == "user.updated.v1" && strings.Contains(message.Body, `"ChangedFields":["first_name","last_name"]`)
+	})).Return(nil).Once()
 
 	suite.auth0ClientMock.On("Update", forReturn.Email, forReturn.Auth0ID).Return(nil).Once()
 
-	updatedUser, err := suite.service.Update(&user)
+	updatedUser, err := suite.service.Update(context.Background(), &user)
 
 	assert.Equal(suite.T(), user.FirstName, updatedUser.FirstName)
 	assert.Equal(suite.T(), user.LastName, updatedUser.LastName)
@@ -174,11 +192,78 @@ func (suite *UserServiceUnitTestsSuite) TestUserService_Update_UserUpdated() {
 	assert.Equal(suite.T(), nil, err)
 }
 
+func (suite *UserServiceUnitTestsSuite) TestUserService_Update_KeepsServerManagedFields() {
+	gender := model.Male
+	user := dto.UserUpdateDTO{
+		ID:        7,
+		FirstName: "Pera",
+		LastName:  "Peric",
+		Email:     "pera@test.com",
+		Gender:    &gender,
+		Username:  "pera",
+	}
+
+	changedAt := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	userEntity := model.User{
+		ID:                  7,
+		FirstName:           "Pera",
+		LastName:            "Peric",
+		Email:               "pera@test.com",
+		Gender:              &gender,
+		Username:            "pera",
+		Auth0ID:             "auth0|7",
+		Password:            "hash",
+		Active:              true,
+		FollowNotifications: true,
+		LikeNotifications:   true,
+		Flagged:             true,
+		ReportCount:         4,
+		PasswordChangedAt:   &changedAt,
+		CreatedAt:           time.Date(2022, 4, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	suite.userRepositoryMock.On("GetByID", user.ID).Return(&userEntity, nil).Once()
+	var saved *model.User
+	suite.userRepositoryMock.On("Update", mock.MatchedBy(func(u *model.User) bool { return u.ID == 7 })).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(*model.User)
+	}).Return(&dto.UserResponseDTO{ID: 7}, nil).Once()
+	suite.auth0ClientMock.On("Update", user.Email, userEntity.Auth0ID).Return(nil).Once()
+
+	_, err := suite.service.Update(context.Background(), &user)
+
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), saved.Active)
+	assert.True(suite.T(), saved.FollowNotifications)
+	assert.True(suite.T(), saved.LikeNotifications)
+	assert.True(suite.T(), saved.Flagged)
+	assert.Equal(suite.T(), 4, saved.ReportCount)
+	assert.Equal(suite.T(), &changedAt, saved.PasswordChangedAt)
+	assert.Equal(suite.T(), userEntity.CreatedAt, saved.CreatedAt)
+}
+
 func (suite *UserServiceUnitTestsSuite) TestUserService_GetBlockedUsers_NoBlockedUsersReturnsEmpty() {
 	suite.userRepositoryMock.On("GetByAuth0ID", "1").Return(&model.User{ID: 1}, nil).Once()
 	suite.userRepositoryMock.On("GetBlockedUsers", 1).Return([]model.User{}).Once()
 
-	blockedUsers := suite.service.GetBlockedUsers("1")
+	blockedUsers := suite.service.GetBlockedUsers(context.Background(), "1")
 
 	assert.Equal(suite.T(), 0, len(blockedUsers))
 }
+
+func (suite *UserServiceUnitTestsSuite) TestUserService_Register_RecordsOutcome() {
+	registry := prometheus.NewRegistry()
+	service := *suite.service.(*UserService)
+	service.Metrics, _ = metrics.NewDomainMetrics(registry)
+
+	service.Register(context.Background(), &dto.RegistrationRequestDTO{Password: "short"})
+	service.Register(context.Background(), &dto.RegistrationRequestDTO{Password: "password"})
+	service.Register(context.Background(), &dto.RegistrationRequestDTO{Password: "password123"})
+
+	expected := `
+# HELP users_registrations_total User registrations, by outcome (success or the reason they failed).
+# TYPE users_registrations_total counter
+users_registrations_total{outcome="invalid_password"} 2
+users_registrations_total{outcome="invalid_user"} 1
+`
+	assert.Nil(suite.T(), testutil.GatherAndCompare(registry, strings.NewReader(expected), "users_registrations_total"))
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+const (
+	gormContextKey = "tracing:context"
+	gormSpanKey    = "tracing:span"
+)
+
+// WithContext returns database carrying ctx. Queries run through it, and
+// through transactions begun from it, are traced as children of the span in
+// ctx.
+func WithContext(database *gorm.DB, ctx context.Context) *gorm.DB {
+	if database == nil || ctx == nil {
+		return database
+	}
+	return database.Set(gormContextKey, ctx)
+}
+
+// RegisterGormCallbacks adds a span around every create, query, update and
+// delete of database. Queries without a context (see WithContext) aren't
+// traced.
+func RegisterGormCallbacks(database *gorm.DB) {
+	callbacks := database.Callback()
+
+	callbacks.Create().Before("gorm:create").Register("tracing:before_create", startGormSpan("create"))
+	callbacks.Create().After("gorm:create").Register("tracing:after_create", finishGormSpan)
+	callbacks.Query().Before("gorm:query").Register("tracing:before_query", startGormSpan("query"))
+	callbacks.Query().After("gorm:query").Register("tracing:after_query", finishGormSpan)
+	callbacks.RowQuery().Before("gorm:row_query").Register("tracing:before_row_query", startGormSpan("row_query"))
+	callbacks.RowQuery().After("gorm:row_query").Register("tracing:after_row_query", finishGormSpan)
+	callbacks.Update().Before("gorm:update").Register("tracing:before_update", startGormSpan("update"))
+	callbacks.Update().After("gorm:update").Register("tracing:after_update", finishGormSpan)
+	callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", startGormSpan("delete"))
+	callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", finishGormSpan)
+}
+
+func startGormSpan(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		value, ok := scope.Get(gormContextKey)
+		if !ok {
+			return
+		}
+		ctx, ok := value.(context.Context)
+		if !ok {
+			return
+		}
+
+		span, _ := opentracing.StartSpanFromContext(ctx, "gorm."+operation)
+		ext.DBType.Set(span, "postgresql")
+		ext.SpanKindRPCClient.Set(span)
+		span.SetTag("db.table", scope.TableName())
+		scope.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func finishGormSpan(scope *gorm.Scope) {
+	value, ok := scope.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(opentracing.Span)
+	if !ok {
+		return
+	}
+
+	ext.DBStatement.Set(span, scope.SQL)
+	if scope.HasError() && !gorm.IsRecordNotFoundError(scope.DB().Error) {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(scope.DB().Error))
+	}
+	span.Finish()
+}
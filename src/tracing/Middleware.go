@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"fmt"
+	"user-ms/src/requestid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// Middleware starts a server span for every request, continuing the trace
+// from the request headers when the caller sent one. The span is put in the
+// request context, so spans the handlers start become its children.
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tracer := opentracing.GlobalTracer()
+		parent, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(ctx.Request.Header))
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		span := tracer.StartSpan(fmt.Sprintf("HTTP %s %s", ctx.Request.Method, route), ext.RPCServerOption(parent))
+		defer span.Finish()
+
+		ext.HTTPMethod.Set(span, ctx.Request.Method)
+		ext.HTTPUrl.Set(span, ctx.Request.URL.Path)
+		ext.Component.Set(span, "gin")
+		if id := requestid.FromContext(ctx.Request.Context()); id != "" {
+			span.SetTag("request_id", id)
+		}
+
+		ctx.Request = ctx.Request.WithContext(opentracing.ContextWithSpan(ctx.Request.Context(), span))
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		ext.HTTPStatusCode.Set(span, uint16(status))
+		if status >= 500 {
+			ext.Error.Set(span, true)
+		}
+	}
+}
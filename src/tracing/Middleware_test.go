@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TracingTestsSuite struct {
+	suite.Suite
+	tracer *mocktracer.MockTracer
+}
+
+func TestTracingTestsSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestsSuite))
+}
+
+func (suite *TracingTestsSuite) SetupTest() {
+	suite.tracer = mocktracer.New()
+	opentracing.SetGlobalTracer(suite.tracer)
+}
+
+func (suite *TracingTestsSuite) TearDownTest() {
+	opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+}
+
+func (suite *TracingTestsSuite) TestMiddleware_ContinuesTrace() {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/users/:id", func(ctx *gin.Context) {
+		assert.NotNil(suite.T(), opentracing.SpanFromContext(ctx.Request.Context()))
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	caller := suite.tracer.StartSpan("caller")
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	suite.tracer.Inject(caller.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := suite.tracer.FinishedSpans()
+	assert.Len(suite.T(), spans, 1)
+	assert.Equal(suite.T(), "HTTP GET /users/:id", spans[0].OperationName)
+	assert.Equal(suite.T(), caller.Context().(mocktracer.MockSpanContext).TraceID, spans[0].SpanContext.TraceID)
+	assert.Equal(suite.T(), uint16(500), spans[0].Tag("http.status_code"))
+	assert.Equal(suite.T(), true, spans[0].Tag("error"))
+}
+
+func (suite *TracingTestsSuite) TestTransport_InjectsTrace() {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+
+	parent := suite.tracer.StartSpan("parent")
+	req, _ := http.NewRequestWithContext(opentracing.ContextWithSpan(context.Background(), parent), http.MethodGet, server.URL, nil)
+	res, err := NewHTTPClient(0).Do(req)
+	assert.Nil(suite.T(), err)
+	res.Body.Close()
+
+	spans := suite.tracer.FinishedSpans()
+	assert.Len(suite.T(), spans, 1)
+	assert.Equal(suite.T(), parent.Context().(mocktracer.MockSpanContext).SpanID, spans[0].ParentID)
+	injected, err := suite.tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), spans[0].SpanContext.SpanID, injected.(mocktracer.MockSpanContext).SpanID)
+	assert.Empty(suite.T(), req.Header)
+}
+
+func (suite *TracingTestsSuite) TestTransport_WithoutSpan() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	res, err := NewHTTPClient(0).Get(server.URL)
+	assert.Nil(suite.T(), err)
+	res.Body.Close()
+
+	assert.Empty(suite.T(), suite.tracer.FinishedSpans())
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// Transport traces outgoing requests made with a context that carries a span
+// and injects the trace into their headers. Requests without a span in their
+// context are sent as they are.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: &Transport{Base: http.DefaultTransport}}
+}
+
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := opentracing.SpanFromContext(req.Context())
+	if parent == nil {
+		return transport.Base.RoundTrip(req)
+	}
+
+	tracer := parent.Tracer()
+	span := tracer.StartSpan(fmt.Sprintf("HTTP %s %s", req.Method, req.URL.Host), opentracing.ChildOf(parent.Context()), ext.SpanKindRPCClient)
+	defer span.Finish()
+
+	ext.HTTPMethod.Set(span, req.Method)
+	ext.HTTPUrl.Set(span, req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+
+	// RoundTrippers must not modify the request they were given.
+	req = req.Clone(req.Context())
+	tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+
+	res, err := transport.Base.RoundTrip(req)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+		return nil, err
+	}
+
+	ext.HTTPStatusCode.Set(span, uint16(res.StatusCode))
+	if res.StatusCode >= 500 {
+		ext.Error.Set(span, true)
+	}
+	return res, nil
+}